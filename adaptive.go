@@ -0,0 +1,77 @@
+package culsim
+
+import (
+	"errors"
+	"math"
+)
+
+// interactions of the tick about to run: Params.Interactions, or with
+// WithAdaptiveInteractions that many in proportion to the active bonds of
+// the grid, at least 1 while any bond is active and none once the grid is
+// frozen
+func (e *Engine) tickInteractions() int {
+	if !e.params.Adaptive {
+		return e.params.Interactions
+	}
+	active, bonds := e.bonds()
+	if active == 0 {
+		return 0
+	}
+	return max(1, int(math.Ceil(float64(e.params.Interactions)*float64(active)/float64(bonds))))
+}
+
+// the active bonds of the grid, pairs of neighbouring populated cells that
+// could still exchange a trait under the rule, and all bonds between
+// populated cells, from the cells of the window of the config if it has
+// one. Under the distance rule a pair is active if it is at a distance the
+// exchange function gives a chance of exchanging at, under
+// Axelrod's rule if its cultures share some features but not all, and under
+// a scripted rule if its cultures differ.
+func (e *Engine) bonds() (active, bonds int) {
+	for n, culture := range e.cultures {
+		if culture == Empty || e.frozen(n) {
+			continue
+		}
+		for _, m := range e.neighbours(n) {
+			if e.cultures[m] == Empty {
+				continue
+			}
+			bonds++
+			shared := e.sharedFeatures(culture, e.cultures[m])
+			switch {
+			case shared == e.features:
+			case e.cfg.Rule != nil:
+				active++
+			case e.params.Rule == Axelrod:
+				if shared > 0 {
+					active++
+				}
+			default:
+				if d := e.diff(n, m); d > 0 && e.exchangeProbability(d, shared) > 0 {
+					active++
+				}
+			}
+		}
+	}
+	return active, bonds
+}
+
+// check that the interactions can adapt to the active bonds, which leave out
+// the empty cells colonization spreads into and the changes of noise
+func (p *Params) validateAdaptive() error {
+	if !p.Adaptive {
+		return nil
+	}
+	if p.Noise > 0 || p.Colonization > 0 || p.Config != nil && p.Config.Noise != nil {
+		return errors.New("adaptive interactions cannot be combined with noise or colonization")
+	}
+	return nil
+}
+
+// metric of the interactions of every tick with WithAdaptiveInteractions
+func (e *Engine) adaptiveMetrics() []Metric {
+	if !e.params.Adaptive {
+		return nil
+	}
+	return []Metric{NewMetric("interactions", func(e *Engine) float64 { return float64(e.interactions) })}
+}
@@ -0,0 +1,47 @@
+package culsim
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// adaptive interactions keep to Params.Interactions, with at least one a
+// tick while any bond is active, and stop once the grid is stable
+func TestAdaptiveInteractions(t *testing.T) {
+	e, err := New(WithGrid(10, 10), WithRule(Axelrod), WithFeatures(3, 3), WithAdaptiveInteractions(), WithSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	interactions := func() int {
+		values := series(t, e, "interactions")
+		return int(values[len(values)-1])
+	}
+	for !e.Stable() {
+		if e.Tick() == 5000 {
+			t.Fatal("grid not stable after 5000 ticks")
+		}
+		active, _ := e.bonds()
+		e.Step(context.Background())
+		if n := interactions(); n > e.Params().Interactions || active > 0 && n < 1 {
+			t.Fatalf("tick %d: %d interactions with %d active bonds", e.Tick(), n, active)
+		}
+	}
+	grid := e.Cultures()
+	e.Step(context.Background())
+	if n := interactions(); n != 0 {
+		t.Errorf("%d interactions on a stable grid", n)
+	}
+	if !reflect.DeepEqual(e.Cultures(), grid) {
+		t.Error("stable grid changed")
+	}
+
+	e, err = New(WithGrid(10, 10), WithInitial("converged"), WithAdaptiveInteractions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Step(context.Background())
+	if n := interactions(); n != 0 {
+		t.Errorf("%d interactions on a converged grid", n)
+	}
+}
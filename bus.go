@@ -0,0 +1,64 @@
+package culsim
+
+// Message is published on a Bus, a TickCompleted, ExchangeHappened,
+// SnapshotTaken or InterventionApplied
+type Message interface {
+	message()
+}
+
+// TickCompleted is published at the end of every tick, after its metrics
+// are recorded
+type TickCompleted struct {
+	Tick  int
+	Stats Stats
+}
+
+// ExchangeHappened is published after every exchange, with the cell copied
+// from, the cell that copied, the feature and the new culture
+type ExchangeHappened struct {
+	Tick, Src, Dst, Feature, Culture int
+}
+
+// SnapshotTaken is published by whatever records the grid of a tick, such as
+// the culsim command with -snapshots
+type SnapshotTaken struct {
+	Tick     int
+	Cultures []int
+}
+
+// InterventionApplied is published after every intervention in a running
+// simulation
+type InterventionApplied struct {
+	Intervention Intervention
+}
+
+func (TickCompleted) message()       {}
+func (ExchangeHappened) message()    {}
+func (SnapshotTaken) message()       {}
+func (InterventionApplied) message() {}
+
+// Bus delivers the messages published on it to every subscriber, in the
+// order they subscribed and in the goroutine that publishes them.
+// Subscribers can publish messages of their own, which are delivered before
+// Publish returns. A Bus is not safe for concurrent use, subscribe before
+// running the simulation.
+type Bus struct {
+	subscribers []func(Message)
+}
+
+// Subscribe calls f with every message published from now on
+func (b *Bus) Subscribe(f func(Message)) {
+	b.subscribers = append(b.subscribers, f)
+}
+
+// Publish delivers a message to every subscriber
+func (b *Bus) Publish(m Message) {
+	for _, f := range b.subscribers {
+		f(m)
+	}
+}
+
+// check if anything is subscribed, so messages aren't made for nothing
+func (b *Bus) active() bool {
+	return len(b.subscribers) > 0
+}
@@ -0,0 +1,137 @@
+package culsim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// most violations reported by a check, the rest are counted
+const maxViolations = 10
+
+// state of the engine at the previous check, to check what changed since
+type checked struct {
+	tick        int
+	cultures    []int
+	influences  []int
+	persistence int
+}
+
+// Check verifies the invariants of the state of the engine, returning every
+// violation found with the cells and values involved. Every culture is
+// empty or has traits below the engine's traits in its features and none in
+// the others, and the metrics don't run ahead of the tick. Called after
+// every tick, it also checks what changed since: the tick, influences and
+// the minority's persistence never went back, there were no more exchanges
+// than the interactions allow and cells only became empty in disasters or
+// populated by colonization or disasters.
+func (e *Engine) Check() error {
+	var v violations
+	cells := e.width * e.height
+	if len(e.cultures) != cells || len(e.influences) != cells {
+		v.add("%d cultures and %d influences for a grid of %d cells", len(e.cultures), len(e.influences), cells)
+		return v.err(e.tick)
+	}
+	for n, c := range e.cultures {
+		if c == Empty {
+			continue
+		}
+		if c < 0 || c > Empty {
+			v.add("cell %s has culture %X, which is not 24 bits", e.coords(n), c)
+			continue
+		}
+		for f := 0; f < Features; f++ {
+			t := extract(c, uint(f))
+			if f < e.features && t >= e.traits {
+				v.add("cell %s has culture %06X with trait %d in feature %d, traits are 0 to %d", e.coords(n), c, t, f, e.traits-1)
+			}
+			if f >= e.features && t != 0 {
+				v.add("cell %s has culture %06X with trait %d in feature %d, beyond the %d features", e.coords(n), c, t, f, e.features)
+			}
+		}
+	}
+	for _, s := range e.metrics {
+		if len(s.Values) > e.tick {
+			v.add("metric %s has %d values after %d ticks", s.Name, len(s.Values), e.tick)
+		}
+	}
+	if e.stats.Exchanges < 0 {
+		v.add("%d exchanges", e.stats.Exchanges)
+	}
+	if e.minorityPersistence > e.tick {
+		v.add("minority persisted until tick %d, after the current tick", e.minorityPersistence)
+	}
+	if p := e.checked; p != nil {
+		e.checkChanges(p, &v)
+	}
+	e.checked = &checked{e.tick, e.Cultures(), e.Influences(), e.minorityPersistence}
+	return v.err(e.tick)
+}
+
+// check what changed since the previous check
+func (e *Engine) checkChanges(p *checked, v *violations) {
+	if e.tick < p.tick {
+		v.add("tick went back from %d", p.tick)
+		return
+	}
+	if e.minorityPersistence < p.persistence {
+		v.add("minority persistence went back from tick %d to %d", p.persistence, e.minorityPersistence)
+	}
+	for n, i := range e.influences {
+		if i < p.influences[n] {
+			v.add("influences of cell %s went down from %d to %d", e.coords(n), p.influences[n], i)
+		}
+	}
+	// the rest only holds from one tick to the next
+	if e.tick != p.tick+1 {
+		return
+	}
+	if most := e.params.Interactions * 8; e.stats.Exchanges > most {
+		v.add("%d exchanges, more than the %d that %d interactions allow", e.stats.Exchanges, most, e.params.Interactions)
+	}
+	disasters := false
+	for _, ev := range e.cfg.Events {
+		disasters = disasters || ev.Type == "disaster"
+	}
+	for n, c := range e.cultures {
+		was := p.cultures[n]
+		if was == Empty && c != Empty && e.params.Colonization == 0 && !disasters {
+			v.add("empty cell %s was populated with %06X without colonization", e.coords(n), c)
+		}
+		if was != Empty && c == Empty && !disasters {
+			v.add("cell %s of culture %06X became empty without a disaster", e.coords(n), was)
+		}
+	}
+}
+
+// coordinates of the cell at index n
+func (e *Engine) coords(n int) string {
+	return fmt.Sprintf("%d,%d", n%e.width, n/e.width)
+}
+
+// violations of the invariants found by a check
+type violations struct {
+	found []string
+	count int
+}
+
+func (v *violations) add(format string, args ...interface{}) {
+	if v.count < maxViolations {
+		v.found = append(v.found, fmt.Sprintf(format, args...))
+	}
+	v.count++
+}
+
+// the violations found at a tick as an error, nil if there are none
+func (v *violations) err(tick int) error {
+	if v.count == 0 {
+		return nil
+	}
+	errs := make([]error, len(v.found))
+	for i, f := range v.found {
+		errs[i] = errors.New(f)
+	}
+	if v.count > len(v.found) {
+		errs = append(errs, fmt.Errorf("and %d more", v.count-len(v.found)))
+	}
+	return fmt.Errorf("tick %d: %d invariants violated:\n%w", tick, v.count, errors.Join(errs...))
+}
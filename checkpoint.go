@@ -0,0 +1,266 @@
+package culsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+)
+
+// Version of the checkpoint format written by Save. Load reads checkpoints
+// of this and earlier versions, migrating them to this one.
+const Version = 3
+
+// migrations of checkpoints from each earlier version to the next, add one
+// whenever Version changes
+var migrations = map[int]func(c *checkpoint){
+	// version 1 only had square grids
+	1: func(c *checkpoint) { c.Height = c.Width },
+	// version 2 had no parameters or state of the mechanisms, so its runs
+	// can be loaded but not carried on
+	2: func(c *checkpoint) {},
+}
+
+// identifies culsim checkpoints among other JSON files
+const checkpointFormat = "culsim-checkpoint"
+
+// checkpoint is the serialized state of an engine
+type checkpoint struct {
+	Format   string   `json:"format"`
+	Version  int      `json:"version"`
+	Width    int      `json:"width"`
+	Height   int      `json:"height"`
+	Tick     int      `json:"tick"`
+	Seed     int64    `json:"seed"`
+	Cultures []int    `json:"cultures"`
+	Metrics  []Series `json:"metrics"`
+	Params   *Params  `json:"params,omitempty"`
+	Draws    uint64   `json:"draws"`
+	State    *state   `json:"state,omitempty"`
+}
+
+// state of the mechanisms of a run, which a run carried on from a checkpoint
+// needs to go on as the run would have
+type state struct {
+	Influences          []int          `json:"influences"`
+	Institutions        []int          `json:"institutions,omitempty"`
+	MinorityPersistence int            `json:"minorityPersistence"`
+	Invader             int            `json:"invader"`
+	LastExchanges       [][3]int       `json:"lastExchanges,omitempty"` // pairs of cells and the tick they last exchanged
+	Interventions       []Intervention `json:"interventions,omitempty"`
+	Stats               Stats          `json:"stats"`
+}
+
+// source of random numbers counting the numbers drawn, so a run carried on
+// from a checkpoint can draw the ones the run would have drawn next
+type countingSource struct {
+	rand.Source64
+	draws uint64
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.Source64.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.Source64.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.draws = 0
+	s.Source64.Seed(seed)
+}
+
+// Save writes the state of the engine as a JSON checkpoint, with the
+// parameters of its run and where it is in its random numbers
+func (e *Engine) Save(w io.Writer) error {
+	e.lastExchange.Lock()
+	exchanges := make([][3]int, 0, len(e.lastExchange.ticks))
+	for pair, tick := range e.lastExchange.ticks {
+		exchanges = append(exchanges, [3]int{pair[0], pair[1], tick})
+	}
+	e.lastExchange.Unlock()
+	sort.Slice(exchanges, func(i, j int) bool {
+		return exchanges[i][0] < exchanges[j][0] || exchanges[i][0] == exchanges[j][0] && exchanges[i][1] < exchanges[j][1]
+	})
+	params := e.params
+	enc := json.NewEncoder(w)
+	return enc.Encode(checkpoint{
+		Format:   checkpointFormat,
+		Version:  Version,
+		Width:    e.width,
+		Height:   e.height,
+		Tick:     e.tick,
+		Seed:     e.seed,
+		Cultures: e.cultures,
+		Metrics:  e.metrics,
+		Params:   &params,
+		Draws:    e.source.draws,
+		State: &state{
+			Influences:          e.influences,
+			Institutions:        e.institutions,
+			MinorityPersistence: e.minorityPersistence,
+			Invader:             e.invader,
+			LastExchanges:       exchanges,
+			Interventions:       e.interventions,
+			Stats:               e.stats,
+		},
+	})
+}
+
+// Load reads an engine from a checkpoint written by Save, with the
+// parameters, grid, tick and metrics of its run, which carries on as the run
+// would have. The engine of a checkpoint of version 2 or earlier, which hold
+// no parameters, has the grid, tick, seed and metrics of the checkpoint and
+// the default parameters.
+func Load(r io.Reader) (*Engine, error) {
+	c, err := readCheckpoint(r)
+	if err != nil {
+		return nil, err
+	}
+	if c.Params == nil {
+		p := defaultParams()
+		p.Width, p.Height, p.Seed = c.Width, c.Height, c.Seed
+		e := newEngine(p)
+		e.restore(c)
+		return e, nil
+	}
+	p := *c.Params
+	if err = p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint parameters: %w", err)
+	}
+	e := newEngine(p)
+	if err = e.registerBuiltins(); err != nil {
+		return nil, err
+	}
+	e.restore(c)
+	return e, nil
+}
+
+// Restore carries on the run saved in a checkpoint written by Save, giving
+// the engine the grid, tick, metrics, random numbers and state of the
+// mechanisms of the checkpoint, so the run goes on as it would have. The
+// engine must have the parameters of the run but for its seed, which is
+// taken from the checkpoint, and its duration. Checkpoints of version 2 or
+// earlier can't be carried on, as they hold no parameters.
+func (e *Engine) Restore(r io.Reader) error {
+	c, err := readCheckpoint(r)
+	if err != nil {
+		return err
+	}
+	if c.Width != e.width || c.Height != e.height {
+		return fmt.Errorf("checkpoint grid of %dx%d cells does not fit a grid of %dx%d cells", c.Width, c.Height, e.width, e.height)
+	}
+	if c.Params == nil {
+		return fmt.Errorf("checkpoint of version %d holds no parameters to carry on its run with, load its grid instead", c.Version)
+	}
+	if changed := changedParams(*c.Params, e.params); len(changed) > 0 {
+		return fmt.Errorf("checkpoint of a run with other parameters: %v", changed)
+	}
+	e.restore(c)
+	return nil
+}
+
+// names of the parameters that differ between 2 runs, but for the seed and
+// the duration, with the settings of the config as one
+func changedParams(a, b Params) []string {
+	a.Seed, a.Duration = b.Seed, b.Duration
+	var fields [2]map[string]json.RawMessage
+	for i, p := range []Params{a, b} {
+		// parameters always encode
+		data, _ := json.Marshal(p)
+		_ = json.Unmarshal(data, &fields[i])
+	}
+	var changed []string
+	for name, value := range fields[0] {
+		if string(value) != string(fields[1][name]) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// read and check a checkpoint
+func readCheckpoint(r io.Reader) (*checkpoint, error) {
+	var c checkpoint
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	if c.Format != checkpointFormat {
+		return nil, fmt.Errorf("not a culsim checkpoint")
+	}
+	if c.Version < 1 || c.Version > Version {
+		return nil, fmt.Errorf("unsupported checkpoint version %d, this culsim reads up to version %d", c.Version, Version)
+	}
+	for ; c.Version < Version; c.Version++ {
+		migrations[c.Version](&c)
+	}
+	if c.Width <= 0 || c.Height <= 0 || len(c.Cultures) != c.Width*c.Height {
+		return nil, fmt.Errorf("%d cultures do not fill a grid of %dx%d cells", len(c.Cultures), c.Width, c.Height)
+	}
+	if c.Tick < 0 {
+		return nil, fmt.Errorf("invalid tick %d", c.Tick)
+	}
+	if c.Params != nil {
+		if c.Params.Width != c.Width || c.Params.Height != c.Height || c.Params.Seed != c.Seed {
+			return nil, fmt.Errorf("parameters of a grid of %dx%d cells and seed %d in a checkpoint of a grid of %dx%d cells and seed %d",
+				c.Params.Width, c.Params.Height, c.Params.Seed, c.Width, c.Height, c.Seed)
+		}
+		if c.State == nil || len(c.State.Influences) != len(c.Cultures) {
+			return nil, fmt.Errorf("checkpoint without the state of its run")
+		}
+	}
+	return &c, nil
+}
+
+// take the grid, tick and metrics of a checkpoint, metrics the engine
+// doesn't record are left out, and the seed, random numbers and state of
+// the mechanisms of a checkpoint with parameters
+func (e *Engine) restore(c *checkpoint) {
+	copy(e.cultures, c.Cultures)
+	e.tick = c.Tick
+	e.checked = nil
+	if c.Params != nil {
+		e.restoreState(c)
+	}
+	if e.metrics == nil {
+		e.metrics = c.Metrics
+		return
+	}
+	for _, m := range c.Metrics {
+		for i := range e.metrics {
+			if e.metrics[i].Name == m.Name {
+				e.metrics[i].Values = append([]float64(nil), m.Values...)
+			}
+		}
+	}
+}
+
+// take the seed, random numbers and state of the mechanisms of a checkpoint,
+// redoing the changes the interventions of its run made to the config
+func (e *Engine) restoreState(c *checkpoint) {
+	e.seed, e.params.Seed = c.Seed, c.Seed
+	e.source.Seed(c.Seed)
+	for e.source.draws < c.Draws {
+		e.source.Uint64()
+	}
+	s := c.State
+	copy(e.influences, s.Influences)
+	e.institutions = s.Institutions
+	e.minorityPersistence, e.invader, e.stats = s.MinorityPersistence, s.Invader, s.Stats
+	e.lastExchange.ticks = make(map[[2]int]int, len(s.LastExchanges))
+	for _, x := range s.LastExchanges {
+		e.lastExchange.ticks[[2]int{x[0], x[1]}] = x[2]
+	}
+	e.cfg = e.params.Config
+	e.interventions = append([]Intervention(nil), s.Interventions...)
+	for _, iv := range e.interventions {
+		if iv.Type == "noise" || iv.Type == "event" {
+			e.reconfigure(iv)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package culsim
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runs carried on from checkpoints, covering the mechanisms with state of
+// their own
+var checkpointRuns = []struct {
+	name   string
+	config string // config file in testdata, if any
+	invade int    // width of the block of invaders seeded, if any
+	opts   []Option
+}{
+	{name: "mechanisms", opts: []Option{WithGrid(24, 24), WithCoverage(0.8), WithNoise(0.02), WithColonization(0.1),
+		WithConquest(0.05, 3), WithReputation(1), WithRefractory(2)}},
+	{name: "config", config: "config.json", opts: []Option{WithCoverage(0.9), WithInitial("zipf")}},
+	{name: "invasion", invade: 6, opts: []Option{WithGrid(24, 24), WithInitial("converged"), WithInvader(1, 1)}},
+	{name: "parallel", opts: []Option{WithGrid(40, 40), WithInteractions(1000), WithWorkers(4)}},
+}
+
+// step an engine to a tick, changing its noise and adding a policy event
+// at tick 5 on the way
+func stepTo(t *testing.T, e *Engine, tick int) {
+	t.Helper()
+	for e.Tick() < tick {
+		e.Step(context.Background())
+		if e.Tick() != 5 {
+			continue
+		}
+		policy := &Event{Type: "policy", Tick: 6, End: 30, Region: Region{W: 12, H: 12}, Feature: 1, Trait: 3, Rate: 0.3}
+		for _, iv := range []Intervention{{Tick: 5, Type: "noise", Rate: 0.05}, {Tick: 5, Type: "event", Event: policy}} {
+			if err := e.Intervene(iv); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// state of a run that a run carried on from a checkpoint must match
+func runState(e *Engine) []any {
+	return []any{e.Tick(), e.Cultures(), e.Metrics(), e.Influences(), e.Institutions(), e.Stats(),
+		e.MinorityPersistence(), e.Invaders(), e.Interventions()}
+}
+
+// a run of n ticks ends as a run of k ticks saved and carried on for n - k
+// more ticks does, whether the checkpoint is loaded or restored into an
+// engine of the same parameters
+func TestCheckpointCarriesOnRun(t *testing.T) {
+	const k, n = 15, 40
+	for _, run := range checkpointRuns {
+		t.Run(run.name, func(t *testing.T) {
+			start := func(seed int64) *Engine {
+				opts := append([]Option{WithSeed(seed), WithDuration(n)}, run.opts...)
+				if run.config != "" {
+					cfg, err := LoadConfig(filepath.Join("testdata", run.config))
+					if err != nil {
+						t.Fatal(err)
+					}
+					opts = append(opts, WithConfig(cfg))
+				}
+				e, err := New(opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if run.invade > 0 {
+					if err = e.Invade(run.invade); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return e
+			}
+			whole := start(1)
+			stepTo(t, whole, n)
+			want := runState(whole)
+
+			saved := start(1)
+			stepTo(t, saved, k)
+			var buf bytes.Buffer
+			if err := saved.Save(&buf); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := Load(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// an engine of another seed takes the seed of the checkpoint
+			restored := start(2)
+			if err = restored.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			for name, e := range map[string]*Engine{"loaded": loaded, "restored": restored} {
+				stepTo(t, e, n)
+				if got := runState(e); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s run ended at tick %d other than the run it carried on", name, e.Tick())
+				}
+			}
+		})
+	}
+}
+
+// a checkpoint carries on only a run of the same parameters, and one without
+// parameters none
+func TestRestoreRefusesOtherRuns(t *testing.T) {
+	e, err := New(WithGrid(12, 12), WithSeed(1), WithDuration(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = e.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = e.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	other, err := New(WithGrid(12, 12), WithInteractions(50), WithNoise(0.01))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = other.Restore(bytes.NewReader(buf.Bytes()))
+	if err == nil || !strings.Contains(err.Error(), "[Interactions Noise]") {
+		t.Errorf("restored a checkpoint of other parameters, error %v", err)
+	}
+
+	old := `{"format": "culsim-checkpoint", "version": 2, "width": 12, "height": 12, "cultures": [` +
+		strings.Repeat("0,", 12*12-1) + `0]}`
+	if err = e.Restore(strings.NewReader(old)); err == nil {
+		t.Error("restored a checkpoint without parameters")
+	}
+	if _, err = Load(strings.NewReader(old)); err != nil {
+		t.Errorf("cannot load a checkpoint of version 2: %v", err)
+	}
+}
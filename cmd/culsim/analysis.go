@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// R script plotting the series of a simulation log with the tidyverse
+var analysisR = template.Must(template.New("R").Parse(`# Plots of the culsim run {{.Name}}, saved in {{.Plot}}.
+# Run from the directory culsim ran in with: Rscript {{.Script}}
+library(tidyverse)
+
+# every line of the log is a series, its name followed by a value per tick
+lines <- strsplit(readLines("{{.Log}}"), ",")
+series <- c({{.Series}})
+
+metrics <- map_dfr(lines, function(line) {
+  tibble(metric = line[1], tick = seq_along(line[-1]), value = as.numeric(line[-1]))
+}) %>% filter(metric %in% series)
+
+plot <- ggplot(metrics, aes(tick, value)) +
+  geom_line() +
+  facet_wrap(~metric, scales = "free_y", ncol = 1) +
+  labs(title = "{{.Name}}", x = "tick", y = NULL)
+ggsave("{{.Plot}}", plot, width = 8, height = 2.5 * length(series))
+`))
+
+// Python script plotting the series of a simulation log with pandas
+var analysisPy = template.Must(template.New("py").Parse(`"""Plots of the culsim run {{.Name}}, saved in {{.Plot}}.
+
+Run from the directory culsim ran in with: python3 {{.Script}}
+"""
+import csv
+
+import matplotlib.pyplot as plt
+import pandas as pd
+
+SERIES = [{{.Series}}]
+
+# every line of the log is a series, its name followed by a value per tick
+with open("{{.Log}}") as f:
+    lines = {row[0]: row[1:] for row in csv.reader(f)}
+metrics = {name: pd.Series(lines[name], dtype=float, index=range(1, len(lines[name]) + 1)) for name in SERIES}
+
+fig, axes = plt.subplots(len(SERIES), 1, sharex=True, figsize=(8, 2.5 * len(SERIES)), squeeze=False)
+for ax, name in zip(axes[:, 0], SERIES):
+    metrics[name].plot(ax=ax)
+    ax.set_title(name)
+axes[-1, 0].set_xlabel("tick")
+fig.suptitle("{{.Name}}")
+fig.tight_layout()
+fig.savefig("{{.Plot}}")
+`))
+
+// save R and Python scripts that plot every series of the simulation log in
+// data/analysis-<name>.R and .py
+func saveAnalysis(name string, data [][]string) {
+	var series []string
+	for _, line := range data {
+		// single values such as minority persistence are not plotted
+		if len(line) > 2 {
+			series = append(series, fmt.Sprintf("%q", line[0]))
+		}
+	}
+	for _, script := range []struct {
+		ext  string
+		tmpl *template.Template
+	}{{"R", analysisR}, {"py", analysisPy}} {
+		path := fmt.Sprintf("data/analysis-%s.%s", name, script.ext)
+		file, err := os.Create(path)
+		if err != nil {
+			slog.Error("failed saving analysis script", "path", path, "err", err)
+			continue
+		}
+		err = script.tmpl.Execute(file, map[string]string{
+			"Name":   name,
+			"Log":    fmt.Sprintf("data/log-%s.csv", name),
+			"Plot":   fmt.Sprintf("data/plots-%s-%s.png", name, strings.ToLower(script.ext)),
+			"Script": path,
+			"Series": strings.Join(series, ", "),
+		})
+		file.Close()
+		if err != nil {
+			slog.Error("failed saving analysis script", "path", path, "err", err)
+			continue
+		}
+		addOutput(path)
+		slog.Info("analysis script saved", "path", path)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// size of the headers of the NumPy arrays written, large enough for the
+// shape of any run so it can be filled in when the run ends
+const npyHeaderSize = 128
+
+// grid snapshots recorded in a NumPy .npy file, a uint32 array of shape
+// (ticks, width, width) that can be memory-mapped with
+// numpy.load(path, mmap_mode="r")
+type npyArchive struct {
+	file  *os.File
+	w     *bufio.Writer
+	width int
+	ticks []int
+}
+
+// start recording snapshots in a .npy file
+func createNPY(path string, width int) (*npyArchive, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &npyArchive{file: file, w: bufio.NewWriter(file), width: width}
+	if _, err = a.w.Write(npyHeader(0, width, width)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// add the cultures of the grid at a tick
+func (a *npyArchive) write(t int, cultures []int) error {
+	buf := make([]byte, 4*len(cultures))
+	for n, c := range cultures {
+		binary.LittleEndian.PutUint32(buf[4*n:], uint32(c))
+	}
+	a.ticks = append(a.ticks, t)
+	_, err := a.w.Write(buf)
+	return err
+}
+
+// finish the file, filling in the number of ticks recorded
+func (a *npyArchive) close() error {
+	err := a.w.Flush()
+	if err == nil {
+		_, err = a.file.WriteAt(npyHeader(len(a.ticks), a.width, a.width), 0)
+	}
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// compress a recorded .npy file into a .npz archive with its cultures, ticks
+// and the version of the archive, loaded with numpy.load(path)["cultures"],
+// ["ticks"] and ["version"]. A .npy file holds only the array of cultures,
+// whose layout NumPy defines.
+func (a *npyArchive) compress(path string) error {
+	src, err := os.Open(a.file.Name())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	entry, err := archive.Create("cultures.npy")
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(entry, src); err != nil {
+		return err
+	}
+	if entry, err = archive.Create("ticks.npy"); err != nil {
+		return err
+	}
+	ticks := make([]byte, 4*len(a.ticks))
+	for i, t := range a.ticks {
+		binary.LittleEndian.PutUint32(ticks[4*i:], uint32(t))
+	}
+	if _, err = entry.Write(append(npyHeader(len(a.ticks)), ticks...)); err != nil {
+		return err
+	}
+	if entry, err = archive.Create("version.npy"); err != nil {
+		return err
+	}
+	version := binary.LittleEndian.AppendUint32(npyHeader(1), snapshotVersion)
+	if _, err = entry.Write(version); err != nil {
+		return err
+	}
+	if err = archive.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// header of a little endian uint32 NumPy array of the given shape
+func npyHeader(shape ...int) []byte {
+	dims := make([]string, len(shape))
+	for i, d := range shape {
+		dims[i] = strconv.Itoa(d)
+	}
+	shapeText := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		shapeText += ","
+	}
+	dict := fmt.Sprintf("{'descr': '<u4', 'fortran_order': False, 'shape': (%s), }", shapeText)
+	header := make([]byte, npyHeaderSize)
+	copy(header, "\x93NUMPY\x01\x00")
+	binary.LittleEndian.PutUint16(header[8:], npyHeaderSize-10)
+	copy(header[10:], dict)
+	for i := 10 + len(dict); i < npyHeaderSize-1; i++ {
+		header[i] = ' '
+	}
+	header[npyHeaderSize-1] = '\n'
+	return header
+}
+
+var npyShape = regexp.MustCompile(`'shape':\s*\(([\d,\s]*)\)`)
+
+// read a little endian uint32 NumPy array and its shape
+func readNPY(r io.Reader) ([]int, []uint32, error) {
+	prefix := make([]byte, 10)
+	if _, err := io.ReadFull(r, prefix); err != nil || string(prefix[:6]) != "\x93NUMPY" {
+		return nil, nil, fmt.Errorf("not a NumPy array")
+	}
+	header := make([]byte, binary.LittleEndian.Uint16(prefix[8:]))
+	if prefix[6] != 1 {
+		return nil, nil, fmt.Errorf("unsupported NumPy format version %d", prefix[6])
+	}
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+	if !strings.Contains(string(header), "'descr': '<u4'") || strings.Contains(string(header), "'fortran_order': True") {
+		return nil, nil, fmt.Errorf("only C ordered uint32 arrays are supported")
+	}
+	match := npyShape.FindStringSubmatch(string(header))
+	if match == nil {
+		return nil, nil, fmt.Errorf("NumPy array has no shape")
+	}
+	var shape []int
+	size := 1
+	for _, d := range strings.Split(match[1], ",") {
+		if d = strings.TrimSpace(d); d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid NumPy array shape %q", match[1])
+		}
+		shape = append(shape, n)
+		size *= n
+	}
+	data := make([]uint32, size)
+	if err := binary.Read(bufio.NewReader(r), binary.LittleEndian, data); err != nil {
+		return nil, nil, err
+	}
+	return shape, data, nil
+}
+
+// read the snapshots recorded in a .npy file, whose ticks are numbered from 0
+func readNPYSnapshots(path string) (int, []snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+	shape, data, err := readNPY(file)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return npySnapshots(path, shape, data, nil)
+}
+
+// read the snapshots and their ticks recorded in a .npz archive
+func readNPZSnapshots(path string) (int, []snapshot, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer archive.Close()
+	var shape []int
+	var cultures, ticks []uint32
+	// archives of version 1 have no version array
+	version := []uint32{1}
+	for _, f := range archive.File {
+		if f.Name != "cultures.npy" && f.Name != "ticks.npy" && f.Name != "version.npy" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return 0, nil, err
+		}
+		s, data, err := readNPY(r)
+		r.Close()
+		if err != nil {
+			return 0, nil, fmt.Errorf("%s %s: %w", path, f.Name, err)
+		}
+		switch f.Name {
+		case "cultures.npy":
+			shape, cultures = s, data
+		case "ticks.npy":
+			ticks = data
+		default:
+			version = data
+		}
+	}
+	if len(version) != 1 {
+		return 0, nil, fmt.Errorf("%s has an invalid version array", path)
+	}
+	if err = checkVersion("snapshot", strconv.Itoa(int(version[0])), snapshotVersion); err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cultures == nil {
+		return 0, nil, fmt.Errorf("%s has no cultures array", path)
+	}
+	return npySnapshots(path, shape, cultures, ticks)
+}
+
+// snapshots of a (ticks, width, width) array of cultures
+func npySnapshots(path string, shape []int, data, ticks []uint32) (int, []snapshot, error) {
+	if len(shape) != 3 || shape[1] != shape[2] || shape[1] == 0 {
+		return 0, nil, fmt.Errorf("%s is not a (ticks, width, width) array", path)
+	}
+	if shape[0] == 0 {
+		return 0, nil, fmt.Errorf("%s has no snapshots recorded", path)
+	}
+	if ticks != nil && len(ticks) != shape[0] {
+		return 0, nil, fmt.Errorf("%s has %d ticks for %d snapshots", path, len(ticks), shape[0])
+	}
+	w := shape[1]
+	snapshots := make([]snapshot, shape[0])
+	for i := range snapshots {
+		snapshots[i] = snapshot{tick: i, cultures: make([]int, w*w)}
+		if ticks != nil {
+			snapshots[i].tick = int(ticks[i])
+		}
+		for n, c := range data[i*w*w : (i+1)*w*w] {
+			snapshots[i].cultures[n] = int(c)
+		}
+	}
+	return w, snapshots, nil
+}
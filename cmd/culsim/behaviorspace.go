@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/sausheong/culsim"
+)
+
+// parameters of a run in the BehaviorSpace tables, as NetLogo lists the
+// variables of an experiment
+var behaviorSpaceParams = []string{"n", "c", "d", "noise", "colonize", "conquest", "conquest-distance",
+	"reputation", "refractory", "init"}
+
+// record the cells as agents at the ticks they are due
+func (sim *CultureSim) recordAgents(m culsim.Message) {
+	if t, ok := m.(culsim.TickCompleted); !ok || !*behaviorSpace || *agentsEvery <= 0 || t.Tick%*agentsEvery != 0 {
+		return
+	}
+	sim.appendAgents()
+}
+
+// add a row for every populated cell at the current tick
+func (sim *CultureSim) appendAgents() {
+	tick := sim.engine.Tick()
+	for n, culture := range sim.cultures() {
+		if culture == culsim.Empty {
+			continue
+		}
+		x, y := coords(n)
+		row := []string{strconv.Itoa(tick), strconv.Itoa(n), strconv.Itoa(x), strconv.Itoa(y), labels.Culture(culture)}
+		row = append(row, traitValues(culture)...)
+		sim.agentRows = append(sim.agentRows, row)
+	}
+}
+
+// save the run in the table format of NetLogo BehaviorSpace experiments,
+// with model data of every step in data/model-<name>.csv and agent data in
+// data/agents-<name>.csv, the columns of both start with the run number,
+// the parameters and the step as in BehaviorSpace and Mesa batch runs
+func (sim *CultureSim) saveBehaviorSpace(name string) {
+	// the agents at the end of the run are always included
+	if len(sim.agentRows) == 0 || sim.agentRows[len(sim.agentRows)-1][0] != strconv.Itoa(sim.engine.Tick()) {
+		sim.appendAgents()
+	}
+	params := []string{"1"}
+	for _, p := range behaviorSpaceParams {
+		params = append(params, lookupFlag(p))
+	}
+	params = append(params, strconv.Itoa(width), strconv.FormatInt(seed, 10))
+	header := append([]string{"[run number]"}, behaviorSpaceParams...)
+	header = append(header, "width", "seed", "[step]")
+
+	model := [][]string{append(append([]string{}, header...), "distance", "changes", "unique", "conquest")}
+	data := sim.data()
+	columns := [][]string{seriesRow(data, "distance"), seriesRow(data, "change"), seriesRow(data, "unique"), seriesRow(data, "conquest")}
+	for i := 1; i <= sim.engine.Tick(); i++ {
+		row := append(append([]string{}, params...), strconv.Itoa(i))
+		// metrics that aren't recorded are 0
+		for _, column := range columns {
+			if i < len(column) {
+				row = append(row, column[i])
+			} else {
+				row = append(row, "0")
+			}
+		}
+		model = append(model, row)
+	}
+	agents := [][]string{append(append(append([]string{}, header...), "who", "xcor", "ycor", "culture"),
+		traitColumns("feature")...)}
+	for _, r := range sim.agentRows {
+		agents = append(agents, append(append(append([]string{}, params...), r[0]), r[1:]...))
+	}
+	for _, table := range []struct {
+		path string
+		rows [][]string
+	}{
+		{fmt.Sprintf("data/model-%s.csv", name), model},
+		{fmt.Sprintf("data/agents-%s.csv", name), agents},
+	} {
+		if err := writeBehaviorSpace(table.path, table.rows); err != nil {
+			slog.Error("failed saving BehaviorSpace table", "path", table.path, "err", err)
+			continue
+		}
+		addOutput(table.path)
+		slog.Info("BehaviorSpace table saved", "path", table.path)
+	}
+}
+
+// write a table after the 6 lines of experiment details BehaviorSpace
+// tables start with
+func writeBehaviorSpace(path string, rows [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	_ = w.WriteAll([][]string{
+		{"BehaviorSpace results (culsim)"},
+		{"culsim"},
+		{runID},
+		{started.Format("01/02/2006 15:04:05.000 -0700")},
+		{"min-pxcor", "max-pxcor", "min-pycor", "max-pycor"},
+		{"0", strconv.Itoa(width - 1), "0", strconv.Itoa(width - 1)},
+	})
+	if err = w.WriteAll(rows); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// value of a flag as given on the command line
+func lookupFlag(name string) string {
+	if f := flag.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
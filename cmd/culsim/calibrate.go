@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// a metric of the target of a calibration, observed at the ticks of its
+// series that aren't NaN, or only at the end of the run if final
+type observation struct {
+	metric string
+	series []float64
+	final  bool
+}
+
+// read the target of a calibration, a CSV laid out like data/log-*.csv: a
+// row for every metric with its name and its value at every tick from 0,
+// empty for ticks not observed, or with a single value for its final value
+func readTarget(path string) ([]observation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var target []observation
+	for _, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("row %q has no values", strings.Join(record, ","))
+		}
+		o := observation{metric: strings.TrimSpace(record[0]), final: len(record) == 2}
+		for _, s := range record[1:] {
+			v := math.NaN()
+			if s = strings.TrimSpace(s); s != "" {
+				if v, err = strconv.ParseFloat(s, 64); err != nil {
+					return nil, fmt.Errorf("metric %s: %w", o.metric, err)
+				}
+			}
+			o.series = append(o.series, v)
+		}
+		target = append(target, o)
+	}
+	if len(target) == 0 {
+		return nil, errors.New("no metrics")
+	}
+	return target, nil
+}
+
+// read the series of the metrics of a run from its data/log-*.csv, by name
+func readLog(path string) (map[string][]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	series := make(map[string][]float64)
+	for _, record := range records {
+		values := make([]float64, 0, len(record)-1)
+		for _, s := range record[1:] {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				break
+			}
+			values = append(values, v)
+		}
+		series[record[0]] = values
+	}
+	return series, nil
+}
+
+// distance of the series of a run from the target, the root mean square
+// error of every metric over the ticks observed, relative to the mean size
+// of the metric in the target so that metrics of any scale count the same,
+// averaged over the metrics. Runs that end early keep their last values.
+func targetDistance(target []observation, run map[string][]float64) (float64, error) {
+	var total float64
+	for _, o := range target {
+		values := run[o.metric]
+		if len(values) == 0 {
+			return 0, invalidError{fmt.Errorf("runs have no metric %q to calibrate", o.metric)}
+		}
+		var sq, size float64
+		var n int
+		for tick, want := range o.series {
+			if math.IsNaN(want) {
+				continue
+			}
+			got := values[min(tick, len(values)-1)]
+			if o.final {
+				got = values[len(values)-1]
+			}
+			sq += (got - want) * (got - want)
+			size += math.Abs(want)
+			n++
+		}
+		if n == 0 {
+			return 0, invalidError{fmt.Errorf("metric %q of the target has no values", o.metric)}
+		}
+		scale := size / float64(n)
+		if scale == 0 {
+			scale = 1
+		}
+		total += math.Sqrt(sq/float64(n)) / scale
+	}
+	return total / float64(len(target)), nil
+}
+
+// fit the -ranges of flags to an empirical -target: metrics observed over
+// time or at the end of a run. The evolution strategy of culsim optimize
+// searches for the values whose -repeats runs are closest to the target on
+// average, and the candidates within -tolerance standard errors of the best
+// fit as well give the uncertainty of each range, printed in a table. Every
+// candidate is saved in data/calibration-<run ID>.csv.
+func calibrate() error {
+	if *targetPath == "" || *sweepRanges == "" {
+		return invalidError{errors.New("a -target and -ranges are needed to calibrate")}
+	}
+	target, err := readTarget(*targetPath)
+	if err != nil {
+		return invalidError{fmt.Errorf("invalid -target %s: %w", *targetPath, err)}
+	}
+	ranges, err := parseRanges(*sweepRanges)
+	if err != nil {
+		return invalidError{fmt.Errorf("invalid -ranges: %w", err)}
+	}
+	if *samples < 2 || *generations < 1 || *repeats < 1 {
+		return invalidError{errors.New("-samples must be at least 2 and -generations and -repeats at least 1")}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	fixed := passedFlags()
+
+	// score a candidate by the mean distance of the runs that finished, the
+	// worst score if none did
+	var candidates []candidate
+	var gens []int
+	evaluate := func(gen int, p []float64) (candidate, error) {
+		c := candidate{point: p, score: math.Inf(1)}
+		var distances []float64
+		for rep := 0; rep < *repeats; rep++ {
+			result := runSample(self, sampleParams(fixed, ranges, p))
+			if result["status"] != "finished" {
+				slog.Warn("sample failed", "status", result["status"], "err", result["error"])
+				continue
+			}
+			var path string
+			paths, _ := result["outputs"].([]interface{})
+			for _, p := range paths {
+				if s, _ := p.(string); strings.HasPrefix(s, "data/log-") {
+					path = s
+				}
+			}
+			series, err := readLog(path)
+			if err != nil {
+				slog.Warn("failed reading sample metrics", "run", result["run"], "err", err)
+				continue
+			}
+			d, err := targetDistance(target, series)
+			if err != nil {
+				return c, err
+			}
+			distances = append(distances, d)
+		}
+		if c.runs = len(distances); c.runs > 0 {
+			for _, d := range distances {
+				c.value += d
+			}
+			c.value /= float64(c.runs)
+			for _, d := range distances {
+				c.err += (d - c.value) * (d - c.value)
+			}
+			if c.runs > 1 {
+				c.err = math.Sqrt(c.err / float64(c.runs-1) / float64(c.runs))
+			}
+			c.score = c.value
+		}
+		candidates, gens = append(candidates, c), append(gens, gen)
+		return c, nil
+	}
+	population, err := evolve(ranges, "distance", evaluate)
+	if err != nil {
+		return err
+	}
+	best := population[0]
+	if best.runs == 0 {
+		return errors.New("no run of any candidate finished")
+	}
+
+	path := fmt.Sprintf("data/calibration-%s.csv", runID)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	header := []string{"generation"}
+	for _, r := range ranges {
+		header = append(header, r.name)
+	}
+	_ = csvwriter.Write(append(header, "runs", "distance", "stderr", "fit"))
+	var fits []candidate
+	for i, c := range candidates {
+		fit := c.runs > 0 && c.value-best.value <= *tolerance*math.Hypot(c.err, best.err)
+		if fit {
+			fits = append(fits, c)
+		}
+		row := []string{strconv.Itoa(gens[i])}
+		for d, r := range ranges {
+			row = append(row, r.at(c.point[d]))
+		}
+		_ = csvwriter.Write(append(row, strconv.Itoa(c.runs), formatFloat(c.value), formatFloat(c.err),
+			strconv.FormatBool(fit)))
+	}
+	csvwriter.Flush()
+
+	// the spread of every range over the candidates that fit
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PARAMETER\tBEST\tMEAN\tSD\tLOW\tHIGH")
+	for d, r := range ranges {
+		values := make([]float64, len(fits))
+		var mean, sd float64
+		for i, c := range fits {
+			values[i] = r.min + c.point[d]*(r.max-r.min)
+			if r.whole {
+				values[i] = math.Round(values[i])
+			}
+			mean += values[i] / float64(len(fits))
+		}
+		for _, v := range values {
+			sd += (v - mean) * (v - mean)
+		}
+		if len(fits) > 1 {
+			sd = math.Sqrt(sd / float64(len(fits)-1))
+		}
+		sort.Float64s(values)
+		fmt.Fprintf(w, "%s\t%s\t%.4g\t%.4g\t%.4g\t%.4g\n", r.name, r.at(best.point[d]), mean, sd, values[0],
+			values[len(values)-1])
+	}
+	if err = errors.Join(csvwriter.Error(), csvfile.Close(), w.Flush()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("calibration saved", "path", path, "best", rangeValues(ranges, best.point),
+		"distance", formatFloat(best.value), "stderr", formatFloat(best.err), "fits", len(fits))
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/sausheong/culsim"
+)
+
+// count the ticks in which the culture of each cell changed, whatever
+// changed it
+func (sim *CultureSim) recordChanges(m culsim.Message) {
+	if _, ok := m.(culsim.TickCompleted); !ok {
+		return
+	}
+	cultures := sim.cultures()
+	for n, c := range cultures {
+		if c != sim.lastCultures[n] {
+			sim.changes[n]++
+		}
+	}
+	sim.lastCultures = cultures
+}
+
+// save the changes of every cell as a grid in data/changes-<name>.csv, a
+// row of the grid per line, and as a heatmap in data/changes-<name>.png
+func (sim *CultureSim) saveChanges(name string) error {
+	path := fmt.Sprintf("data/changes-%s.csv", name)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	most := 0
+	for y := 0; y < width; y++ {
+		row := make([]string, width)
+		for x := range row {
+			c := sim.changes[y*width+x]
+			row[x] = strconv.Itoa(c)
+			most = max(most, c)
+		}
+		_ = csvwriter.Write(row)
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("cell changes saved", "path", path, "most", most)
+
+	// the heatmap runs from cells that never changed to the most changed
+	colors := make([]color.RGBA, len(sim.changes))
+	for n, c := range sim.changes {
+		colors[n] = heat(float64(c) / float64(max(most, 1)))
+	}
+	legend := make([]legendEntry, 0, len(HEATMAP))
+	for i := range HEATMAP {
+		legend = append(legend, legendEntry{fmt.Sprintf("changes %.0f", float64(most)*float64(i)/float64(len(HEATMAP)-1)), HEATMAP[i], 0})
+	}
+	path = fmt.Sprintf("data/changes-%s.png", name)
+	if err = savePNG(renderImage(colors, width, *scale, legend), path); err != nil {
+		return err
+	}
+	addOutput(path)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// bars of increasing height for sparklines
+var SPARKS = []rune("▁▂▃▄▅▆▇█")
+
+// render the last n values of a data series as a sparkline scaled between its
+// smallest and largest values. The first value of a series is its name.
+func sparkline(series []string, n int) string {
+	values := series[1:]
+	if len(values) > n {
+		values = values[len(values)-n:]
+	}
+	nums := make([]float64, 0, len(values))
+	for _, v := range values {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		nums = append(nums, f)
+	}
+	if len(nums) == 0 {
+		return ""
+	}
+	min, max := nums[0], nums[0]
+	for _, f := range nums {
+		if f < min {
+			min = f
+		}
+		if f > max {
+			max = f
+		}
+	}
+	var b strings.Builder
+	for _, f := range nums {
+		i := 0
+		if max > min {
+			i = int((f - min) / (max - min) * float64(len(SPARKS)-1))
+		}
+		b.WriteRune(SPARKS[i])
+	}
+	return b.String()
+}
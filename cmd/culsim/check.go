@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/sausheong/culsim"
+)
+
+// check the invariants of the model at the end of every tick with -check
+func (sim *CultureSim) checkInvariants(m culsim.Message) {
+	if _, ok := m.(culsim.TickCompleted); ok {
+		sim.checkEngine()
+	}
+}
+
+// check the invariants of the model, stopping with the violations and a
+// checkpoint of the grid that broke them, for replaying or inspecting it
+func (sim *CultureSim) checkEngine() {
+	err := sim.engine.Check()
+	if err == nil {
+		return
+	}
+	restoreTerminal()
+	path := fmt.Sprintf("data/checkpoint-%s-t%d-invalid.json", sim.name(), sim.engine.Tick())
+	if saveErr := sim.saveCheckpoint(path); saveErr != nil {
+		slog.Error("failed saving checkpoint", "path", path, "err", saveErr)
+	} else {
+		slog.Error("checkpoint of the invalid grid saved", "path", path)
+	}
+	fail("invariants of the model violated", err)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// save the current state of the simulation as a checkpoint the run can be
+// resumed from with -resume
+func (sim *CultureSim) saveCheckpoint(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = sim.engine.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// load a checkpoint
+func loadCheckpoint(path string) (*culsim.Engine, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	engine, err := culsim.Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return engine, nil
+}
+
+// carry on the run saved in a checkpoint as it would have gone on, which
+// the flags must set the parameters of
+func resume(engine *culsim.Engine, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = engine.Restore(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// read a checkpoint as a single snapshot to replay
+func readCheckpoint(path string) (int, []snapshot, error) {
+	engine, err := loadCheckpoint(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	return engine.Width(), []snapshot{{engine.Tick(), engine.Cultures()}}, nil
+}
+
+// start from the final grid of another run, by its ID in the run index or
+// the path of a checkpoint
+func initFrom(engine *culsim.Engine, from string) error {
+	path := from
+	if !strings.HasSuffix(from, ".json") {
+		runs, err := readIndex()
+		if err != nil {
+			return err
+		}
+		path = ""
+		for _, run := range runs {
+			if run.ID != from {
+				continue
+			}
+			// the final grid, or the latest checkpoint of a run that didn't finish
+			for _, output := range run.Outputs {
+				if strings.HasPrefix(output, "data/final-") || strings.HasPrefix(output, "data/checkpoint-") {
+					path = output
+				}
+			}
+		}
+		if path == "" {
+			return fmt.Errorf("no run %s with a final grid in the run index", from)
+		}
+	}
+	previous, err := loadCheckpoint(path)
+	if err != nil {
+		return err
+	}
+	return engine.SetCultures(previous.Cultures())
+}
+
+// save the final grid of the run in data/final-<name>.json, a checkpoint
+// other runs can start from with -init-from
+func (sim *CultureSim) saveFinal(name string) error {
+	path := fmt.Sprintf("data/final-%s.json", name)
+	if err := sim.saveCheckpoint(path); err != nil {
+		return err
+	}
+	addOutput(path)
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/sausheong/culsim"
+)
+
+// spatial correlation length of cultural similarity in cells, fitting the
+// similarity of pairs of cells r cells apart along the rows and columns, in
+// excess of the similarity of unrelated cells with the same trait
+// frequencies, to an exponential decay exp(-r/length). The similarity of 2
+// cultures is the fraction of features they share. Returns NaN when the
+// similarity doesn't decay, such as when the grid has converged, and the
+// similarity of unrelated cells.
+func correlationLength(cultures []int, w, features int, torus bool) (float64, float64) {
+	h := len(cultures) / w
+	// similarity of unrelated cells, from the frequency of each trait
+	counts := make([][16]float64, features)
+	var populated float64
+	for _, c := range cultures {
+		if c == culsim.Empty {
+			continue
+		}
+		populated++
+		for f := 0; f < features; f++ {
+			counts[f][culsim.FeatureTrait(c, f)]++
+		}
+	}
+	if populated == 0 {
+		return math.NaN(), 0
+	}
+	var baseline float64
+	for f := range counts {
+		for _, n := range counts[f] {
+			baseline += (n / populated) * (n / populated) / float64(features)
+		}
+	}
+
+	// fit log(excess) = a - r/length while the excess is positive
+	var n, sr, sy, srr, sry float64
+	for r := 1; r <= min(w, h)/2; r++ {
+		var total, pairs float64
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				a := cultures[y*w+x]
+				if a == culsim.Empty {
+					continue
+				}
+				for _, d := range [][2]int{{r, 0}, {0, r}} {
+					bx, by := x+d[0], y+d[1]
+					if torus {
+						bx, by = bx%w, by%h
+					} else if bx >= w || by >= h {
+						continue
+					}
+					b := cultures[by*w+bx]
+					if b == culsim.Empty {
+						continue
+					}
+					for f := 0; f < features; f++ {
+						if culsim.FeatureTrait(a, f) == culsim.FeatureTrait(b, f) {
+							total++
+						}
+					}
+					pairs++
+				}
+			}
+		}
+		if pairs == 0 {
+			break
+		}
+		excess := total/(pairs*float64(features)) - baseline
+		if excess <= 0 {
+			break
+		}
+		y := math.Log(excess)
+		n, sr, sy, srr, sry = n+1, sr+float64(r), sy+y, srr+float64(r*r), sry+float64(r)*y
+	}
+	if n < 2 {
+		return math.NaN(), baseline
+	}
+	slope := (n*sry - sr*sy) / (n*srr - sr*sr)
+	if slope >= 0 {
+		return math.NaN(), baseline
+	}
+	return -1 / slope, baseline
+}
+
+// log the correlation length of the grid every -correlation-every ticks
+func (sim *CultureSim) recordCorrelation(m culsim.Message) {
+	t, ok := m.(culsim.TickCompleted)
+	if !ok || t.Tick%*correlationEvery != 0 {
+		return
+	}
+	params := sim.engine.Params()
+	length, baseline := correlationLength(sim.cultures(), width, params.Features, params.Topology == culsim.Torus)
+	slog.Info("correlation length", "tick", t.Tick, "length", fmt.Sprintf("%.2f", length),
+		"baseline", fmt.Sprintf("%.3f", baseline))
+	sim.correlations = append(sim.correlations, []string{strconv.Itoa(t.Tick),
+		strconv.FormatFloat(length, 'f', -1, 64), strconv.FormatFloat(baseline, 'f', -1, 64)})
+}
+
+// save the correlation lengths logged in data/correlation-<name>.csv
+func (sim *CultureSim) saveCorrelation(name string) error {
+	path := fmt.Sprintf("data/correlation-%s.csv", name)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"tick", "length", "baseline"})
+	_ = csvwriter.WriteAll(sim.correlations)
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("correlation lengths saved", "path", path)
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/sausheong/culsim"
+)
+
+// save the Hamming distances, the number of features they differ on, between
+// every pair of distinct cultures of the final grid in data/distances-<name>.csv,
+// a row and column per culture, most common first, after its cell count
+func (sim *CultureSim) saveDistances(name string) error {
+	counts := make(map[int]int)
+	for _, c := range sim.cultures() {
+		if c != culsim.Empty {
+			counts[c]++
+		}
+	}
+	cultures := make([]int, 0, len(counts))
+	for c := range counts {
+		cultures = append(cultures, c)
+	}
+	sort.Slice(cultures, func(i, j int) bool {
+		if counts[cultures[i]] != counts[cultures[j]] {
+			return counts[cultures[i]] > counts[cultures[j]]
+		}
+		return cultures[i] < cultures[j]
+	})
+
+	path := fmt.Sprintf("data/distances-%s.csv", name)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	header := []string{"culture", "count"}
+	for _, c := range cultures {
+		header = append(header, labels.Culture(c))
+	}
+	_ = csvwriter.Write(header)
+	for _, a := range cultures {
+		row := []string{labels.Culture(a), strconv.Itoa(counts[a])}
+		for _, b := range cultures {
+			row = append(row, strconv.Itoa(culsim.Features-culsim.SharedFeatures(a, b)))
+		}
+		_ = csvwriter.Write(row)
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("culture distances saved", "path", path, "cultures", len(cultures))
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+
+	"github.com/sausheong/culsim"
+)
+
+// run -replicates replicas of the simulation of the flags for -d ticks from
+// the same initial grid, each with its own seed drawn from the run's seed,
+// and save how far their grids drift apart every tick in
+// data/divergence-<run ID>.csv: the fraction of cells and of features that
+// differ between 2 replicas, averaged over every pair, with the standard
+// deviation over the pairs. How soon the divergence saturates measures how
+// sensitive the parameters are to chance alone.
+func diverge() error {
+	if *replicates < 2 {
+		return invalidError{errors.New("-replicates must be at least 2 to measure divergence")}
+	}
+	replicas := make([]*culsim.Engine, *replicates)
+	for i := range replicas {
+		engine, err := newEngine(rand.Int63())
+		if err != nil {
+			return err
+		}
+		// every replica starts from the grid of the first
+		if i > 0 {
+			if err = engine.SetCultures(replicas[0].Cultures()); err != nil {
+				return err
+			}
+		}
+		replicas[i] = engine
+	}
+	features := replicas[0].Params().Features
+
+	path := fmt.Sprintf("data/divergence-%s.csv", runID)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"tick", "differing", "divergence", "divergence_sd"})
+	var series []float64
+	for tick := replicas[0].Tick(); tick < *duration; tick++ {
+		grids := make([][]int, len(replicas))
+		for i, engine := range replicas {
+			engine.Step(context.Background())
+			grids[i] = engine.Cultures()
+		}
+		var differing, mean, sq float64
+		var pairs int
+		for i := range grids {
+			for j := i + 1; j < len(grids); j++ {
+				cells, d := divergence(grids[i], grids[j], features)
+				differing, mean, sq = differing+cells, mean+d, sq+d*d
+				pairs++
+			}
+		}
+		n := float64(pairs)
+		differing, mean = differing/n, mean/n
+		sd := math.Sqrt(math.Max(0, sq/n-mean*mean) * n / math.Max(1, n-1))
+		series = append(series, mean)
+		_ = csvwriter.Write([]string{strconv.Itoa(replicas[0].Tick()), formatFloat(differing), formatFloat(mean),
+			formatFloat(sd)})
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	if len(series) == 0 {
+		slog.Info("divergence saved", "path", path)
+		return nil
+	}
+	// the first tick the replicas were half as far apart as they end up, 0
+	// if they never parted
+	final, half := series[len(series)-1], 0
+	for i, d := range series {
+		if final > 0 && d >= final/2 {
+			half = replicas[0].Tick() - len(series) + i + 1
+			break
+		}
+	}
+	slog.Info("divergence saved", "path", path, "replicas", len(replicas), "divergence", formatFloat(final),
+		"half-time", half)
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/sausheong/culsim"
+)
+
+// a domain's size and centre, in cell units from the top left of the grid
+type domainInfo struct {
+	label, size int
+	cx, cy      float64
+}
+
+// the k largest domains with their centres. On a torus a domain can wrap
+// around the edges, so its centre is the circular mean of its cells.
+func largestDomains(labels, sizes []int, k int) []domainInfo {
+	domains := make([]domainInfo, len(sizes))
+	height := len(labels) / width
+	torus := culsim.Topology(*topology) == culsim.Torus
+	// sums of the cells' positions, or of the sines and cosines of their
+	// angles around the torus
+	sums := make([][4]float64, len(sizes))
+	for n, d := range labels {
+		if d == -1 {
+			continue
+		}
+		x, y := float64(n%width)+0.5, float64(n/width)+0.5
+		if torus {
+			ax, ay := 2*math.Pi*x/float64(width), 2*math.Pi*y/float64(height)
+			sums[d] = [4]float64{sums[d][0] + math.Cos(ax), sums[d][1] + math.Sin(ax), sums[d][2] + math.Cos(ay), sums[d][3] + math.Sin(ay)}
+		} else {
+			sums[d][0], sums[d][2] = sums[d][0]+x, sums[d][2]+y
+		}
+	}
+	for d, size := range sizes {
+		domains[d].label, domains[d].size = d, size
+		if torus {
+			domains[d].cx = wrapAngle(math.Atan2(sums[d][1], sums[d][0])) * float64(width) / (2 * math.Pi)
+			domains[d].cy = wrapAngle(math.Atan2(sums[d][3], sums[d][2])) * float64(height) / (2 * math.Pi)
+		} else {
+			domains[d].cx = sums[d][0] / float64(size)
+			domains[d].cy = sums[d][2] / float64(size)
+		}
+	}
+	sort.SliceStable(domains, func(i, j int) bool { return domains[i].size > domains[j].size })
+	if len(domains) > k {
+		domains = domains[:k]
+	}
+	return domains
+}
+
+// angle in [0, 2π)
+func wrapAngle(a float64) float64 {
+	if a < 0 {
+		return a + 2*math.Pi
+	}
+	return a
+}
+
+// Gini coefficient of domain sizes, 0 when all domains are the same size
+// and approaching 1 when one domain has nearly all the cells, and the ratio
+// of the largest size to the median
+func sizeInequality(sizes []int) (float64, float64) {
+	if len(sizes) == 0 {
+		return 0, 0
+	}
+	sorted := append([]int(nil), sizes...)
+	sort.Ints(sorted)
+	// G = sum((2i - n - 1) x_i) / (n sum(x_i)) for sizes sorted ascending,
+	// i from 1
+	var weighted, total float64
+	n := len(sorted)
+	for i, s := range sorted {
+		weighted += float64(2*(i+1)-n-1) * float64(s)
+		total += float64(s)
+	}
+	median := float64(sorted[n/2])
+	if n%2 == 0 {
+		median = float64(sorted[n/2-1]+sorted[n/2]) / 2
+	}
+	return weighted / (float64(n) * total), float64(sorted[n-1]) / median
+}
+
+// log the number of domains and the inequality of their sizes every
+// -domain-every ticks
+func (sim *CultureSim) recordDomains(m culsim.Message) {
+	t, ok := m.(culsim.TickCompleted)
+	if !ok || t.Tick%*domainEvery != 0 {
+		return
+	}
+	cultures := sim.cultures()
+	domains, sizes := culsim.Domains(cultures, width, culsim.Topology(*topology))
+	gini, ratio := sizeInequality(sizes)
+	slog.Info("domain sizes", "tick", t.Tick, "domains", len(sizes), "gini", fmt.Sprintf("%.3f", gini),
+		"max-median", fmt.Sprintf("%.1f", ratio))
+	sim.domainRows = append(sim.domainRows, []string{strconv.Itoa(t.Tick), strconv.Itoa(len(sizes)),
+		strconv.FormatFloat(gini, 'f', -1, 64), strconv.FormatFloat(ratio, 'f', -1, 64)})
+	if !*domainTable && !*domainEvents {
+		return
+	}
+	events := len(sim.domains.events)
+	ids := sim.domains.follow(t.Tick, cultures, domains, sizes)
+	if *domainTable {
+		sim.appendDomainTable(t.Tick, domains, sizes, ids)
+	}
+	if *domainEvents {
+		counts := make(map[string]int)
+		for _, row := range sim.domains.events[events:] {
+			counts[row[1]]++
+		}
+		slog.Info("domain events", "tick", t.Tick, "births", counts["birth"], "merges", counts["merge"],
+			"splits", counts["split"], "extinctions", counts["extinction"])
+	}
+}
+
+// save the domain sizes logged in data/domains-<name>.csv
+func (sim *CultureSim) saveDomains(name string) error {
+	path := fmt.Sprintf("data/domains-%s.csv", name)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"tick", "domains", "gini", "max_median"})
+	_ = csvwriter.WriteAll(sim.domainRows)
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("domain sizes saved", "path", path)
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// domains followed from sample to sample, so each keeps its ID while it
+// lasts
+type domainTracker struct {
+	ids      []int      // ID of the domain of each cell in the latest sample, -1 if empty
+	cultures []int      // culture of each domain by ID
+	formed   []int      // tick each domain by ID formed, or the run started
+	rows     [][]string // domains of every sample: tick, ID, size, centroid, perimeter, age, culture and its traits
+	events   [][]string // births, merges, splits and extinctions: tick, event, ID, size and the domains involved
+}
+
+// label the domains of a grid at a tick with the IDs of the domains of the
+// previous sample. A domain takes the ID of the domain of the same culture
+// it overlaps most, unless another domain overlaps that one more, and a new
+// ID otherwise, formed at the tick. What happened to the domains since the
+// previous sample is added to the events.
+func (dt *domainTracker) follow(tick int, cultures, labels, sizes []int) []int {
+	// cells each domain shares with the previous domains of its culture
+	overlaps := make([]map[int]int, len(sizes))
+	for n, d := range labels {
+		if d == -1 || dt.ids == nil || dt.ids[n] == -1 || dt.cultures[dt.ids[n]] != cultures[n] {
+			continue
+		}
+		if overlaps[d] == nil {
+			overlaps[d] = make(map[int]int)
+		}
+		overlaps[d][dt.ids[n]]++
+	}
+	best, claimed := make([]int, len(sizes)), make(map[int]int)
+	for d := range sizes {
+		best[d] = -1
+		for id, cells := range overlaps[d] {
+			if best[d] == -1 || cells > overlaps[d][best[d]] || cells == overlaps[d][best[d]] && id < best[d] {
+				best[d] = id
+			}
+		}
+		if id := best[d]; id != -1 {
+			if other, ok := claimed[id]; !ok || overlaps[d][id] > overlaps[other][id] {
+				claimed[id] = d
+			}
+		}
+	}
+	ids := make([]int, len(sizes))
+	for d := range sizes {
+		if id := best[d]; id != -1 && claimed[id] == d {
+			ids[d] = id
+			continue
+		}
+		ids[d] = len(dt.cultures)
+		dt.cultures = append(dt.cultures, culsim.Empty)
+		dt.formed = append(dt.formed, tick)
+	}
+	if dt.ids != nil {
+		dt.addEvents(tick, dt.ids, ids, overlaps, sizes)
+	}
+	dt.ids = make([]int, len(labels))
+	for n, d := range labels {
+		dt.ids[n] = -1
+		if d != -1 {
+			dt.ids[n] = ids[d]
+			dt.cultures[ids[d]] = cultures[n]
+		}
+	}
+	return ids
+}
+
+// add the events between the previous sample, with the IDs of each cell,
+// and the domains of a grid at a tick, with their IDs and the cells they
+// share with the previous domains of their culture. A domain is born when it
+// shares no cells with them and merges the ones it shares cells with if
+// there are more than one. A previous domain splits when more than one
+// domain shares its cells, and dies out when none does.
+func (dt *domainTracker) addEvents(tick int, previous, ids []int, overlaps []map[int]int, sizes []int) {
+	size := make(map[int]int)
+	parts := make(map[int][]int)
+	for d, id := range ids {
+		size[id] = sizes[d]
+		for old := range overlaps[d] {
+			parts[old] = append(parts[old], id)
+		}
+	}
+	event := func(name string, id int, others []int) {
+		sort.Ints(others)
+		list := make([]string, len(others))
+		for i, o := range others {
+			list[i] = strconv.Itoa(o)
+		}
+		dt.events = append(dt.events, []string{strconv.Itoa(tick), name, strconv.Itoa(id), strconv.Itoa(size[id]),
+			strings.Join(list, " ")})
+	}
+	for d, id := range ids {
+		if len(overlaps[d]) == 0 {
+			event("birth", id, nil)
+		}
+	}
+	for d, id := range ids {
+		if len(overlaps[d]) > 1 {
+			var merged []int
+			for old := range overlaps[d] {
+				if old != id {
+					merged = append(merged, old)
+				}
+			}
+			event("merge", id, merged)
+		}
+	}
+	// previous domains, and the cells of the ones that died out
+	var old []int
+	before := make(map[int]int)
+	for _, id := range previous {
+		if id != -1 {
+			if before[id] == 0 {
+				old = append(old, id)
+			}
+			before[id]++
+		}
+	}
+	sort.Ints(old)
+	for _, id := range old {
+		if len(parts[id]) > 1 {
+			var split []int
+			for _, part := range parts[id] {
+				if part != id {
+					split = append(split, part)
+				}
+			}
+			event("split", id, split)
+		}
+	}
+	for _, id := range old {
+		if len(parts[id]) == 0 {
+			size[id] = before[id]
+			event("extinction", id, nil)
+		}
+	}
+}
+
+// cell sides of each domain that face a cell outside it, empty or not,
+// without the edges of a grid that doesn't wrap around
+func perimeters(labels []int, domains int, torus bool) []int {
+	h := len(labels) / width
+	sides := make([]int, domains)
+	for n, d := range labels {
+		if d == -1 {
+			continue
+		}
+		x, y := coords(n)
+		for _, s := range [][2]int{{0, -1}, {0, 1}, {-1, 0}, {1, 0}} {
+			nx, ny := x+s[0], y+s[1]
+			if torus {
+				nx, ny = (nx+width)%width, (ny+h)%h
+			} else if nx < 0 || nx >= width || ny < 0 || ny >= h {
+				continue
+			}
+			if labels[ny*width+nx] != d {
+				sides[d]++
+			}
+		}
+	}
+	return sides
+}
+
+// add a row for every domain of the grid at a tick, with their IDs, to the
+// domain table
+func (sim *CultureSim) appendDomainTable(tick int, domains, sizes, ids []int) {
+	dt := &sim.domains
+	sides := perimeters(domains, len(sizes), culsim.Topology(*topology) == culsim.Torus)
+	for _, info := range largestDomains(domains, sizes, len(sizes)) {
+		id, c := ids[info.label], dt.cultures[ids[info.label]]
+		row := []string{strconv.Itoa(tick), strconv.Itoa(id), strconv.Itoa(info.size), formatFloat(info.cx),
+			formatFloat(info.cy), strconv.Itoa(sides[info.label]), strconv.Itoa(tick - dt.formed[id]),
+			labels.Culture(c)}
+		dt.rows = append(dt.rows, append(row, traitValues(c)...))
+	}
+}
+
+// save the domains of every sample in data/domain-table-<name>.csv
+func (sim *CultureSim) saveDomainTable(name string) error {
+	path := fmt.Sprintf("data/domain-table-%s.csv", name)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	header := []string{"tick", "id", "size", "x", "y", "perimeter", "age", "culture"}
+	_ = csvwriter.Write(append(header, traitColumns("trait")...))
+	_ = csvwriter.WriteAll(sim.domains.rows)
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("domain table saved", "path", path, "domains", len(sim.domains.cultures))
+	return nil
+}
+
+// save the domain events of the run in data/domain-events-<name>.csv
+func (sim *CultureSim) saveDomainEvents(name string) error {
+	path := fmt.Sprintf("data/domain-events-%s.csv", name)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"tick", "event", "id", "size", "domains"})
+	_ = csvwriter.WriteAll(sim.domains.events)
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("domain events saved", "path", path, "events", len(sim.domains.events))
+	return nil
+}
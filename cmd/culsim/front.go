@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/sausheong/culsim"
+)
+
+// positions of the cultural frontier of a run over time
+type front struct {
+	culture   int        // culture whose region the radial front bounds
+	ticks     []int      // ticks the front was measured at
+	positions []float64  // position of the front at each tick
+	rows      [][]string // positions: tick and position
+}
+
+// radius of the region of a culture, that of a disc of as many cells as
+// have the culture
+func frontRadius(cultures []int, culture int) float64 {
+	var count int
+	for _, c := range cultures {
+		if c == culture {
+			count++
+		}
+	}
+	return math.Sqrt(float64(count) / math.Pi)
+}
+
+// column where the left bloc of a grid gives way to the right one, averaged
+// over the rows: the cells of each row that share more features with the
+// most common culture of the left edge than with that of the right edge,
+// with the ones sharing as many counting half
+func frontColumn(cultures []int, features int) float64 {
+	h := len(cultures) / width
+	edge := func(x int) int {
+		counts := make(map[int]int)
+		common := culsim.Empty
+		for y := 0; y < h; y++ {
+			c := cultures[y*width+x]
+			if counts[c]++; c != culsim.Empty && (common == culsim.Empty || counts[c] > counts[common]) {
+				common = c
+			}
+		}
+		return common
+	}
+	left, right := edge(0), edge(width-1)
+	shared := func(a, b int) int {
+		if b == culsim.Empty {
+			return -1
+		}
+		var n int
+		for f := 0; f < features; f++ {
+			if culsim.FeatureTrait(a, f) == culsim.FeatureTrait(b, f) {
+				n++
+			}
+		}
+		return n
+	}
+	var column float64
+	for _, c := range cultures {
+		if c == culsim.Empty {
+			continue
+		}
+		if l, r := shared(c, left), shared(c, right); l > r {
+			column++
+		} else if l == r {
+			column += 0.5
+		}
+	}
+	return column / float64(h)
+}
+
+// speed of a front in cells per tick, the least-squares slope of its
+// position over the ticks from the first to the last in which it moved, NaN
+// if it never did
+func frontSpeed(ticks []int, positions []float64) float64 {
+	first, last := -1, -1
+	for i := 1; i < len(positions); i++ {
+		if positions[i] != positions[i-1] {
+			if first == -1 {
+				first = i - 1
+			}
+			last = i
+		}
+	}
+	if first == -1 {
+		return math.NaN()
+	}
+	var n, st, sp, stt, stp float64
+	for i := first; i <= last; i++ {
+		t, p := float64(ticks[i]), positions[i]
+		n, st, sp, stt, stp = n+1, st+t, sp+p, stt+t*t, stp+t*p
+	}
+	return (n*stp - st*sp) / (n*stt - st*st)
+}
+
+// measure the position of the -front at a tick
+func (sim *CultureSim) measureFront(tick int) {
+	fr := &sim.front
+	var position float64
+	if *frontShape == "radial" {
+		position = frontRadius(sim.cultures(), fr.culture)
+	} else {
+		position = frontColumn(sim.cultures(), sim.engine.Params().Features)
+	}
+	fr.ticks, fr.positions = append(fr.ticks, tick), append(fr.positions, position)
+	fr.rows = append(fr.rows, []string{strconv.Itoa(tick), formatFloat(position)})
+}
+
+// measure the position of the -front at the end of every tick
+func (sim *CultureSim) recordFront(m culsim.Message) {
+	if t, ok := m.(culsim.TickCompleted); ok {
+		sim.measureFront(t.Tick)
+	}
+}
+
+// save the positions of the front in data/front-<name>.csv and log its
+// speed
+func (sim *CultureSim) saveFront(name string) error {
+	path := fmt.Sprintf("data/front-%s.csv", name)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"tick", "position"})
+	_ = csvwriter.WriteAll(sim.front.rows)
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("front saved", "path", path, "front", *frontShape,
+		"speed", fmt.Sprintf("%.4f", frontSpeed(sim.front.ticks, sim.front.positions)))
+	return nil
+}
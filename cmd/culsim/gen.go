@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// a parameter of a scenario culsim gen writes, with its default
+type genParam struct {
+	name  string
+	value float64
+	about string
+}
+
+// a standard experiment culsim gen writes the config of, from its
+// parameters by name
+type archetype struct {
+	about  string
+	params []genParam
+	config func(p map[string]float64) (*culsim.Config, error)
+}
+
+var archetypes = map[string]archetype{
+	"invasion": {
+		about: "a block of a single random culture in the centre of the grid, which keeps its culture with probability retention",
+		params: []genParam{
+			{"size", 0, "width of the block, a fifth of the grid if 0"},
+			{"retention", 0, "probability that an invader cell keeps its culture when it would change"},
+			{"media", 0, "probability per tick that the invader culture influences every cell sharing half its features"},
+		},
+		config: func(p map[string]float64) (*culsim.Config, error) {
+			size := int(p["size"])
+			if size == 0 {
+				size = max(1, width/5)
+			}
+			if size < 0 || size > width {
+				return nil, fmt.Errorf("size must be between 1 and the grid width %d", width)
+			}
+			return &culsim.Config{Minority: &culsim.Minority{
+				Culture:   rand.Intn(culsim.Empty),
+				Region:    culsim.Region{X: (width - size) / 2, Y: (width - size) / 2, W: size, H: size},
+				Retention: p["retention"],
+				Media:     p["media"],
+			}}, nil
+		},
+	},
+	"two-bloc": {
+		about: "the left and right halves of the grid held at 2 random cultures differing in some features until they come into contact",
+		params: []genParam{
+			{"contact", 100, "tick the blocs come into contact"},
+			{"differ", 3, "features the cultures of the blocs differ in"},
+		},
+		config: func(p map[string]float64) (*culsim.Config, error) {
+			contact, differ := int(p["contact"]), int(p["differ"])
+			if contact < 1 {
+				return nil, errors.New("contact must be at least 1")
+			}
+			if differ < 0 || differ > culsim.Features {
+				return nil, fmt.Errorf("differ must be between 0 and %d", culsim.Features)
+			}
+			left := rand.Intn(culsim.Empty)
+			right := left
+			for _, f := range rand.Perm(culsim.Features)[:differ] {
+				trait := (culsim.FeatureTrait(left, f) + 1 + rand.Intn(0xF)) & 0xF
+				right = right&^(0xF<<(4*f)) | trait<<(4*f)
+			}
+			// policies that keep every cell of each half at its bloc's
+			// culture until the contact
+			cfg := &culsim.Config{}
+			for _, bloc := range []struct {
+				region  culsim.Region
+				culture int
+			}{
+				{culsim.Region{W: width / 2, H: width}, left},
+				{culsim.Region{X: width / 2, W: width - width/2, H: width}, right},
+			} {
+				for f := 0; f < culsim.Features; f++ {
+					cfg.Events = append(cfg.Events, culsim.Event{Type: "policy", End: contact - 1, Region: bloc.region,
+						Feature: f, Trait: culsim.FeatureTrait(bloc.culture, f), Rate: 1})
+				}
+			}
+			return cfg, nil
+		},
+	},
+	"media-shock": {
+		about: "a media campaign making every cell of the grid adopt a trait with probability rate every tick for a while",
+		params: []genParam{
+			{"tick", 100, "tick the campaign starts"},
+			{"duration", 50, "ticks the campaign lasts"},
+			{"rate", 0.05, "probability per tick that a cell adopts the trait"},
+			{"feature", 0, "feature of the trait"},
+			{"trait", 0, "trait adopted"},
+		},
+		config: func(p map[string]float64) (*culsim.Config, error) {
+			if p["duration"] < 1 {
+				return nil, errors.New("duration must be at least 1")
+			}
+			tick := int(p["tick"])
+			return &culsim.Config{Events: []culsim.Event{{Type: "policy", Tick: tick, End: tick + int(p["duration"]) - 1,
+				Region: culsim.Region{W: width, H: width}, Feature: int(p["feature"]), Trait: int(p["trait"]),
+				Rate: p["rate"]}}}, nil
+		},
+	},
+	"noise-quench": {
+		about: "noise at a high rate that drops suddenly to a low rate",
+		params: []genParam{
+			{"high", 0.05, "noise rate before the quench"},
+			{"low", 0, "noise rate from the quench"},
+			{"tick", 100, "tick of the quench"},
+		},
+		config: func(p map[string]float64) (*culsim.Config, error) {
+			if p["tick"] < 1 {
+				return nil, errors.New("tick must be at least 1")
+			}
+			return &culsim.Config{Noise: &culsim.NoiseSchedule{Schedule: "step", Points: []culsim.NoisePoint{
+				{Tick: 0, Rate: p["high"]}, {Tick: int(p["tick"]), Rate: p["low"]}}}}, nil
+		},
+	},
+}
+
+// usage of culsim gen, listing the archetypes and their parameters
+func genUsage() string {
+	names := make([]string, 0, len(archetypes))
+	for name := range archetypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("usage: culsim gen <archetype> [flags] [name=value]...\n")
+	for _, name := range names {
+		a := archetypes[name]
+		fmt.Fprintf(&b, "\n%s: %s\n", name, a.about)
+		for _, p := range a.params {
+			fmt.Fprintf(&b, "  %s=%s  %s\n", p.name, formatFloat(p.value), p.about)
+		}
+	}
+	return b.String()
+}
+
+// write the config of a standard experiment for a grid of -w to
+// data/scenario-<archetype>-<run ID>.json, with the defaults of its
+// parameters replaced by the name=value arguments, and log how to run it
+// with the other flags of the command line
+func generate(name string, args []string) error {
+	a, ok := archetypes[name]
+	if !ok {
+		return invalidError{fmt.Errorf("unknown archetype %q, culsim gen lists them", name)}
+	}
+	p := make(map[string]float64)
+	for _, param := range a.params {
+		p[param.name] = param.value
+	}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if _, known := p[key]; !ok || !known {
+			return invalidError{fmt.Errorf("%s has no parameter %q, culsim gen lists them", name, arg)}
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return invalidError{fmt.Errorf("parameter %s: %w", key, err)}
+		}
+		p[key] = v
+	}
+	cfg, err := a.config(p)
+	if err != nil {
+		return invalidError{fmt.Errorf("%s: %w", name, err)}
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("data/scenario-%s-%s.json", name, runID)
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	// the scenario must load like any config
+	if _, err = culsim.LoadConfig(path); err != nil {
+		os.Remove(path)
+		return invalidError{err}
+	}
+	addOutput(path)
+	command := []string{"culsim", "-config", path}
+	fixed := passedFlags()
+	flags := make([]string, 0, len(fixed))
+	for f := range fixed {
+		flags = append(flags, f)
+	}
+	sort.Strings(flags)
+	for _, f := range flags {
+		command = append(command, fmt.Sprintf("-%s=%s", f, fixed[f]))
+	}
+	slog.Info("scenario saved", "path", path, "run", strings.Join(command, " "))
+	return nil
+}
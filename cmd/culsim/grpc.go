@@ -0,0 +1,176 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package main
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative culsimpb/culsim.proto
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sausheong/culsim"
+	"github.com/sausheong/culsim/culsimpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// the simulation service, which drives one simulation at a time
+type simService struct {
+	culsimpb.UnimplementedSimulationServer
+	sync.Mutex
+	sim      *CultureSim
+	defaults map[string]string // flags the server was started with
+}
+
+// serve the simulation service on addr until the program ends
+func serveGRPC(addr string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fatal("failed listening", "addr", addr, "err", err)
+	}
+	service := &simService{defaults: make(map[string]string)}
+	flag.VisitAll(func(f *flag.Flag) {
+		service.defaults[f.Name] = f.Value.String()
+	})
+	server := grpc.NewServer()
+	culsimpb.RegisterSimulationServer(server, service)
+	slog.Info("simulation service listening", "addr", listener.Addr().String())
+	if err = server.Serve(listener); err != nil {
+		fatal("simulation service stopped", "err", err)
+	}
+}
+
+func (s *simService) CreateSim(ctx context.Context, req *culsimpb.CreateSimRequest) (*culsimpb.CreateSimResponse, error) {
+	s.Lock()
+	defer s.Unlock()
+	for name, value := range s.defaults {
+		_ = flag.Set(name, value)
+	}
+	for name, value := range req.Params {
+		if name == "grpc" || name == "w" {
+			return nil, status.Errorf(codes.InvalidArgument, "flag %q cannot be changed by a client", name)
+		}
+		if err := flag.Set(name, value); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid flag %q: %s", name, err)
+		}
+	}
+	// every simulation of the service has its own seed, the client's or one
+	// drawn from the service's, and names its files after its own ID
+	seed = req.Seed
+	if _, ok := req.Params["seed"]; ok && seed == 0 {
+		seed = *seedFlag
+	}
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	runID = newRunID(time.Now())
+	// reject bad settings rather than letting Init stop the server
+	engine, err := newEngine(seed)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed initialising simulation: %s", err)
+	}
+	if s.sim != nil {
+		s.sim.closeSnapshots()
+	}
+	sim := &CultureSim{}
+	if err = sim.start(engine); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed starting simulation: %s", err)
+	}
+	s.sim = sim
+	return &culsimpb.CreateSimResponse{Name: s.sim.name(), Width: int32(width)}, nil
+}
+
+func (s *simService) Step(ctx context.Context, req *culsimpb.StepRequest) (*culsimpb.Metrics, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.sim == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no simulation, call CreateSim first")
+	}
+	ticks := int(req.Ticks)
+	if ticks < 1 {
+		ticks = 1
+	}
+	for i := 0; i < ticks; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		s.sim.step()
+	}
+	return s.sim.metricsMessage(), nil
+}
+
+func (s *simService) GetState(ctx context.Context, req *culsimpb.GetStateRequest) (*culsimpb.State, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.sim == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no simulation, call CreateSim first")
+	}
+	cultures := s.sim.cultures()
+	state := &culsimpb.State{Tick: int32(s.sim.engine.Tick()), Width: int32(width), Cultures: make([]uint32, len(cultures))}
+	for i, c := range cultures {
+		state.Cultures[i] = uint32(c)
+	}
+	return state, nil
+}
+
+func (s *simService) GetMetrics(ctx context.Context, req *culsimpb.GetMetricsRequest) (*culsimpb.Metrics, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.sim == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no simulation, call CreateSim first")
+	}
+	return s.sim.metricsMessage(), nil
+}
+
+func (s *simService) Snapshot(ctx context.Context, req *culsimpb.SnapshotRequest) (*culsimpb.SnapshotResponse, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.sim == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no simulation, call CreateSim first")
+	}
+	path := fmt.Sprintf("data/checkpoint-%s-t%d.json", s.sim.name(), s.sim.engine.Tick())
+	if err := s.sim.saveCheckpoint(path); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed saving checkpoint: %s", err)
+	}
+	return &culsimpb.SnapshotResponse{Path: path}, nil
+}
+
+func (s *simService) Intervene(ctx context.Context, req *culsimpb.InterveneRequest) (*culsimpb.InterveneResponse, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.sim == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no simulation, call CreateSim first")
+	}
+	engine := s.sim.engine
+	iv := culsim.Intervention{Type: req.Type, Cell: int(req.Cell), Culture: int(req.Culture), Rate: req.Rate}
+	if req.Type == "media" {
+		// a policy event over the whole grid
+		iv = culsim.Intervention{Type: "event", Event: &culsim.Event{Type: "policy", Tick: engine.Tick() + 1,
+			End: int(req.End), Region: culsim.Region{W: engine.Width(), H: engine.Height()},
+			Feature: int(req.Feature), Trait: int(req.Trait), Rate: req.Rate}}
+	}
+	if err := engine.Intervene(iv); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid intervention: %s", err)
+	}
+	return &culsimpb.InterveneResponse{Tick: int32(engine.Tick())}, nil
+}
+
+// metrics of the latest tick as a service message
+func (sim *CultureSim) metricsMessage() *culsimpb.Metrics {
+	stats := sim.engine.Stats()
+	return &culsimpb.Metrics{
+		Tick:     int32(sim.engine.Tick()),
+		Distance: int32(stats.Distance),
+		Changes:  int32(stats.Exchanges),
+		Unique:   int32(stats.Unique),
+		Conquest: int32(stats.Conquered),
+	}
+}
@@ -0,0 +1,49 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package main
+
+import (
+	"context"
+	"flag"
+	"slices"
+	"testing"
+
+	"github.com/sausheong/culsim/culsimpb"
+)
+
+// the grid of a simulation created by the service after some ticks
+func serviceGrid(t *testing.T, s *simService, req *culsimpb.CreateSimRequest) []uint32 {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := s.CreateSim(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Step(ctx, &culsimpb.StepRequest{Ticks: 20}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := s.GetState(ctx, &culsimpb.GetStateRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state.Cultures
+}
+
+// every simulation runs with the seed of its request, or a fresh one
+func TestCreateSimSeed(t *testing.T) {
+	s := &simService{defaults: make(map[string]string)}
+	flag.VisitAll(func(f *flag.Flag) { s.defaults[f.Name] = f.Value.String() })
+	first := serviceGrid(t, s, &culsimpb.CreateSimRequest{Seed: 1})
+	if again := serviceGrid(t, s, &culsimpb.CreateSimRequest{Seed: 1}); !slices.Equal(first, again) {
+		t.Error("simulations with the same seed differ")
+	}
+	if other := serviceGrid(t, s, &culsimpb.CreateSimRequest{Seed: 2}); slices.Equal(first, other) {
+		t.Error("simulations with seeds 1 and 2 are the same")
+	}
+	if param := serviceGrid(t, s, &culsimpb.CreateSimRequest{Params: map[string]string{"seed": "2"}}); slices.Equal(first, param) {
+		t.Error("simulation with seed 2 in its params is the same as with seed 1")
+	}
+	if fresh, again := serviceGrid(t, s, &culsimpb.CreateSimRequest{}), serviceGrid(t, s, &culsimpb.CreateSimRequest{}); slices.Equal(fresh, again) {
+		t.Error("simulations without a seed share one")
+	}
+}
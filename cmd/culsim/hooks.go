@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Go callbacks run when the run ends, after its data is saved and uploaded,
+// with the summary of the run. Their errors are logged, they don't change
+// how the run ended.
+var exitHooks []func(s summary) error
+
+// run f when the run ends, after the data is saved
+func onExit(f func(s summary) error) {
+	exitHooks = append(exitHooks, f)
+}
+
+// run the exit hooks and the -after command, in that order
+func runExitHooks(s summary) {
+	hooks := exitHooks
+	if *afterCmd != "" {
+		hooks = append(hooks[:len(hooks):len(hooks)], afterCommand)
+	}
+	for _, f := range hooks {
+		if err := f(s); err != nil {
+			slog.Error("exit hook failed", "err", err)
+		}
+	}
+}
+
+// run the -after shell command with the files saved by the run as its
+// arguments, and the run's ID, status, files and summary in CULSIM_RUN_ID,
+// CULSIM_STATUS, CULSIM_OUTPUTS, a line per file, and CULSIM_SUMMARY. Its
+// output goes to stderr, which stdout sinks leave free.
+func afterCommand(s summary) error {
+	files := outputFiles()
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", append([]string{"-c", *afterCmd, "culsim"}, files...)...)
+	cmd.Env = append(os.Environ(),
+		"CULSIM_RUN_ID="+s.ID,
+		"CULSIM_STATUS="+s.Status,
+		"CULSIM_OUTPUTS="+strings.Join(files, "\n"),
+		"CULSIM_SUMMARY="+string(body),
+	)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	slog.Info("running after command", "command", *afterCmd)
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", *afterCmd, err)
+	}
+	return nil
+}
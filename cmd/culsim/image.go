@@ -0,0 +1,101 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/sausheong/culsim"
+)
+
+// width in pixels of the legend drawn to the right of a grid image
+const legendWidth = 160
+
+// draw a grid of colours w cells wide, each cell scale pixels square, with
+// the legend to its right
+func renderImage(colors []color.RGBA, w, scale int, legend []legendEntry) *image.RGBA {
+	h := len(colors) / w
+	imgWidth := w * scale
+	if len(legend) > 0 {
+		imgWidth += legendWidth
+	}
+	imgHeight := h * scale
+	if lh := 20 * (len(legend) + 1); imgHeight < lh && len(legend) > 0 {
+		imgHeight = lh
+	}
+	img := image.NewRGBA(image.Rect(0, 0, imgWidth, imgHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{white}, image.Point{}, draw.Src)
+	for n, c := range colors {
+		x, y := n%w, n/w
+		rect := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale)
+		draw.Draw(img, rect, &image.Uniform{c}, image.Point{}, draw.Src)
+	}
+
+	drawer := &font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+	left := w*scale + 10
+	for i, e := range legend {
+		top := 10 + i*20
+		draw.Draw(img, image.Rect(left, top, left+14, top+14), &image.Uniform{e.color}, image.Point{}, draw.Src)
+		drawer.Dot = fixed.P(left+20, top+11)
+		drawer.DrawString(e.String())
+	}
+	return img
+}
+
+// render a grid of cultures as an image in the current view, with the domain
+// overlay if outlines are on
+func gridImage(cultures []int) *image.RGBA {
+	colors, legend := renderView(cultures)
+	img := renderImage(colors, width, *scale, legend)
+	if *outline {
+		labels, sizes := culsim.Domains(cultures, width, culsim.Topology(*topology))
+		overlayDomains(img, labels, sizes, width, *scale)
+	}
+	return img
+}
+
+// outline the domains on a grid image and label the largest ones with their
+// size at their centre
+func overlayDomains(img *image.RGBA, labels, sizes []int, w, scale int) {
+	h := len(labels) / w
+	for n := range labels {
+		x, y := n%w, n/w
+		if x+1 < w && labels[n] != labels[n+1] {
+			draw.Draw(img, image.Rect((x+1)*scale-1, y*scale, (x+1)*scale+1, (y+1)*scale), image.Black, image.Point{}, draw.Src)
+		}
+		if y+1 < h && labels[n] != labels[n+w] {
+			draw.Draw(img, image.Rect(x*scale, (y+1)*scale-1, (x+1)*scale, (y+1)*scale+1), image.Black, image.Point{}, draw.Src)
+		}
+	}
+	drawer := &font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+	for _, d := range largestDomains(labels, sizes, *labelDomains) {
+		label := strconv.Itoa(d.size)
+		cx, cy := int(d.cx*float64(scale)), int(d.cy*float64(scale))
+		// a white box behind the label keeps it readable on any colour
+		box := image.Rect(cx-len(label)*7/2-1, cy-7, cx+len(label)*7/2+1, cy+6)
+		draw.Draw(img, box, &image.Uniform{white}, image.Point{}, draw.Src)
+		drawer.Dot = fixed.P(box.Min.X+1, cy+4)
+		drawer.DrawString(label)
+	}
+}
+
+// save an image as a PNG file, creating its directory if needed
+func savePNG(img image.Image, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, img)
+}
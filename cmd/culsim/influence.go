@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/sausheong/culsim"
+)
+
+// count every exchange by the cell copied from and the cell that copied
+func (sim *CultureSim) recordInfluences(m culsim.Message) {
+	if x, ok := m.(culsim.ExchangeHappened); ok {
+		sim.influenced[[2]int{x.Src, x.Dst}]++
+	}
+}
+
+// save the influences counted over the run as a sparse matrix in the Matrix
+// Market format of data/influence-<name>.mtx, which scipy.io.mmread, R's
+// Matrix::readMM and Julia's MatrixMarket read. Entry i,j is the number of
+// exchanges in which i influenced j, between cells numbered row by row from
+// 1, or with -influence domains between the domains of the final grid,
+// leaving out cells that ended up empty.
+func (sim *CultureSim) saveInfluences(name string) error {
+	path := fmt.Sprintf("data/influence-%s.mtx", name)
+	size, index, what := width*width, func(n int) int { return n }, "cells, numbered row by row"
+	if *influence == "domains" {
+		labels, sizes := culsim.Domains(sim.cultures(), width, culsim.Topology(*topology))
+		size, index, what = len(sizes), func(n int) int { return labels[n] }, "domains of the final grid, numbered in the order of their first cell"
+	}
+	counts := make(map[[2]int]int)
+	for pair, c := range sim.influenced {
+		if i, j := index(pair[0]), index(pair[1]); i >= 0 && j >= 0 {
+			counts[[2]int{i, j}] += c
+		}
+	}
+	entries := make([][2]int, 0, len(counts))
+	for pair := range counts {
+		entries = append(entries, pair)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a][0] != entries[b][0] {
+			return entries[a][0] < entries[b][0]
+		}
+		return entries[a][1] < entries[b][1]
+	})
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	fmt.Fprintln(w, "%%MatrixMarket matrix coordinate integer general")
+	fmt.Fprintf(w, "%% exchanges in which the row influenced the column, between %s from 1\n", what)
+	fmt.Fprintf(w, "%d %d %d\n", size, size, len(entries))
+	for _, pair := range entries {
+		fmt.Fprintf(w, "%d %d %d\n", pair[0]+1, pair[1]+1, counts[pair])
+	}
+	if err = errors.Join(w.Flush(), file.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("influence matrix saved", "path", path, "entries", len(entries))
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// number of recent changes kept for each cell
+const historySize = 8
+
+// a change of a cell's culture
+type cellChange struct {
+	Tick int    `json:"tick"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// a neighbour of an inspected cell and its similarity to the cell
+type neighbourInfo struct {
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Culture string `json:"culture"`
+	Shared  int    `json:"shared"`
+}
+
+// everything the inspector shows about a cell
+type cellInfo struct {
+	X          int             `json:"x"`
+	Y          int             `json:"y"`
+	Culture    string          `json:"culture"`
+	Traits     []int           `json:"traits"`
+	Neighbours []neighbourInfo `json:"neighbours"`
+	History    []cellChange    `json:"history"`
+}
+
+// recent changes of every cell, and the cultures they were compared to
+var history [][]cellChange
+var previousCultures []int
+
+// the inspected cell and whether the inspector is shown in the terminal
+var cursor int
+var inspecting bool
+
+// record the cells whose cultures changed since the last tick
+func recordHistory(tick int, cultures []int) {
+	if len(history) != len(cultures) {
+		history, previousCultures = make([][]cellChange, len(cultures)), nil
+	}
+	for n, c := range cultures {
+		if previousCultures != nil && previousCultures[n] != c {
+			change := cellChange{tick, labels.Culture(previousCultures[n]), labels.Culture(c)}
+			history[n] = append(history[n], change)
+			if len(history[n]) > historySize {
+				history[n] = history[n][1:]
+			}
+		}
+	}
+	previousCultures = cultures
+}
+
+// describe the cell at n in a grid of cultures
+func inspectCell(cultures []int, n int) cellInfo {
+	x, y := coords(n)
+	c := cultures[n]
+	info := cellInfo{X: x, Y: y, Culture: labels.Culture(c)}
+	for f := 0; f < culsim.Features; f++ {
+		info.Traits = append(info.Traits, culsim.FeatureTrait(c, f))
+	}
+	for _, neighbour := range findNeighbours(n) {
+		nx, ny := coords(neighbour)
+		nc := cultures[neighbour]
+		info.Neighbours = append(info.Neighbours, neighbourInfo{nx, ny, labels.Culture(nc), culsim.SharedFeatures(c, nc)})
+	}
+	if n < len(history) {
+		info.History = append(info.History, history[n]...)
+	}
+	return info
+}
+
+// the inspector panel for the terminal
+func (info cellInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cell (%d,%d) culture %s traits %v\n", info.X, info.Y, info.Culture, info.Traits)
+	for _, nb := range info.Neighbours {
+		fmt.Fprintf(&b, "  neighbour (%d,%d) %s shares %d/%d features\n", nb.X, nb.Y, nb.Culture, nb.Shared, culsim.Features)
+	}
+	for _, h := range info.History {
+		fmt.Fprintf(&b, "  tick %d: %s -> %s\n", h.Tick, h.From, h.To)
+	}
+	return b.String()
+}
+
+// move the inspector's cursor by dx columns and dy rows, staying on the grid
+func moveCursor(dx, dy int) {
+	x, y := coords(cursor)
+	x, y = clamp(x+dx, 0, width-1), clamp(y+dy, 0, width-1)
+	cursor = y*width + x
+}
+
+func clamp(v, low, high int) int {
+	if v < low {
+		return low
+	}
+	if v > high {
+		return high
+	}
+	return v
+}
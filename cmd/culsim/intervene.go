@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/sausheong/culsim"
+)
+
+// log the interventions in the run as they are applied
+func (sim *CultureSim) recordInterventions(m culsim.Message) {
+	if a, ok := m.(culsim.InterventionApplied); ok {
+		iv := a.Intervention
+		slog.Info("intervention applied", "tick", iv.Tick, "type", iv.Type, "cell", iv.Cell,
+			"culture", labels.Culture(iv.Culture), "rate", iv.Rate)
+	}
+}
+
+// save the interventions in the run in data/interventions-<name>.json, as
+// the interventions of a config that redoes them in a run with the same
+// -seed and flags
+func (sim *CultureSim) saveInterventions(name string) error {
+	data, err := json.MarshalIndent(culsim.Config{Interventions: sim.engine.Interventions()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("data/interventions-%s.json", name)
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("interventions saved", "path", path)
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// run the invasion experiment: for each replicate initialise the grid, seed a
+// block of a single invader culture in its centre and follow the number of
+// invader cells, and the radius of their front, until the invader dies out or
+// the simulation ends
+func runInvasion() error {
+	name := fmt.Sprintf("n%d-w%d-c%1.1f-b%d-%s", *interactions, width, *coverage, *invade, runID)
+	csvfile, err := os.Create(fmt.Sprintf("data/invasion-%s.csv", name))
+	if err != nil {
+		return err
+	}
+	defer csvfile.Close()
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"replicate", "tick", "invaders", "radius"})
+
+	var extinctions, finished int
+	// extinct replicates end early, so the progress is a worst case
+	runProgress.start(0, *replicates**duration)
+	for rep := 1; rep <= *replicates; rep++ {
+		if wallTimeUp() {
+			slog.Info("wall time used up, replicates left unfinished", "max-wall-time", *maxWallTime, "finished", finished)
+			break
+		}
+		// every replicate has its own seed, drawn from the run's seed
+		engine, err := newEngine(rand.Int63())
+		if err != nil {
+			return err
+		}
+		if err = engine.Invade(*invade); err != nil {
+			return invalidError{fmt.Errorf("invalid invader block: %w", err)}
+		}
+		sim := &CultureSim{}
+		if err = sim.start(engine); err != nil {
+			return err
+		}
+		count := engine.Invaders()
+		ticks, radii := []int{0}, []float64{math.Sqrt(float64(count) / math.Pi)}
+		_ = csvwriter.Write([]string{strconv.Itoa(rep), "0", strconv.Itoa(count), formatFloat(radii[0])})
+		for engine.Tick() < *duration && count > 0 && !wallTimeUp() {
+			sim.step()
+			count = engine.Invaders()
+			runProgress.update((rep-1)**duration + engine.Tick())
+			ticks, radii = append(ticks, engine.Tick()), append(radii, math.Sqrt(float64(count)/math.Pi))
+			_ = csvwriter.Write([]string{strconv.Itoa(rep), strconv.Itoa(engine.Tick()), strconv.Itoa(count),
+				formatFloat(radii[len(radii)-1])})
+		}
+		speed := fmt.Sprintf("%.4f", frontSpeed(ticks, radii))
+		if wallTimeUp() && engine.Tick() < *duration && count > 0 {
+			slog.Info("wall time used up, replicate left unfinished", "replicate", rep, "tick", engine.Tick())
+			break
+		}
+		finished++
+		if count == 0 {
+			extinctions++
+			slog.Info("invader extinct", "replicate", rep, "tick", engine.Tick(), "front-speed", speed)
+		} else {
+			slog.Info("invader survived", "replicate", rep, "invaders", count, "tick", engine.Tick(), "front-speed", speed)
+		}
+	}
+	csvwriter.Flush()
+	if err = csvwriter.Error(); err != nil {
+		return err
+	}
+	closeStream()
+	shutdownTracing()
+	addOutput(csvfile.Name())
+	status := "finished"
+	if finished < *replicates {
+		status = "timed out"
+	}
+	slog.Info("invasion experiment "+status, "extinctions", extinctions, "replicates", finished,
+		"path", csvfile.Name())
+	uploadOutputs()
+	report(status, "", map[string]int{"replicates": finished, "extinctions": extinctions})
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// keys pressed while the simulation runs
+var keys = make(chan rune, 16)
+
+// terminal settings to restore when the simulation ends
+var savedTerminal string
+
+// read single key presses from the terminal, if there is one
+func listenKeys() {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return
+	}
+	if _, err = stty("cbreak", "-echo"); err != nil {
+		return
+	}
+	savedTerminal = strings.TrimSpace(saved)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			r, _, err := reader.ReadRune()
+			if err != nil {
+				return
+			}
+			select {
+			case keys <- r:
+			default: // drop keys pressed faster than they are handled
+			}
+		}
+	}()
+}
+
+// restore the terminal to how it was before listening to keys
+func restoreTerminal() {
+	if savedTerminal != "" {
+		_, _ = stty(savedTerminal)
+		savedTerminal = ""
+	}
+}
+
+// run stty on the terminal
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// simulation controls set by the keys
+var paused bool   // the simulation is paused
+var stepping bool // advance one tick while paused
+var saving bool   // write the data and a snapshot now
+var quitting bool // save the data and quit
+
+// escape sequence being read
+var escape []rune
+
+// the minimap is shown with zoomed or panned terminal views
+var minimap = true
+
+// handle the keys pressed since the last tick: c shows the culture view, p
+// the palette view, h and d the diversity and distance heatmaps, and 0 to 5
+// the view of that feature. Space pauses and resumes the simulation, s
+// advances it one tick while paused, w writes the data and a snapshot now and
+// q quits. i shows the cell inspector, whose cursor the arrow keys move.
+// + and - zoom the terminal views in and out, W, A, S and D pan them and m
+// shows the minimap. Returns whether any key was handled.
+func handleKeys() bool {
+	handled := false
+	for {
+		select {
+		case k := <-keys:
+			handled = true
+			// arrow keys arrive as the escape sequences ESC [ A to D and move
+			// the inspector's cursor
+			if k == 0x1B || len(escape) > 0 {
+				escape = append(escape, k)
+				if len(escape) == 3 {
+					switch escape[2] {
+					case 'A':
+						moveCursor(0, -1)
+					case 'B':
+						moveCursor(0, 1)
+					case 'C':
+						moveCursor(1, 0)
+					case 'D':
+						moveCursor(-1, 0)
+					}
+					escape = nil
+				}
+				continue
+			}
+			switch {
+			case k == '+':
+				port.zoomBy(true)
+			case k == '-':
+				port.zoomBy(false)
+			case k == 'W':
+				port.pan(0, -1)
+			case k == 'S':
+				port.pan(0, 1)
+			case k == 'A':
+				port.pan(-1, 0)
+			case k == 'D':
+				port.pan(1, 0)
+			case k == 'm':
+				minimap = !minimap
+			case k == 'i':
+				inspecting = !inspecting
+			case k == ' ':
+				paused = !paused
+			case k == 's':
+				stepping = true
+			case k == 'w':
+				saving = true
+			case k == 'q':
+				quitting = true
+			case k == 'c':
+				*view = "culture"
+			case k == 'p':
+				*view = "palette"
+			case k == 'h':
+				*view = "diversity"
+			case k == 'd':
+				*view = "distance"
+			case k >= '0' && int(k-'0') < culsim.Features:
+				*view, *viewFeature = "feature", int(k-'0')
+			}
+		default:
+			return handled
+		}
+	}
+}
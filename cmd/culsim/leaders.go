@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/sausheong/culsim"
+)
+
+// start tracking influences for a new epoch
+func (sim *CultureSim) resetEpoch() {
+	sim.epochStart = sim.engine.Influences()
+	sim.epochCultures = nil
+}
+
+// record the cultures of every tick and, at the end of an epoch, the
+// trajectories of its top influencers
+func (sim *CultureSim) recordLeaders(m culsim.Message) {
+	t, ok := m.(culsim.TickCompleted)
+	if !ok || *leaders <= 0 {
+		return
+	}
+	sim.epochCultures = append(sim.epochCultures, sim.cultures())
+	if t.Tick%*epoch == 0 {
+		sim.closeEpoch()
+	}
+}
+
+// record the top influencers of the epoch just ended and start a new one
+func (sim *CultureSim) closeEpoch() {
+	if len(sim.epochCultures) == 0 {
+		return
+	}
+	start := sim.engine.Tick() - len(sim.epochCultures) + 1
+	influences := sim.engine.Influences()
+	for n := range influences {
+		influences[n] -= sim.epochStart[n]
+	}
+	for rank, n := range topInfluencers(influences, *leaders) {
+		x, y := coords(n)
+		for t, cultures := range sim.epochCultures {
+			sim.leaderRows = append(sim.leaderRows, []string{
+				strconv.Itoa((start - 1) / *epoch),
+				strconv.Itoa(rank + 1),
+				strconv.Itoa(n),
+				strconv.Itoa(x),
+				strconv.Itoa(y),
+				strconv.Itoa(influences[n]),
+				strconv.Itoa(start + t),
+				labels.Culture(cultures[n]),
+			})
+		}
+	}
+	sim.resetEpoch()
+}
+
+// the k cells with the most successful influences, cells without any
+// influence are never leaders
+func topInfluencers(influences []int, k int) []int {
+	var cells []int
+	for n, count := range influences {
+		if count > 0 {
+			cells = append(cells, n)
+		}
+	}
+	sort.SliceStable(cells, func(i, j int) bool {
+		return influences[cells[i]] > influences[cells[j]]
+	})
+	if len(cells) > k {
+		cells = cells[:k]
+	}
+	return cells
+}
+
+// save the leader trajectories of every epoch
+func (sim *CultureSim) saveLeaders(name string) error {
+	csvfile, err := os.Create(fmt.Sprintf("data/leaders-%s.csv", name))
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"epoch", "rank", "cell", "x", "y", "influences", "tick", "culture"})
+	for _, row := range sim.leaderRows {
+		_ = csvwriter.Write(row)
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(fmt.Sprintf("data/leaders-%s.csv", name))
+	slog.Info("opinion leaders saved", "path", fmt.Sprintf("data/leaders-%s.csv", name))
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// exit codes of culsim
+const (
+	exitFailed  = 1 // the run failed, such as when its data cannot be saved
+	exitInvalid = 2 // the flags or settings of the run are invalid
+)
+
+// an error in the flags or settings of a run
+type invalidError struct{ error }
+
+func (e invalidError) Unwrap() error { return e.error }
+
+// exit code for an error stopping the run
+func exitCode(err error) int {
+	var invalid invalidError
+	if errors.As(err, &invalid) {
+		return exitInvalid
+	}
+	return exitFailed
+}
+
+// log to stderr at the -log-level in the -log-format, text or json
+func setupLogging() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid log level %q, use debug, info, warn or error\n", *logLevel)
+		os.Exit(exitInvalid)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(*logFormat) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		fmt.Fprintf(os.Stderr, "invalid log format %q, use text or json\n", *logFormat)
+		os.Exit(exitInvalid)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// log an error and stop
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(exitFailed)
+}
@@ -0,0 +1,897 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sausheong/culsim"
+)
+
+var width int         // width of simulation grid
+var interactions *int // how many cultural interactions
+var coverage *float64 // how much of the grid is covered
+var duration *int
+var featureCount *int        // features of a culture
+var traitCount *int          // traits of each feature
+var preset *string           // published model the flags are set to
+var rule *string             // how neighbouring cultures interact
+var topology *string         // which cells are neighbours
+var partner *string          // which neighbours interact
+var exchange *string         // how distance sets the probability of an exchange
+var steepness *float64       // steepness of the sigmoid exchange function
+var threshold *float64       // distance the sigmoid and threshold exchange functions turn at
+var copies *int              // traits copied in an exchange
+var differingOnly *bool      // copy only features the cultures differ in
+var adaptive *bool           // scale the interactions of every tick with the active bonds
+var workers *int             // goroutines running parallel ticks
+var noise *float64           // probability of a random trait change per interaction
+var colonization *float64    // probability of spreading into an empty neighbour
+var conquest *float64        // probability of a contest at each domain border per tick
+var conquestDistance *int    // minimum feature distance for domains to contest
+var reputationBias *float64  // how strongly reputation biases who is copied
+var leaders *int             // number of top influencers reported per epoch
+var epoch *int               // ticks per epoch of opinion leader reporting
+var initial *string          // how the grid is initialised
+var invade *int              // width of the invader block, 0 for a normal run
+var sweepRanges *string      // ranges of the flags culsim sweep samples
+var sampling *string         // how culsim sweep samples the ranges
+var samples *int             // number of samples of culsim sweep
+var objective *string        // metric culsim optimize optimizes
+var goal *string             // min, max or a value of the metric to reach
+var generations *int         // generations of culsim optimize
+var repeats *int             // runs of every candidate of culsim optimize
+var targetPath *string       // metrics culsim calibrate fits the runs to
+var treatment *string        // config the treated run of culsim twin adds
+var replicates *int          // number of invasion or validation replicates
+var tolerance *float64       // standard errors validation results may be off by
+var scalingSizes *string     // grid sizes of finite-size scaling
+var scalingTraits *string    // traits finite-size scaling runs through
+var scalingFeatures *int     // features of finite-size scaling
+var qc *float64              // critical traits of finite-size scaling
+var nu *float64              // correlation length exponent of finite-size scaling
+var beta *float64            // order parameter exponent of finite-size scaling
+var invaderPrestige *float64 // extra copying weight of invader cells
+var invaderActivity *float64 // extra chance of invader cells initiating
+var view *string             // how the grid is coloured when rendered
+var viewFeature *int         // feature shown in the feature view
+var paletteSize *int         // number of cultures given palette colours
+var showGrid *bool           // render the grid in the terminal
+var pngEvery *int            // ticks between PNG images of the grid
+var scale *int               // pixels per cell in images
+var snapshots *bool          // record grid snapshots every tick
+var fps *int                 // frames per second of exported videos
+var output *string           // path of the exported video
+var svg *bool                // save the final grid as SVG
+var outline *bool            // outline domains in rendered images
+var labelDomains *int        // number of largest domains labelled
+var webAddr *string          // address of the web view
+var chartWidth *int          // ticks shown in the console charts
+var renderEvery *int         // ticks between refreshes of the display
+var tps *float64             // target ticks per second
+var termWidth *int           // cells shown across the terminal
+var termHeight *int          // cells shown down the terminal
+var refractory *int          // ticks a pair of cells rests after an exchange
+var configFile *string       // path to the JSON config file
+var skipMetrics *string      // built-in metrics that aren't recorded
+var sinks *string            // where the data is written as the run goes
+var notifyURL *string        // webhook notified when the run ends
+var uploadURI *string        // object store the outputs are uploaded to
+var natsURI *string          // NATS server and subject metrics are streamed to
+var streamExchanges *bool    // also stream every exchange
+var queueURL *string         // job queue of culsim worker
+var grpcAddr *string         // address of the gRPC simulation service
+var logLevel *string         // lowest level of messages logged
+var logFormat *string        // text or json log lines
+var traceRun *bool           // export spans of every tick
+var checkRun *bool           // check the invariants of the model every tick
+var featureStats *bool       // record the exchanges of every tick by feature
+var interactionStats *bool   // record the interactions of every tick by outcome
+var entropy *bool            // record the trait entropy of every feature
+var performance *bool        // record the wall time and allocations of every tick
+var approxUnique *int        // precision of the approximate unique count
+var noReplacement *bool      // choose the initiators of a tick without replacement
+var influence *string        // cells or domains the influence matrix is saved between
+var distances *bool          // save the distances between the final cultures
+var changes *bool            // save how often each cell's culture changed
+var plateau *int             // ticks without change that make a metastable state
+var plateauTol *float64      // change in the metrics that ends a metastable state
+var smooth *int              // ticks the moving averages of metrics are over
+var smoothMetrics *string    // metrics that get moving averages
+var smoothEWMA *bool         // exponentially weighted moving averages
+var domainEvery *int         // ticks between logs of the domain sizes
+var domainTable *bool        // save every domain of each domain sample
+var domainEvents *bool       // log the births, merges, splits and extinctions of domains
+var track *int               // number of random cells whose trajectories are saved
+var trackCells *string       // cells whose trajectories are saved
+var correlationEvery *int    // ticks between estimates of the correlation length
+var frontShape *string       // shape of the cultural frontier whose speed is measured
+var seedFlag *int64          // seed of the random numbers
+var sinceFlag *string        // earliest start date of runs listed by culsim ls
+var snapshotFormat *string   // file format of recorded snapshots
+var keyframes *int           // snapshots between keyframes of delta snapshots
+var behaviorSpace *bool      // also save NetLogo BehaviorSpace tables
+var agentsEvery *int         // ticks between agent rows of the BehaviorSpace tables
+var analysis *bool           // also save scripts plotting the data
+var resumePath *string       // checkpoint the run carries on from
+var initFromRun *string      // run whose final grid the run starts from
+var poll *time.Duration      // wait between polls of an empty job queue
+
+var lastTick time.Time // when the latest tick started
+var timedOut bool      // the run stopped at its -max-wall-time
+
+var labels culsim.Labels // names of the features and traits from the config
+
+var maxWallTime *time.Duration   // time the run may take before it stops
+var progressEvery *time.Duration // time between progress reports in the log
+var statusFile *string           // file the progress of the run is written to
+var afterCmd *string             // shell command run when the run ends
+
+func init() {
+	interactions = flag.Int("n", 100, "number of interactions between cultures per simulation tick")
+	coverage = flag.Float64("c", 1.0, "percentage of simulation grid that is populated with cultures")
+	duration = flag.Int("d", 200, "the duration of the simulation")
+	featureCount = flag.Int("features", culsim.Features, "features of a culture, at most 6")
+	traitCount = flag.Int("traits", 0x10, "traits of each feature, at most 16")
+	preset = flag.String("preset", "", "set the flags of the model to a published one, axelrod1997 for Axelrod's The Dissemination of Culture, keeping any set on the command line")
+	rule = flag.String("rule", "distance", "how neighbouring cultures interact: distance for culsim's trait distance rule or axelrod for Axelrod's shared features rule")
+	partner = flag.String("partner", "", "which neighbours the cell initiating an interaction interacts with: all for every neighbour in turn, random for one random neighbour, most-similar for the neighbour sharing the most features or weighted for one neighbour weighted by the features they share, the default of the -rule if empty")
+	exchange = flag.String("exchange", "linear", "how the distance between 2 cultures sets their probability of an exchange under the distance rule: linear for 1 minus the distance over the features times the traits, overlap for the fraction of features they share, sigmoid for a fall around the -threshold as steep as the -steepness, or threshold for exchanges only up to the -threshold")
+	steepness = flag.Float64("steepness", 10, "steepness of the sigmoid -exchange function")
+	threshold = flag.Float64("threshold", 0.5, "distance, as a fraction of the features times the traits, the sigmoid and threshold -exchange functions turn at")
+	copies = flag.Int("copies", 1, "traits copied in an exchange, the one of the feature the rule chooses and up to this many minus 1 more the 2 cultures differ in, every one they differ in if at least 6")
+	differingOnly = flag.Bool("differing-only", false, "choose the feature whose trait is copied under the distance rule among the features the 2 cultures differ in, as Axelrod's rule does, instead of among all of them")
+	adaptive = flag.Bool("adaptive", false, "scale the -n interactions of every tick by the fraction of pairs of neighbours that could still exchange a trait, at least 1 while any can, so nearly frozen runs skip interactions that can't change anything, recorded as the interactions metric")
+	topology = flag.String("topology", "moore", "which cells are neighbours: moore for the 8 surrounding cells, von-neumann for the 4 adjacent cells or torus for the 8 surrounding cells with the edges wrapping around")
+	workers = flag.Int("workers", 0, "run the interactions of every tick in tiles of the grid on this many goroutines, reproducible for any number of workers with the same -seed, 0 for serial ticks")
+	noise = flag.Float64("noise", 0, "probability that a randomly chosen culture changes one trait at random per interaction")
+	colonization = flag.Float64("colonize", 0, "probability that a chosen culture spreads into each empty neighbouring cell")
+	conquest = flag.Float64("conquest", 0, "probability per tick that neighbouring domains of different cultures contest a border cell")
+	conquestDistance = flag.Int("conquest-distance", 3, "minimum feature distance between domains for them to contest their border")
+	reputationBias = flag.Float64("reputation", 0, "how strongly a cell's reputation from past successful influences makes it the one copied, 0 to ignore reputation")
+	leaders = flag.Int("leaders", 0, "number of top influencer cells whose trajectories are saved per epoch, 0 to disable")
+	epoch = flag.Int("epoch", 50, "number of ticks per epoch when reporting opinion leaders")
+	initial = flag.String("init", "random", "initial distribution of cultures: random, converged, zipf, clusters, blobs, marginals or file")
+	invade = flag.Int("invade", 0, "run the invasion experiment, seeding a block of this width with a single invader culture")
+	sweepRanges = flag.String("ranges", "", "comma-separated ranges of the flags culsim sweep samples or culsim optimize searches, such as n=100:1000,c=0.5:1, sampling whole numbers if both ends are whole")
+	sampling = flag.String("sampling", "lhs", "how culsim sweep samples the -ranges: grid for -samples evenly spaced values of each range and all their combinations, lhs for a Latin hypercube of -samples runs or random for -samples runs anywhere in the ranges")
+	samples = flag.Int("samples", 10, "number of runs of culsim sweep, or of values of each range for grid sampling, or of candidates in every generation of culsim optimize")
+	objective = flag.String("objective", "unique", "final metric of the runs culsim optimize optimizes, such as unique, distance or changes")
+	goal = flag.String("goal", "min", "what culsim optimize looks for: min or max for the smallest or largest -objective, or a number for the -objective closest to it")
+	generations = flag.Int("generations", 5, "generations of candidates culsim optimize runs after the first")
+	repeats = flag.Int("repeats", 1, "runs of every candidate of culsim optimize or culsim calibrate, averaged")
+	treatment = flag.String("treatment", "", "JSON config of the treatment culsim twin gives one of 2 otherwise identical runs, whose settings replace the ones of -config")
+	targetPath = flag.String("target", "", "CSV of the metrics culsim calibrate fits the -ranges to, a row for each with its name and its value at every tick from 0, empty for ticks not observed, or only its final value")
+	replicates = flag.Int("replicates", 10, "number of replicates of the invasion experiment, or of each parameterization checked by culsim validate or run by culsim scaling, or replicas compared by culsim diverge")
+	tolerance = flag.Float64("tolerance", 2, "standard errors the stable regions found by culsim validate may be off their published values by, or the distance of the candidates of culsim calibrate that fit may be off the best")
+	scalingSizes = flag.String("sizes", "10,20,40", "comma-separated grid sizes culsim scaling runs")
+	scalingTraits = flag.String("scaling-traits", "2,4,6,8,10,12,14,16", "comma-separated traits per feature culsim scaling runs on every grid size")
+	scalingFeatures = flag.Int("scaling-features", 5, "features of the cultures of culsim scaling")
+	qc = flag.Float64("qc", 0, "critical number of traits culsim scaling centres the scaled traits on")
+	nu = flag.Float64("nu", 1, "correlation length exponent culsim scaling scales the traits by")
+	beta = flag.Float64("beta", 0, "order parameter exponent culsim scaling scales the order parameter by")
+	invaderPrestige = flag.Float64("invader-prestige", 0, "extra weight of invader cells being the one copied in an exchange")
+	invaderActivity = flag.Float64("invader-activity", 0, "extra weight of invader cells being chosen to initiate an interaction")
+	view = flag.String("view", "culture", "how the grid is coloured: culture for the raw culture values, palette for distinct colours for the most common cultures, feature for the traits of one feature, or diversity or distance for heatmaps of local diversity")
+	viewFeature = flag.Int("view-feature", 0, "feature whose traits are shown in the feature view")
+	paletteSize = flag.Int("palette", 10, "number of most common cultures given distinct colours in the palette view")
+	showGrid = flag.Bool("show-grid", false, "render the grid and its legend in the terminal")
+	pngEvery = flag.Int("png-every", 0, "save a PNG image of the grid every this many ticks, 0 to disable")
+	scale = flag.Int("scale", 8, "pixels per cell in images")
+	snapshots = flag.Bool("snapshots", false, "record the grid every tick in data/snapshots-* for replaying with culsim render")
+	fps = flag.Int("fps", 10, "frames per second of videos exported with culsim video")
+	output = flag.String("o", "data/replay.mp4", "video file exported with culsim video, .mp4 or .webm")
+	svg = flag.Bool("svg", false, "save the final grid as an SVG image in data/grid-*.svg")
+	outline = flag.Bool("outline", false, "outline the borders of cultural domains in images and the web view")
+	labelDomains = flag.Int("label-domains", 5, "number of largest domains labelled with their size when outlining domains")
+	webAddr = flag.String("web", "", "address to serve a live web view of the grid on, such as :8080")
+	chartWidth = flag.Int("chart", 60, "number of latest ticks charted in the terminal status screen")
+	renderEvery = flag.Int("render-every", 1, "refresh the terminal display every this many ticks")
+	tps = flag.Float64("tps", 0, "target number of ticks per second, 0 to run at full speed")
+	termWidth = flag.Int("term-width", 100, "number of columns of cells shown in the terminal views before they need panning")
+	termHeight = flag.Int("term-height", 60, "number of rows of cells shown in the terminal views before they need panning")
+	refractory = flag.Int("refractory", 0, "number of ticks a pair of cells cannot interact after exchanging a trait")
+	configFile = flag.String("config", "", "path to a JSON file with optional simulation settings")
+	sinks = flag.String("sink", "", "comma-separated sinks the metrics of every tick and the -snapshots are written to as the run goes: csv for data/ticks-*.csv and data/grids-*.csv, jsonl for data/run-*.jsonl, sqlite for the data/run-*.db database or stdout for JSON lines instead of the terminal display")
+	skipMetrics = flag.String("skip-metrics", "", "comma-separated built-in metrics that aren't recorded, such as distance, the slowest to measure")
+	natsURI = flag.String("nats", "", "stream the metrics of every tick to a NATS server and subject, such as nats://localhost:4222/culsim")
+	streamExchanges = flag.Bool("stream-exchanges", false, "also stream every exchange to the <subject>.exchanges subject")
+	queueURL = flag.String("queue", "", "URL of the HTTP job queue culsim worker takes parameter sets from")
+	poll = flag.Duration("poll", 0, "how long culsim worker waits before asking an empty job queue again, 0 to stop when it is empty")
+	grpcAddr = flag.String("grpc", "", "serve the gRPC simulation service on this address, such as :50051, instead of running a simulation")
+	logLevel = flag.String("log-level", "info", "lowest level of messages logged: debug, info, warn or error")
+	logFormat = flag.String("log-format", "text", "format of log messages: text or json")
+	maxWallTime = flag.Duration("max-wall-time", 0, "stop cleanly once the run has taken this long, such as 2h30m, saving its data and a checkpoint to carry on from with -resume, 0 for no limit")
+	progressEvery = flag.Duration("progress", 0, "log the percentage done, ticks per second and estimated time left every this long, such as 1m, 0 to not log progress")
+	statusFile = flag.String("status-file", "", "keep the progress of the run as JSON in this file, rewritten every -progress or every 10s")
+	interactionStats = flag.Bool("interaction-stats", false, "record the interactions of every tick by how they turned out, as attempts, rejected, blocked-identical, blocked and copies")
+	noReplacement = flag.Bool("without-replacement", false, "choose the cells initiating the interactions of every tick without replacement from the populated cells, instead of drawing each from every cell")
+	approxUnique = flag.Int("approx-unique", 0, "count the unique cultures approximately with a HyperLogLog sketch of 2^N registers, between 4 and 16, in fixed memory for very large grids, 0 to count them exactly")
+	performance = flag.Bool("perf", false, "record the wall time in microseconds, heap allocations and interactions attempted of every tick, as wall-us, allocs, alloc-bytes and attempts")
+	entropy = flag.Bool("entropy", false, "record the Shannon entropy in bits of the traits of each feature every tick, as entropy-0 to entropy-5")
+	featureStats = flag.Bool("feature-stats", false, "record the exchanges of every tick by the feature copied, as copied-0 to copied-5, and the interactions blocked because the cultures have no distance between them, as blocked-identical")
+	influence = flag.String("influence", "", "save the exchanges of the run by who influenced whom as a sparse matrix in data/influence-*.mtx: cells between every pair of cells, or domains between the domains of the final grid")
+	distances = flag.Bool("distances", false, "save the number of features every pair of distinct cultures of the final grid differ on, with the cells of each culture, in data/distances-*.csv for cluster analysis")
+	changes = flag.Bool("changes", false, "save the number of ticks in which the culture of each cell changed as a grid in data/changes-*.csv and a heatmap in data/changes-*.png")
+	plateau = flag.Int("plateau", 0, "log when the run enters and leaves metastable states, in which no metric moves more than -plateau-tolerance for this many ticks, and save them in data/plateaus-*.csv, 0 to disable")
+	plateauTol = flag.Float64("plateau-tolerance", 0.01, "change of a metric, relative to its level or to 1 for levels below 1, that doesn't count as moving in a metastable state")
+	smooth = flag.Int("smooth", 0, "also record moving averages of the -smooth-metrics over this many ticks, such as change-ma10, 0 to disable")
+	smoothMetrics = flag.String("smooth-metrics", "change", "comma-separated metrics that get moving averages with -smooth")
+	smoothEWMA = flag.Bool("ewma", false, "make the -smooth moving averages exponentially weighted, such as change-ewma10")
+	domainEvery = flag.Int("domain-every", 0, "log the number of domains, the Gini coefficient of their sizes and the ratio of the largest to the median every this many ticks, and save them in data/domains-*.csv, 0 to disable")
+	domainTable = flag.Bool("domain-table", false, "with -domain-every, also save every domain of each sample in data/domain-table-*.csv, with its ID from sample to sample, size, centroid, perimeter, age and culture")
+	domainEvents = flag.Bool("domain-events", false, "with -domain-every, also log the domains born, merged, split and died out since the previous sample, and save them in data/domain-events-*.csv")
+	track = flag.Int("track", 0, "save the culture of this many random populated cells every tick in data/trajectories-*.csv, 0 to disable")
+	trackCells = flag.String("track-cells", "", "space-separated x,y coordinates of the cells whose culture is saved every tick in data/trajectories-*.csv, such as \"0,0 10,12\", instead of random -track cells")
+	correlationEvery = flag.Int("correlation-every", 0, "log the spatial correlation length of cultural similarity every this many ticks, and save it in data/correlation-*.csv, 0 to disable")
+	frontShape = flag.String("front", "", "measure the cultural frontier every tick, saving its position in data/front-*.csv and logging its speed: radial for the radius of the region of the culture the centre cell starts with, as in an invasion, or planar for the column where the culture of the left edge gives way to that of the right edge, as between 2 blocs")
+	checkRun = flag.Bool("check", false, "check the invariants of the model after every tick, such as traits within range, and stop with the violations and a checkpoint of the grid if any break")
+	traceRun = flag.Bool("trace", false, "export OpenTelemetry spans of every tick and its phases to the OTLP endpoint in OTEL_EXPORTER_OTLP_ENDPOINT")
+	seedFlag = flag.Int64("seed", 0, "seed of the random numbers, 0 for a random seed, which is kept in data/index.json")
+	sinceFlag = flag.String("since", "", "only list runs started on or after this date, YYYY-MM-DD, with culsim ls")
+	keyframes = flag.Int("keyframes", 0, "record csv -snapshots as the cells that changed since the previous tick, with every cell every this many snapshots as a keyframe, 0 to record every cell every tick")
+	snapshotFormat = flag.String("snapshot-format", "csv", "format snapshots are recorded in: csv, npy for a NumPy array that can be memory-mapped, or npz for a compressed NumPy archive")
+	behaviorSpace = flag.Bool("behaviorspace", false, "also save the run as NetLogo BehaviorSpace tables of model and agent data in data/model-*.csv and data/agents-*.csv")
+	agentsEvery = flag.Int("agents-every", 0, "record every cell in the BehaviorSpace agent table every this many ticks, 0 for only the end of the run")
+	analysis = flag.Bool("analysis", false, "also save R and Python scripts that plot the simulation data in data/analysis-*")
+	initFromRun = flag.String("init-from", "", "start from the final grid of another run, by its ID in the run index or a checkpoint file, with this run's parameters and a grid of the same size")
+	resumePath = flag.String("resume", "", "carry on the run saved in a checkpoint, which the w key saves in data/checkpoint-*.json, or the culsim sweep of this ID or plan file, skipping its finished samples")
+	uploadURI = flag.String("upload", "", "object store URI such as s3://bucket/prefix/ or gs://bucket/prefix/ to upload the saved files to when the run ends")
+	afterCmd = flag.String("after", "", "shell command run when the run or invasion experiment ends, after its data is saved and uploaded, with the saved files as its arguments and in $CULSIM_OUTPUTS")
+	notifyURL = flag.String("notify-url", "", "URL to POST a JSON summary to when the run or invasion experiment finishes or fails")
+}
+
+// a simulation run by the command, with the data it records as it goes
+type CultureSim struct {
+	grid
+	engine *culsim.Engine
+	err    error // why saving the outputs of the run failed
+
+	epochStart    []int             // successful influences of each cell at the start of the current epoch
+	epochCultures [][]int           // cultures of every cell for each tick of the current epoch
+	leaderRows    [][]string        // leader trajectories of the finished epochs
+	agentRows     [][]string        // agent rows: step, cell, x, y, culture and its traits
+	snapshots     *snapshotRecorder // grid snapshots, nil if not recorded
+	sink          Sink              // sinks the data is written to as the run goes, nil if none
+	influenced    map[[2]int]int    // exchanges by the cell copied from and the cell that copied
+	changes       []int             // ticks in which the culture of each cell changed
+	lastCultures  []int             // cultures at the end of the latest tick
+	plateaus      plateaus          // metastable states of the run
+	domainRows    [][]string        // domain sizes: tick, domains, Gini coefficient and max/median ratio
+	domains       domainTracker     // domains followed from sample to sample for the domain table and events
+	correlations  [][]string        // correlation lengths: tick, length and baseline similarity
+	front         front             // positions of the cultural frontier
+	tracked       []int             // cells whose cultures are tracked every tick
+	trajectories  [][]string        // tracked cells: tick, cell, x, y, culture and its traits
+	sinkErr       error             // why writing to the sinks failed, which stops writing to them
+}
+
+func (sim *CultureSim) Exit() {
+	restoreTerminal()
+	sim.closeSnapshots()
+	closeStream()
+	shutdownTracing()
+	sim.err = errors.Join(sim.err, sim.save(sim.name()), sim.closeSink())
+	uploadOutputs()
+	if sim.err != nil {
+		slog.Error("failed saving data", "err", sim.err)
+		report("failed", sim.err.Error(), sim.metrics())
+		return
+	}
+	status := "finished"
+	if quitting {
+		status = "stopped"
+	}
+	if timedOut {
+		status = "timed out"
+	}
+	report(status, "", sim.metrics())
+}
+
+// save the data of the run and the tables and images asked for
+func (sim *CultureSim) save(name string) error {
+	err := sim.saveData(name)
+	if *behaviorSpace {
+		sim.saveBehaviorSpace(name)
+	}
+	if *svg {
+		if err := sim.saveSVG(name); err != nil {
+			slog.Error("failed saving SVG", "err", err)
+		}
+	}
+	if *leaders > 0 {
+		// include the unfinished epoch
+		sim.closeEpoch()
+		err = errors.Join(err, sim.saveLeaders(name))
+	}
+	if *influence != "" {
+		err = errors.Join(err, sim.saveInfluences(name))
+	}
+	if *distances {
+		err = errors.Join(err, sim.saveDistances(name))
+	}
+	if *changes {
+		err = errors.Join(err, sim.saveChanges(name))
+	}
+	if *plateau > 0 {
+		err = errors.Join(err, sim.savePlateaus(name))
+	}
+	if *domainEvery > 0 {
+		err = errors.Join(err, sim.saveDomains(name))
+		if *domainTable {
+			err = errors.Join(err, sim.saveDomainTable(name))
+		}
+		if *domainEvents {
+			err = errors.Join(err, sim.saveDomainEvents(name))
+		}
+	}
+	if *correlationEvery > 0 {
+		err = errors.Join(err, sim.saveCorrelation(name))
+	}
+	if *frontShape != "" {
+		err = errors.Join(err, sim.saveFront(name))
+	}
+	if sim.tracked != nil {
+		err = errors.Join(err, sim.saveTrajectories(name))
+	}
+	if len(sim.engine.Interventions()) > 0 {
+		err = errors.Join(err, sim.saveInterventions(name))
+	}
+	return errors.Join(err, sim.saveFinal(name))
+}
+
+// exit code of the finished run
+func (sim *CultureSim) exitCode() int {
+	if sim.err != nil {
+		return exitCode(sim.err)
+	}
+	return 0
+}
+
+func (sim *CultureSim) Init() {
+	engine, err := newEngine(seed)
+	if err == nil {
+		err = sim.start(engine)
+	}
+	if err != nil {
+		fail("failed initialising simulation", err)
+	}
+	runProgress.start(engine.Tick(), *duration)
+	engine.Bus().Subscribe(trackProgress)
+}
+
+// flags of the published models of -preset
+var presets = map[string]map[string]string{
+	// Axelrod (1997), The Dissemination of Culture: a 10x10 territory of
+	// sites with 5 features of 10 traits, where a random site interacts with
+	// a random one of its 4 adjacent sites, without wrapping edges, with a
+	// probability of the fraction of features they share, and copies the
+	// trait of one of the features they differ in
+	"axelrod1997": {
+		"w": "10", "features": "5", "traits": "10", "c": "1", "init": "random",
+		"rule": "axelrod", "topology": "von-neumann", "partner": "random", "exchange": "linear", "copies": "1", "adaptive": "false",
+		"without-replacement": "false", "noise": "0", "colonize": "0", "conquest": "0", "reputation": "0",
+		"refractory": "0", "invade": "0", "config": "",
+	},
+}
+
+// set the flags to the model of the -preset, except the ones set on the
+// command line, whose departures from it are logged
+func applyPreset() error {
+	if *preset == "" {
+		return nil
+	}
+	settings, ok := presets[*preset]
+	if !ok {
+		return fmt.Errorf("unknown -preset %q", *preset)
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := settings[name]
+		if !explicit[name] {
+			if err := flag.Set(name, value); err != nil {
+				return err
+			}
+		} else if set := flag.Lookup(name).Value.String(); set != value {
+			slog.Warn("flag departs from the preset", "preset", *preset, "flag", name, "value", set, "model", value)
+		}
+	}
+	return nil
+}
+
+// create the engine of a simulation from the flags and the config file,
+// carrying on from the checkpoint given with -resume. Its errors are
+// invalid settings.
+func newEngine(seed int64) (*culsim.Engine, error) {
+	cfg, err := culsim.LoadConfig(*configFile)
+	if err != nil {
+		return nil, invalidError{err}
+	}
+	return configuredEngine(seed, cfg)
+}
+
+// create the engine of a simulation from the flags and a config
+func configuredEngine(seed int64, cfg *culsim.Config) (*culsim.Engine, error) {
+	labels = cfg.Labels
+	var skipped []string
+	if *skipMetrics != "" {
+		skipped = strings.Split(*skipMetrics, ",")
+	}
+	opts := []culsim.Option{
+		culsim.WithGrid(width, width),
+		culsim.WithFeatures(*featureCount, *traitCount),
+		culsim.WithRule(culsim.Rule(*rule)),
+		culsim.WithTopology(culsim.Topology(*topology)),
+		culsim.WithPartner(culsim.Partner(*partner)),
+		culsim.WithExchange(culsim.Exchange(*exchange), *steepness, *threshold),
+		culsim.WithCopies(*copies),
+		culsim.WithSeed(seed),
+		culsim.WithInteractions(*interactions),
+		culsim.WithWorkers(*workers),
+		culsim.WithCoverage(*coverage),
+		culsim.WithDuration(*duration),
+		culsim.WithNoise(*noise),
+		culsim.WithColonization(*colonization),
+		culsim.WithConquest(*conquest, *conquestDistance),
+		culsim.WithReputation(*reputationBias),
+		culsim.WithRefractory(*refractory),
+		culsim.WithInitial(*initial),
+		culsim.WithInvader(*invaderPrestige, *invaderActivity),
+		culsim.WithConfig(cfg),
+		culsim.WithoutMetrics(skipped...),
+	}
+	if *differingOnly {
+		opts = append(opts, culsim.WithDifferingOnly())
+	}
+	if *adaptive {
+		opts = append(opts, culsim.WithAdaptiveInteractions())
+	}
+	if *featureStats {
+		opts = append(opts, culsim.WithFeatureStats())
+	}
+	if *interactionStats {
+		opts = append(opts, culsim.WithInteractionStats())
+	}
+	if *entropy {
+		opts = append(opts, culsim.WithEntropy())
+	}
+	if *performance {
+		opts = append(opts, culsim.WithPerformance())
+	}
+	if *noReplacement {
+		opts = append(opts, culsim.WithoutReplacement())
+	}
+	if *approxUnique > 0 {
+		opts = append(opts, culsim.WithApproximateUnique(*approxUnique))
+	}
+	engine, err := culsim.New(opts...)
+	if err != nil {
+		return nil, invalidError{err}
+	}
+	if *smooth > 0 {
+		if err = registerAverages(engine); err != nil {
+			return nil, invalidError{err}
+		}
+	}
+	if *initFromRun != "" {
+		if err = initFrom(engine, *initFromRun); err != nil {
+			return nil, invalidError{fmt.Errorf("failed starting from run %s: %w", *initFromRun, err)}
+		}
+	}
+	if *resumePath != "" {
+		if err = resume(engine, *resumePath); err != nil {
+			return nil, invalidError{fmt.Errorf("failed resuming run: %w", err)}
+		}
+	}
+	return engine, nil
+}
+
+// register the -smooth moving averages of the -smooth-metrics, after the
+// metrics they average
+func registerAverages(engine *culsim.Engine) error {
+	recorded := make(map[string]bool)
+	for _, s := range engine.Metrics() {
+		recorded[s.Name] = true
+	}
+	for _, name := range strings.Split(*smoothMetrics, ",") {
+		if !recorded[name] {
+			return fmt.Errorf("no metric %q to smooth", name)
+		}
+		average := culsim.MovingAverage(name, *smooth)
+		if *smoothEWMA {
+			average = culsim.ExponentialAverage(name, *smooth)
+		}
+		if err := engine.Register(average); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// start simulating with an engine, showing its grid and recording its data
+func (sim *CultureSim) start(engine *culsim.Engine) error {
+	sim.engine = engine
+	cultures := engine.Cultures()
+	sim.Units = make([]cell, width*width)
+	n := 0
+	for i := 1; i <= width; i++ {
+		for j := 1; j <= width; j++ {
+			sim.Units[n] = sim.CreateCell(i, j, cultures[n], 0)
+			n++
+		}
+	}
+	sim.resetEpoch()
+	sim.leaderRows = nil
+	sim.agentRows = nil
+	sim.influenced = make(map[[2]int]int)
+	sim.changes, sim.lastCultures = make([]int, width*width), cultures
+	sim.plateaus = plateaus{}
+	sim.domainRows = nil
+	sim.domains = domainTracker{}
+	if *domainEvery > 0 && (*domainTable || *domainEvents) {
+		// domains of the grid the run starts from formed when it started
+		domains, sizes := culsim.Domains(cultures, width, culsim.Topology(*topology))
+		sim.domains.follow(engine.Tick(), cultures, domains, sizes)
+	}
+	sim.correlations = nil
+	sim.front = front{}
+	if *frontShape != "" {
+		sim.front.culture = cultures[width/2*width+width/2]
+		sim.measureFront(engine.Tick())
+	}
+	sim.tracked, sim.trajectories = nil, nil
+	if *track > 0 || *trackCells != "" {
+		tracked, err := trackedCells(cultures)
+		if err != nil {
+			return err
+		}
+		sim.tracked = tracked
+		sim.appendTrajectories()
+	}
+	if *snapshots {
+		recorder, err := openSnapshots(sim.name())
+		if err != nil {
+			return err
+		}
+		sim.snapshots = recorder
+	}
+	if *sinks != "" {
+		s, err := openSinks(*sinks, sim.name())
+		if err != nil {
+			return err
+		}
+		sim.sink = s
+	}
+	// everything that follows the run gets its ticks, exchanges and
+	// snapshots from the engine's bus
+	bus := engine.Bus()
+	if *checkRun {
+		sim.checkEngine()
+		bus.Subscribe(sim.checkInvariants)
+	}
+	bus.Subscribe(sim.syncUnits)
+	bus.Subscribe(sim.recordLeaders)
+	bus.Subscribe(sim.recordAgents)
+	bus.Subscribe(sim.writeSinks)
+	bus.Subscribe(sim.writeSnapshot)
+	bus.Subscribe(sim.takeSnapshot)
+	bus.Subscribe(sim.recordInterventions)
+	if *influence != "" {
+		bus.Subscribe(sim.recordInfluences)
+	}
+	if *changes {
+		bus.Subscribe(sim.recordChanges)
+	}
+	if *plateau > 0 {
+		bus.Subscribe(sim.recordPlateaus)
+	}
+	if *domainEvery > 0 {
+		bus.Subscribe(sim.recordDomains)
+	}
+	if *correlationEvery > 0 {
+		bus.Subscribe(sim.recordCorrelation)
+	}
+	if *frontShape != "" {
+		bus.Subscribe(sim.recordFront)
+	}
+	if sim.tracked != nil {
+		bus.Subscribe(sim.recordTrajectories)
+	}
+	if *showGrid || *webAddr != "" {
+		bus.Subscribe(sim.publishFrames)
+	}
+	if stream.conn != nil {
+		bus.Subscribe(streamMessages)
+	}
+	if *snapshots {
+		bus.Publish(culsim.SnapshotTaken{Tick: engine.Tick(), Cultures: cultures})
+	}
+	return nil
+}
+
+func (sim *CultureSim) Process() {
+	// if current tick is beyond simulation duration, save data and exit
+	if sim.engine.Tick() > *duration {
+		sim.Exit()
+		os.Exit(sim.exitCode())
+	}
+	changed := handleKeys()
+	if quitting || sim.outOfTime() {
+		sim.Exit()
+		os.Exit(sim.exitCode())
+	}
+	if saving {
+		saving = false
+		sim.saveNow()
+	}
+	if paused && !stepping {
+		if changed {
+			sim.display()
+		}
+		// wait for keys without spinning
+		time.Sleep(50 * time.Millisecond)
+		return
+	}
+	stepping = false
+	sim.throttle()
+	sim.step()
+	sim.saveFrame()
+	// the display only refreshes every few ticks so it doesn't slow the run
+	if (sim.engine.Tick()%*renderEvery == 0 || paused) && !sinksStdout(*sinks) {
+		sim.display()
+	}
+}
+
+// wait before the next tick so the simulation runs no faster than the
+// target ticks per second
+func (sim *CultureSim) throttle() {
+	if *tps <= 0 {
+		return
+	}
+	next := lastTick.Add(time.Duration(float64(time.Second) / *tps))
+	if wait := time.Until(next); wait > 0 {
+		time.Sleep(wait)
+	}
+	lastTick = time.Now()
+}
+
+// check if the run has taken its -max-wall-time, saving a checkpoint to
+// carry on from with -resume if it has
+func (sim *CultureSim) outOfTime() bool {
+	if !wallTimeUp() {
+		return false
+	}
+	timedOut = true
+	path := fmt.Sprintf("data/checkpoint-%s-t%d.json", sim.name(), sim.engine.Tick())
+	if err := sim.saveCheckpoint(path); err != nil {
+		sim.err = fmt.Errorf("failed saving checkpoint: %w", err)
+		return true
+	}
+	addOutput(path)
+	slog.Info("wall time used up, checkpoint saved", "max-wall-time", *maxWallTime, "path", path)
+	return true
+}
+
+// check if the program has run for its -max-wall-time
+func wallTimeUp() bool {
+	return *maxWallTime > 0 && time.Since(started) >= *maxWallTime
+}
+
+// write the data so far and a checkpoint of the run without stopping
+func (sim *CultureSim) saveNow() {
+	name := sim.name()
+	if err := sim.saveData(name); err != nil {
+		slog.Error("failed saving data", "err", err)
+		return
+	}
+	path := fmt.Sprintf("data/checkpoint-%s-t%d.json", name, sim.engine.Tick())
+	if err := sim.saveCheckpoint(path); err != nil {
+		slog.Error("failed saving checkpoint", "path", path, "err", err)
+		return
+	}
+	addOutput(path)
+	slog.Info("checkpoint saved", "path", path)
+}
+
+// run one tick of the simulation, its data is recorded by the subscribers
+// of the engine's bus
+func (sim *CultureSim) step() {
+	sim.engine.Step(context.Background())
+}
+
+// keep the cells petri draws in step with the grid
+func (sim *CultureSim) syncUnits(m culsim.Message) {
+	if _, ok := m.(culsim.TickCompleted); ok {
+		for n, c := range sim.cultures() {
+			sim.Units[n].SetRGB(c)
+		}
+	}
+}
+
+// show the current state of the simulation in the terminal
+func (sim *CultureSim) display() {
+	// clear screen first
+	fmt.Print("\033[H\033[2J")
+	fmt.Println("\nNumber of cultural interactions:", *interactions)
+	fmt.Printf("\nSimulation coverage: %2.0f%%", *coverage*100)
+	fmt.Printf("\nSimulation tick: %d/%d", sim.engine.Tick(), *duration)
+	fmt.Printf("\nProgress: %s", &runProgress)
+	// charts show the trend of the latest ticks next to each number
+	stats, data := sim.engine.Stats(), sim.data()
+	if row := seriesRow(data, "distance"); row != nil {
+		fmt.Printf("\naverage distance between cultures: %-6d %s", stats.Distance, sparkline(row, *chartWidth))
+	}
+	if row := seriesRow(data, "unique"); row != nil {
+		fmt.Printf("\nnumber of unique cultures        : %-6d %s", stats.Unique, sparkline(row, *chartWidth))
+	}
+	if row := seriesRow(data, "change"); row != nil {
+		fmt.Printf("\nnumber of cultural exchanges     : %-6d %s", stats.Exchanges, sparkline(row, *chartWidth))
+	}
+	fmt.Println()
+	if *conquest > 0 {
+		fmt.Println("number of cells conquered        :", stats.Conquered)
+	}
+	// then the latest measurements of the scenario events, institutions,
+	// minority and any other metrics
+	institutions, i := sim.engine.Institutions(), 0
+	for _, series := range data {
+		name, latest := series[0], series[len(series)-1]
+		switch {
+		case name == "distance" || name == "unique" || name == "change" || name == "conquest" || name == "minority-persistence":
+			// these are shown above
+		case name == "minority":
+			fmt.Println("number of minority cells         :", latest)
+		case strings.HasPrefix(name, "institution-"):
+			fmt.Printf("institution %-21d: %s agreement %s\n", i, labels.Culture(institutions[i]), latest)
+			i++
+		default:
+			fmt.Printf("%-33s: %s\n", name, latest)
+		}
+	}
+	if *showGrid {
+		cultures := sim.cultures()
+		colors, legend := renderView(cultures)
+		if inspecting {
+			// mark the inspected cell by inverting its colour
+			c := colors[cursor]
+			colors[cursor].R, colors[cursor].G, colors[cursor].B = 0xFF-c.R, 0xFF-c.G, 0xFF-c.B
+		}
+		fmt.Print("\n", terminalView(colors, width), terminalLegend(legend))
+		if inspecting {
+			fmt.Print("\n", inspectCell(cultures, cursor))
+		}
+	}
+	if paused {
+		fmt.Println("\n[paused] space to resume, s to advance one tick.")
+	} else {
+		fmt.Println("\nspace to pause.")
+	}
+	fmt.Println("c, p, h, d or 0-5 to view cultures, the palette, diversity, distance or a feature.")
+	if *showGrid {
+		fmt.Println("i to inspect cells, moving between them with the arrow keys.")
+		fmt.Println("+ and - to zoom, W, A, S and D to pan, m to show the minimap.")
+	}
+	fmt.Println("w to save data and a snapshot now, q or Ctrl-c to quit simulation and save data.")
+}
+
+// save a PNG image of the grid if one is due this tick
+func (sim *CultureSim) saveFrame() {
+	if tick := sim.engine.Tick(); *pngEvery > 0 && tick%*pngEvery == 0 {
+		path := fmt.Sprintf("data/frames-%s/%05d.png", sim.name(), tick)
+		if err := savePNG(gridImage(sim.cultures()), path); err != nil {
+			slog.Error("failed saving image", "path", path, "err", err)
+		}
+		addOutput(fmt.Sprintf("data/frames-%s/", sim.name()))
+	}
+}
+
+// cultures of all the cells
+func (sim *CultureSim) cultures() []int {
+	return sim.engine.Cultures()
+}
+
+// name of the simulation used in its output files, with the run ID so
+// repeated runs don't overwrite each other's files
+func (sim *CultureSim) name() string {
+	return fmt.Sprintf("n%d-w%d-c%1.1f-%s", *interactions, width, *coverage, runID)
+}
+
+// columns of the traits of cultures in exports, named after the features in
+// the config's labels or prefix-<feature>
+func traitColumns(prefix string) []string {
+	columns := make([]string, culsim.Features)
+	for f := range columns {
+		if columns[f] = labels.Feature(f); columns[f] == "" {
+			columns[f] = fmt.Sprintf("%s-%d", prefix, f)
+		}
+	}
+	return columns
+}
+
+// traits of a culture in exports, with their labels in the config
+func traitValues(c int) []string {
+	traits := make([]string, culsim.Features)
+	for f := range traits {
+		traits[f] = labels.Trait(f, culsim.FeatureTrait(c, f))
+	}
+	return traits
+}
+
+// column and row of the cell at index n
+func coords(n int) (int, int) {
+	return n % width, n / width
+}
+
+// indexes of the neighbours of the cell at index n under the -topology, the
+// neighbours the engine's cells interact with
+func findNeighbours(n int) []int {
+	return culsim.Neighbours(n, width, width, culsim.Topology(*topology))
+}
+
+// simulation data, a row of each series with its name followed by its
+// values at every tick
+func (sim *CultureSim) data() [][]string {
+	var data [][]string
+	for _, s := range sim.engine.Metrics() {
+		row := []string{s.Name}
+		for _, v := range s.Values {
+			row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		data = append(data, row)
+	}
+	if sim.engine.Params().Config.Minority != nil {
+		data = append(data, []string{"minority-persistence", strconv.Itoa(sim.engine.MinorityPersistence())})
+	}
+	return data
+}
+
+// the row of a series in the simulation data, nil if it isn't recorded
+func seriesRow(data [][]string, name string) []string {
+	for _, row := range data {
+		if row[0] == name {
+			return row
+		}
+	}
+	return nil
+}
+
+// save simulation data
+func (sim *CultureSim) saveData(name string) error {
+	// the average feature distance, number of changes and unique cultures,
+	// then any conquests, scenario event measurements, institution agreement
+	// and minority cells
+	data := sim.data()
+	csvfile, err := os.Create(fmt.Sprintf("data/log-%s.csv", name))
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+
+	for _, line := range data {
+		_ = csvwriter.Write([]string(line))
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(fmt.Sprintf("data/log-%s.csv", name))
+	slog.Info("simulation data saved", "path", fmt.Sprintf("data/log-%s.csv", name))
+	if *analysis {
+		saveAnalysis(name, data)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sausheong/petri"
+)
+
+// the grid of cells, which petri runs and draws in its window
+type grid = petri.Sim
+type cell = petri.Cellular
+
+func init() {
+	width = *petri.Width
+	petri.Label = "Cultural Simulation"
+}
+
+func main() {
+	// culsim render <snapshot-file>... replays recorded runs side by side,
+	// culsim video <snapshot-file> encodes one into a video and
+	// culsim worker -queue <url> runs parameter sets from a job queue,
+	// culsim ls [name=value]... lists the runs in the run index and
+	// culsim validate checks the model against Axelrod's published results,
+	// culsim scaling tabulates its order parameter for finite-size scaling,
+	// culsim sweep runs samples of ranges of parameters,
+	// culsim optimize searches them for the best value of a metric,
+	// culsim calibrate fits them to observed metrics,
+	// culsim analyze sensitivity screens which of them affect a metric most,
+	// culsim gen writes the config of a standard experiment,
+	// culsim twin compares a run with a treated twin and
+	// culsim diverge measures how far replicas of a run drift apart
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && (args[0] == "render" || args[0] == "video" || args[0] == "worker" || args[0] == "ls" ||
+		args[0] == "validate" || args[0] == "scaling" || args[0] == "sweep" ||
+		args[0] == "optimize" || args[0] == "calibrate" || args[0] == "analyze" || args[0] == "gen" ||
+		args[0] == "twin" || args[0] == "diverge") {
+		command, args = args[0], args[1:]
+	}
+	// the analysis or archetype comes before the flags
+	var analysis string
+	if (command == "analyze" || command == "gen") && len(args) > 0 {
+		analysis, args = args[0], args[1:]
+	}
+	_ = flag.CommandLine.Parse(args)
+	setupLogging()
+	if err := applyPreset(); err != nil {
+		fatal("invalid preset", "err", err)
+	}
+	switch command {
+	case "render":
+		if flag.NArg() == 0 {
+			fatal("usage: culsim render [flags] <snapshot-file>...")
+		}
+		runViewer(flag.Args())
+		return
+	case "video":
+		if flag.NArg() != 1 {
+			fatal("usage: culsim video [flags] <snapshot-file>")
+		}
+		exportVideo(flag.Arg(0), *output)
+		return
+	case "worker":
+		if *queueURL == "" {
+			fatal("usage: culsim worker -queue <url> [-poll <duration>]")
+		}
+		runWorker(*queueURL)
+		return
+	case "ls":
+		listRuns(flag.Args())
+		return
+	case "validate":
+		seedRandom()
+		if err := validate(); err != nil {
+			fail("validation failed", err)
+		}
+		return
+	case "scaling":
+		seedRandom()
+		if err := scaling(); err != nil {
+			fail("finite-size scaling failed", err)
+		}
+		report("finished", "", nil)
+		return
+	case "sweep":
+		seedRandom()
+		if err := sweep(); err != nil {
+			fail("sweep failed", err)
+		}
+		report("finished", "", nil)
+		return
+	case "optimize":
+		seedRandom()
+		if err := optimize(); err != nil {
+			fail("optimization failed", err)
+		}
+		report("finished", "", nil)
+		return
+	case "calibrate":
+		seedRandom()
+		if err := calibrate(); err != nil {
+			fail("calibration failed", err)
+		}
+		report("finished", "", nil)
+		return
+	case "gen":
+		if analysis == "" {
+			fmt.Print(genUsage())
+			return
+		}
+		width = *petri.Width
+		seedRandom()
+		if err := generate(analysis, flag.Args()); err != nil {
+			fail("failed generating scenario", err)
+		}
+		report("finished", "", nil)
+		return
+	case "twin":
+		width = *petri.Width
+		seedRandom()
+		if err := twin(); err != nil {
+			fail("twin runs failed", err)
+		}
+		report("finished", "", nil)
+		return
+	case "diverge":
+		width = *petri.Width
+		seedRandom()
+		if err := diverge(); err != nil {
+			fail("divergence failed", err)
+		}
+		report("finished", "", nil)
+		return
+	case "analyze":
+		if analysis != "sensitivity" {
+			fatal("usage: culsim analyze sensitivity -ranges <ranges> [flags]")
+		}
+		seedRandom()
+		if err := sensitivity(); err != nil {
+			fail("sensitivity analysis failed", err)
+		}
+		report("finished", "", nil)
+		return
+	}
+	width = *petri.Width
+	seedRandom()
+	if *renderEvery < 1 {
+		fatal("-render-every must be at least 1")
+	}
+	if *termWidth < 1 || *termHeight < 1 {
+		fatal("-term-width and -term-height must be at least 1")
+	}
+	if *influence != "" && *influence != "cells" && *influence != "domains" {
+		fatal("-influence must be cells or domains")
+	}
+	if *frontShape != "" && *frontShape != "radial" && *frontShape != "planar" {
+		fatal("-front must be radial or planar")
+	}
+	if *traceRun {
+		if err := setupTracing(); err != nil {
+			fatal("failed setting up tracing", "err", err)
+		}
+	}
+	if *natsURI != "" {
+		if err := openStream(*natsURI); err != nil {
+			fatal("failed connecting to NATS", "uri", *natsURI, "err", err)
+		}
+	}
+	if *grpcAddr != "" {
+		serveGRPC(*grpcAddr)
+		return
+	}
+	if *invade > 0 {
+		if err := runInvasion(); err != nil {
+			fail("invasion experiment failed", err)
+		}
+		return
+	}
+	s := &CultureSim{}
+	defer func() {
+		// let the webhook know about crashes too
+		if r := recover(); r != nil {
+			restoreTerminal()
+			report("failed", fmt.Sprint(r), s.metrics())
+			panic(r)
+		}
+	}()
+	listenKeys()
+	if *webAddr != "" {
+		serveWeb(*webAddr)
+	}
+	petri.Run(s)
+}
+
+// change the width of the grid, when replaying recorded runs
+func setWidth(w int) {
+	width, *petri.Width = w, w
+}
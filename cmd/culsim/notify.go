@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+var started = time.Now() // when the program started
+var outputs []string     // files saved by this run
+
+// summary of a run kept in the run index and posted to the -notify-url
+// webhook. Text is what chat webhooks like Slack's show, the other fields
+// are for scripts.
+type summary struct {
+	Text       string            `json:"text"`
+	ID         string            `json:"id"`
+	Started    time.Time         `json:"started"`
+	Seed       int64             `json:"seed"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Parameters map[string]string `json:"parameters"`
+	Metrics    map[string]int    `json:"metrics"`
+	Outputs    []string          `json:"outputs"`
+	Duration   float64           `json:"duration_seconds"`
+}
+
+// note a file saved by this run, for the completion summary
+func addOutput(path string) {
+	for _, p := range outputs {
+		if p == path {
+			return
+		}
+	}
+	outputs = append(outputs, path)
+}
+
+// record the end of the run in the run index, run the exit hooks and post
+// its summary to the -notify-url webhook, status is finished, stopped, timed
+// out or failed
+func report(status, errMsg string, metrics map[string]int) {
+	s := summary{
+		ID:         runID,
+		Started:    started,
+		Seed:       seed,
+		Status:     status,
+		Error:      errMsg,
+		Parameters: make(map[string]string),
+		Metrics:    metrics,
+		Outputs:    outputs,
+		Duration:   time.Since(started).Seconds(),
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		s.Parameters[f.Name] = f.Value.String()
+	})
+	s.Text = fmt.Sprintf("culsim run %s after %s at tick %d", status, time.Since(started).Round(time.Second), metrics["ticks"])
+	if errMsg != "" {
+		s.Text += ": " + errMsg
+	}
+	if err := indexRun(s); err != nil {
+		slog.Error("failed updating the run index", "err", err)
+	}
+	runProgress.write(status)
+	runExitHooks(s)
+	notify(s)
+}
+
+// post a summary of the run to the -notify-url webhook
+func notify(s summary) {
+	if *notifyURL == "" {
+		return
+	}
+	body, err := json.Marshal(s)
+	if err != nil {
+		slog.Error("failed encoding notification", "err", err)
+		return
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(*notifyURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Error("failed sending notification", "url", *notifyURL, "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Error("failed sending notification", "url", *notifyURL, "status", resp.Status)
+	}
+}
+
+// stop a run that cannot go on with the exit code of the error, notifying
+// the webhook first
+func fail(msg string, err error) {
+	report("failed", fmt.Sprintf("%s: %s", msg, err), nil)
+	slog.Error(msg, "err", err)
+	os.Exit(exitCode(err))
+}
+
+// metrics of the latest tick, none before the simulation starts
+func (sim *CultureSim) metrics() map[string]int {
+	if sim.engine == nil {
+		return nil
+	}
+	stats := sim.engine.Stats()
+	return map[string]int{
+		"ticks":    sim.engine.Tick(),
+		"distance": stats.Distance,
+		"changes":  stats.Exchanges,
+		"unique":   stats.Unique,
+		"conquest": stats.Conquered,
+	}
+}
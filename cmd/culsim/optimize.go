@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// a point of the ranges searched and how well its runs met the goal, lower
+// is better
+type candidate struct {
+	point []float64
+	value float64 // mean of the objective over the runs that finished
+	err   float64 // standard error of the value
+	score float64
+	runs  int
+}
+
+// score of a mean value of the objective for the -goal, lower is better:
+// min, max or reaching a number
+func goalScore(goal string, value float64) (float64, error) {
+	switch goal {
+	case "min":
+		return value, nil
+	case "max":
+		return -value, nil
+	}
+	target, err := strconv.ParseFloat(goal, 64)
+	if err != nil {
+		return 0, fmt.Errorf("-goal must be min, max or a number to reach, not %q", goal)
+	}
+	return math.Abs(value - target), nil
+}
+
+// search the -ranges of flags for the values whose runs best meet the -goal
+// for the final value of the -objective metric, with an evolution strategy.
+// The first generation is a Latin hypercube of -samples candidates, and every
+// one of the -generations after keeps the best quarter and fills the rest
+// with mutations of them, smaller every generation. Every candidate is
+// scored by the mean of -repeats runs, and all of them are saved in
+// data/optimize-<run ID>.csv.
+func optimize() error {
+	if *sweepRanges == "" {
+		return invalidError{errors.New("-ranges are needed to optimize")}
+	}
+	ranges, err := parseRanges(*sweepRanges)
+	if err != nil {
+		return invalidError{fmt.Errorf("invalid -ranges: %w", err)}
+	}
+	if *samples < 2 || *generations < 1 || *repeats < 1 {
+		return invalidError{errors.New("-samples must be at least 2 and -generations and -repeats at least 1")}
+	}
+	if _, err = goalScore(*goal, 0); err != nil {
+		return invalidError{err}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	fixed := passedFlags()
+
+	path := fmt.Sprintf("data/optimize-%s.csv", runID)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer csvfile.Close()
+	csvwriter := csv.NewWriter(csvfile)
+	header := []string{"generation"}
+	for _, r := range ranges {
+		header = append(header, r.name)
+	}
+	_ = csvwriter.Write(append(header, "runs", *objective, "score"))
+
+	// score a candidate by the runs that finished, the worst score if none did
+	evaluate := func(gen int, p []float64) (candidate, error) {
+		c := candidate{point: p, score: math.Inf(1)}
+		var sum float64
+		for rep := 0; rep < *repeats; rep++ {
+			result := runSample(self, sampleParams(fixed, ranges, p))
+			if result["status"] != "finished" {
+				slog.Warn("sample failed", "status", result["status"], "err", result["error"])
+				continue
+			}
+			m, _ := result["metrics"].(map[string]interface{})
+			v, ok := m[*objective].(float64)
+			if !ok {
+				return c, invalidError{fmt.Errorf("runs have no metric %q to optimize", *objective)}
+			}
+			sum += v
+			c.runs++
+		}
+		if c.runs > 0 {
+			c.value = sum / float64(c.runs)
+			c.score, _ = goalScore(*goal, c.value)
+		}
+		row := []string{strconv.Itoa(gen)}
+		for d, r := range ranges {
+			row = append(row, r.at(p[d]))
+		}
+		_ = csvwriter.Write(append(row, strconv.Itoa(c.runs), formatFloat(c.value), formatFloat(c.score)))
+		return c, nil
+	}
+
+	population, err := evolve(ranges, *objective, evaluate)
+	if err != nil {
+		return err
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	best := population[0]
+	slog.Info("optimization saved", "path", path, "best", rangeValues(ranges, best.point),
+		*objective, formatFloat(best.value))
+	return nil
+}
+
+// run the evolution strategy of culsim optimize and culsim calibrate on the
+// ranges, returning the last generation from the best candidate. The value
+// of the candidates is logged as name.
+func evolve(ranges []sweepRange, name string, evaluate func(gen int, p []float64) (candidate, error)) ([]candidate, error) {
+	points, _ := samplePoints("lhs", len(ranges), *samples)
+	var population []candidate
+	for gen := 0; gen <= *generations; gen++ {
+		for _, p := range points {
+			c, err := evaluate(gen, p)
+			if err != nil {
+				return nil, err
+			}
+			population = append(population, c)
+		}
+		sort.SliceStable(population, func(i, j int) bool { return population[i].score < population[j].score })
+		best := population[0]
+		slog.Info("generation done", "generation", gen, "best", rangeValues(ranges, best.point),
+			name, formatFloat(best.value), "score", formatFloat(best.score))
+		if gen == *generations {
+			break
+		}
+
+		// the best quarter survive and their mutations make up the rest
+		population = population[:max(1, *samples/4)]
+		sigma := 0.2 / float64(gen+1)
+		points = points[:0]
+		for len(population)+len(points) < *samples {
+			parent := population[rand.Intn(len(population))].point
+			child := make([]float64, len(parent))
+			for d, u := range parent {
+				child[d] = math.Min(1, math.Max(0, u+rand.NormFloat64()*sigma))
+			}
+			points = append(points, child)
+		}
+	}
+	return population, nil
+}
+
+// values of the ranges at the point p, such as n=300 c=0.8
+func rangeValues(ranges []sweepRange, p []float64) string {
+	var s string
+	for d, r := range ranges {
+		if d > 0 {
+			s += " "
+		}
+		s += r.name + "=" + r.at(p[d])
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// metastable states of a run: plateaus of -plateau ticks or more in which
+// no metric moved more than -plateau-tolerance of its level
+type plateaus struct {
+	names  []string    // metrics followed
+	window [][]float64 // values of each metric in the latest ticks
+	level  []float64   // values of each metric the current plateau holds at, nil outside one
+	start  int         // tick the current plateau started
+	rows   [][]string  // plateaus that ended
+}
+
+// follow the metrics of every tick, logging when the run enters and leaves a
+// metastable state
+func (sim *CultureSim) recordPlateaus(m culsim.Message) {
+	t, ok := m.(culsim.TickCompleted)
+	if !ok {
+		return
+	}
+	p := &sim.plateaus
+	names, values := sim.engine.Latest()
+	if len(names) == 0 {
+		return
+	}
+	if len(names) != len(p.names) {
+		// the metrics recorded changed, start over
+		p.names, p.window, p.level = names, make([][]float64, len(names)), nil
+	}
+	for i, v := range values {
+		p.window[i] = append(p.window[i], v)
+		if len(p.window[i]) > *plateau {
+			p.window[i] = p.window[i][1:]
+		}
+	}
+	if p.level != nil {
+		var moved []string
+		for i, v := range values {
+			if !steady(v, p.level[i]) {
+				moved = append(moved, names[i])
+			}
+		}
+		if len(moved) > 0 {
+			cause := sim.perturbations(t)
+			slog.Info("metastable state left", "start", p.start, "tick", t.Tick, "ticks", t.Tick-p.start,
+				"metrics", strings.Join(moved, " "), "cause", cause)
+			p.rows = append(p.rows, []string{strconv.Itoa(p.start), strconv.Itoa(t.Tick), strconv.Itoa(t.Tick - p.start),
+				strings.Join(moved, " "), cause})
+			p.level = nil
+		}
+		return
+	}
+	if len(p.window[0]) < *plateau {
+		return
+	}
+	level := make([]float64, len(names))
+	for i, w := range p.window {
+		for _, v := range w {
+			level[i] += v / float64(len(w))
+		}
+		for _, v := range w {
+			if !steady(v, level[i]) {
+				return
+			}
+		}
+	}
+	p.level, p.start = level, t.Tick-*plateau+1
+	slog.Info("metastable state entered", "start", p.start, "tick", t.Tick)
+}
+
+// check if a value is within -plateau-tolerance of a level, relative to the
+// level or to 1 for levels near 0
+func steady(v, level float64) bool {
+	return math.Abs(v-level) <= *plateauTol*math.Max(1, math.Abs(level))
+}
+
+// what perturbed the grid in a tick: the scenario events that happened in
+// it, noise and conquest, or the interactions of the model alone
+func (sim *CultureSim) perturbations(t culsim.TickCompleted) string {
+	var causes []string
+	if cfg := sim.engine.Params().Config; cfg != nil {
+		for i := range cfg.Events {
+			if cfg.Events[i].Active(t.Tick) {
+				causes = append(causes, fmt.Sprintf("%s-%d", cfg.Events[i].Type, i))
+			}
+		}
+	}
+	if t.Stats.Mutations > 0 {
+		causes = append(causes, "noise")
+	}
+	if t.Stats.Conquered > 0 {
+		causes = append(causes, "conquest")
+	}
+	if len(causes) == 0 {
+		return "interactions"
+	}
+	return strings.Join(causes, " ")
+}
+
+// save the metastable states of the run in data/plateaus-<name>.csv, with
+// the one the run ended in, if any, left without an end
+func (sim *CultureSim) savePlateaus(name string) error {
+	p := &sim.plateaus
+	rows := p.rows
+	if p.level != nil {
+		rows = append(rows[:len(rows):len(rows)], []string{strconv.Itoa(p.start), "", strconv.Itoa(sim.engine.Tick() - p.start + 1), "", ""})
+	}
+	path := fmt.Sprintf("data/plateaus-%s.csv", name)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"start", "end", "ticks", "metrics", "cause"})
+	_ = csvwriter.WriteAll(rows)
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("metastable states saved", "path", path, "plateaus", len(rows))
+	return nil
+}
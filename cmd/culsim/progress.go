@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sausheong/culsim"
+)
+
+// how often the -status-file is written when -progress doesn't say
+const statusEvery = 10 * time.Second
+
+// progress of a run or experiment towards its last tick, measured from when
+// this process started on it so runs carried on from a checkpoint get an
+// honest rate
+type progress struct {
+	started  time.Time // when counting started
+	from     int       // ticks done when counting started
+	done     int       // ticks done so far
+	total    int       // ticks to do in all
+	reported time.Time // when progress was last reported
+}
+
+// the progress of the run or invasion experiment of the command
+var runProgress progress
+
+// the progress written to the -status-file
+type progressStatus struct {
+	Status   string    `json:"status"` // running, or how the run ended
+	Tick     int       `json:"tick"`
+	Total    int       `json:"total"`
+	Percent  float64   `json:"percent"`
+	Rate     float64   `json:"ticks_per_second"`
+	ETA      float64   `json:"eta_seconds"`
+	Elapsed  float64   `json:"elapsed_seconds"`
+	Updated  time.Time `json:"updated"`
+	Started  time.Time `json:"started"`
+	RunID    string    `json:"id"`
+	Progress string    `json:"text"`
+}
+
+// follow the progress of the run of the command through its ticks
+func trackProgress(m culsim.Message) {
+	if t, ok := m.(culsim.TickCompleted); ok {
+		runProgress.update(t.Tick)
+	}
+}
+
+// start counting progress towards total ticks, done of them already
+func (p *progress) start(done, total int) {
+	now := time.Now()
+	*p = progress{started: now, from: done, done: done, total: total, reported: now}
+}
+
+// note the ticks done so far, reporting progress if it is due
+func (p *progress) update(done int) {
+	p.done = done
+	every := *progressEvery
+	if every <= 0 && *statusFile != "" {
+		every = statusEvery
+	}
+	if every <= 0 || time.Since(p.reported) < every {
+		return
+	}
+	p.reported = time.Now()
+	if *progressEvery > 0 {
+		slog.Info("progress", "tick", p.done, "total", p.total, "percent", fmt.Sprintf("%.1f", p.percent()),
+			"ticks_per_second", fmt.Sprintf("%.1f", p.rate()), "eta", p.eta())
+	}
+	p.write("running")
+}
+
+// percentage of the ticks done
+func (p *progress) percent() float64 {
+	if p.total <= 0 {
+		return 0
+	}
+	return 100 * float64(min(p.done, p.total)) / float64(p.total)
+}
+
+// ticks done per second since counting started
+func (p *progress) rate() float64 {
+	elapsed := time.Since(p.started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.done-p.from) / elapsed
+}
+
+// estimated time left at the current rate, 0 until there is a rate
+func (p *progress) eta() time.Duration {
+	rate := p.rate()
+	if rate <= 0 || p.done >= p.total {
+		return 0
+	}
+	return (time.Duration(float64(p.total-p.done)/rate) * time.Second).Round(time.Second)
+}
+
+// progress as shown in the terminal display and status file
+func (p *progress) String() string {
+	if p.rate() <= 0 {
+		return fmt.Sprintf("%.0f%%", p.percent())
+	}
+	return fmt.Sprintf("%.0f%% at %.1f ticks/s, %s left", p.percent(), p.rate(), p.eta())
+}
+
+// write the progress to the -status-file, replacing it in one go so readers
+// never see half a file
+func (p *progress) write(state string) {
+	if *statusFile == "" {
+		return
+	}
+	s := progressStatus{
+		Status:   state,
+		Tick:     p.done,
+		Total:    p.total,
+		Percent:  p.percent(),
+		Rate:     p.rate(),
+		ETA:      p.eta().Seconds(),
+		Elapsed:  time.Since(started).Seconds(),
+		Updated:  time.Now(),
+		Started:  started,
+		RunID:    runID,
+		Progress: p.String(),
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(*statusFile), "."+filepath.Base(*statusFile)+".tmp")
+		if err = os.WriteFile(tmp, b, 0644); err == nil {
+			err = os.Rename(tmp, *statusFile)
+		}
+	}
+	if err != nil {
+		slog.Error("failed writing status file", "path", *statusFile, "err", err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// index of every run saved in the data directory
+const indexPath = "data/index.json"
+
+// identifies this run in the index and the names of its files, by when it
+// started and, once it is seeded, a short hash of its parameters and seed
+var runID = fmt.Sprintf("%s-%d", started.Format("20060102T150405"), os.Getpid())
+
+// seed of the random numbers of this run
+var seed int64
+
+// seed the random numbers with -seed, or a random seed that is kept in the
+// run index so the run can be repeated
+func seedRandom() {
+	seed = *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rand.Seed(seed)
+	runID = newRunID(started)
+}
+
+// ID of a run started at t with the flags set now
+func newRunID(t time.Time) string {
+	return fmt.Sprintf("%s-%08x", t.Format("20060102T150405"), paramsHash())
+}
+
+// hash of the flags set, the grid width and the seed, so runs started in the
+// same second only share an ID if they are the same run
+func paramsHash() uint32 {
+	h := fnv.New32a()
+	flag.Visit(func(f *flag.Flag) {
+		fmt.Fprintf(h, "%s=%s\n", f.Name, f.Value)
+	})
+	fmt.Fprintf(h, "width=%d\nseed=%d\n", width, seed)
+	return h.Sum32()
+}
+
+// add a run to the index, replacing any earlier entry of the same run
+func indexRun(s summary) error {
+	unlock, err := lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	runs, err := readIndex()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range runs {
+		if runs[i].ID == s.ID {
+			runs[i], replaced = s, true
+		}
+	}
+	if !replaced {
+		runs = append(runs, s)
+	}
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	// replace the index in one step so readers never see half of it
+	tmp := indexPath + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, indexPath)
+}
+
+// runs in the index, none if there is no index yet
+func readIndex() ([]summary, error) {
+	data, err := os.ReadFile(indexPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []summary
+	if err = json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("corrupt run index %s: %w", indexPath, err)
+	}
+	return runs, nil
+}
+
+// stop runs ending at the same time from overwriting each other's entries,
+// waiting a few seconds for another run's lock before taking it over
+func lockIndex() (func(), error) {
+	lock := indexPath + ".lock"
+	for i := 0; ; i++ {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if i == 50 {
+			os.Remove(lock)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// list the runs in the index matching all the filters, which compare a
+// parameter to a value, such as n=500 or c=0.7
+func listRuns(filters []string) {
+	runs, err := readIndex()
+	if err != nil {
+		fatal("failed reading the run index", "err", err)
+	}
+	var since time.Time
+	if *sinceFlag != "" {
+		if since, err = time.ParseInLocation("2006-01-02", *sinceFlag, time.Local); err != nil {
+			fatal("invalid -since date, use YYYY-MM-DD", "since", *sinceFlag)
+		}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tSTATUS\tSEED\tPARAMETERS\tOUTPUTS")
+	for _, run := range runs {
+		if run.Started.Before(since) || !matches(run, filters) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", run.ID, run.Started.Local().Format("2006-01-02 15:04"),
+			run.Status, run.Seed, changedParameters(run), strings.Join(run.Outputs, " "))
+	}
+	w.Flush()
+}
+
+// whether a run's parameters match all the filters, numbers are compared by
+// value so c=0.70 matches c=0.7
+func matches(run summary, filters []string) bool {
+	for _, f := range filters {
+		name, value, ok := strings.Cut(f, "=")
+		if !ok {
+			fatal("invalid filter, use name=value", "filter", f)
+		}
+		param, found := run.Parameters[strings.TrimPrefix(name, "-")]
+		if !found {
+			return false
+		}
+		a, errA := strconv.ParseFloat(param, 64)
+		b, errB := strconv.ParseFloat(value, 64)
+		if errA == nil && errB == nil {
+			if a != b {
+				return false
+			}
+		} else if param != value {
+			return false
+		}
+	}
+	return true
+}
+
+// parameters of a run that differ from their defaults
+func changedParameters(run summary) string {
+	var changed []string
+	for name, value := range run.Parameters {
+		if f := flag.Lookup(name); f == nil || f.DefValue != value {
+			changed = append(changed, fmt.Sprintf("%s=%s", name, value))
+		}
+	}
+	sort.Strings(changed)
+	return strings.Join(changed, " ")
+}
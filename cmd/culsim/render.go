@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// PALETTE holds perceptually distinct colours for the most common cultures,
+// from Kelly's colours of maximum contrast
+var PALETTE = []color.RGBA{
+	{0xF3, 0xC3, 0x00, 0xFF}, {0x87, 0x56, 0x92, 0xFF}, {0xF3, 0x84, 0x00, 0xFF}, {0xA1, 0xCA, 0xF1, 0xFF},
+	{0xBE, 0x00, 0x32, 0xFF}, {0xC2, 0xB2, 0x80, 0xFF}, {0x84, 0x84, 0x82, 0xFF}, {0x00, 0x88, 0x56, 0xFF},
+	{0xE6, 0x8F, 0xAC, 0xFF}, {0x00, 0x67, 0xA5, 0xFF}, {0xF9, 0x93, 0x79, 0xFF}, {0x60, 0x4E, 0x97, 0xFF},
+	{0xF6, 0xA6, 0x00, 0xFF}, {0xB3, 0x44, 0x6C, 0xFF}, {0xDC, 0xD3, 0x00, 0xFF}, {0x88, 0x2D, 0x17, 0xFF},
+	{0x8D, 0xB6, 0x00, 0xFF}, {0x65, 0x45, 0x22, 0xFF}, {0xE2, 0x58, 0x22, 0xFF}, {0x2B, 0x3D, 0x26, 0xFF},
+}
+
+// HEATMAP is the colour ramp of the heatmap views, from low to high (viridis)
+var HEATMAP = []color.RGBA{
+	{0x44, 0x01, 0x54, 0xFF}, {0x3B, 0x52, 0x8B, 0xFF}, {0x21, 0x90, 0x8C, 0xFF}, {0x5D, 0xC9, 0x63, 0xFF}, {0xFD, 0xE7, 0x25, 0xFF},
+}
+
+var gray = color.RGBA{0xC8, 0xC8, 0xC8, 0xFF}  // cultures outside the palette
+var white = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF} // empty cells
+
+// an entry in the legend of a rendered view
+type legendEntry struct {
+	label string
+	color color.RGBA
+	count int
+}
+
+// label of the legend entry, with its number of cells if it counts any
+func (e legendEntry) String() string {
+	if e.count == 0 {
+		return e.label
+	}
+	return fmt.Sprintf("%-8s %d cells", e.label, e.count)
+}
+
+// colour of a culture as it is stored
+func rgb(culture int) color.RGBA {
+	return color.RGBA{uint8(culture >> 16), uint8(culture >> 8), uint8(culture), 0xFF}
+}
+
+// colours of the cells of a grid of cultures in the current view, and the
+// legend explaining them
+func renderView(cultures []int) ([]color.RGBA, []legendEntry) {
+	colors := make([]color.RGBA, len(cultures))
+	switch *view {
+	case "palette":
+		return renderPalette(cultures)
+	case "feature":
+		return renderFeature(cultures, *viewFeature)
+	case "diversity", "distance":
+		return renderHeat(cultures, *view)
+	default:
+		for n, c := range cultures {
+			colors[n] = rgb(c)
+		}
+	}
+	return colors, nil
+}
+
+// give the most common cultures distinct palette colours and the rest gray
+func renderPalette(cultures []int) ([]color.RGBA, []legendEntry) {
+	counts := make(map[int]int)
+	for _, c := range cultures {
+		if c != culsim.Empty {
+			counts[c]++
+		}
+	}
+	ranked := make([]int, 0, len(counts))
+	for c := range counts {
+		ranked = append(ranked, c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if counts[ranked[i]] != counts[ranked[j]] {
+			return counts[ranked[i]] > counts[ranked[j]]
+		}
+		return ranked[i] < ranked[j]
+	})
+	top := *paletteSize
+	if top > len(PALETTE) {
+		top = len(PALETTE)
+	}
+	if top > len(ranked) {
+		top = len(ranked)
+	}
+	mapped := make(map[int]color.RGBA)
+	var legend []legendEntry
+	for i, c := range ranked[:top] {
+		mapped[c] = PALETTE[i]
+		legend = append(legend, legendEntry{labels.Culture(c), PALETTE[i], counts[c]})
+	}
+	var others int
+	for _, c := range ranked[top:] {
+		others += counts[c]
+	}
+	if others > 0 {
+		legend = append(legend, legendEntry{"others", gray, others})
+	}
+
+	colors := make([]color.RGBA, len(cultures))
+	for n, c := range cultures {
+		switch col, ok := mapped[c]; {
+		case c == culsim.Empty:
+			colors[n] = white
+		case ok:
+			colors[n] = col
+		default:
+			colors[n] = gray
+		}
+	}
+	return colors, legend
+}
+
+// colour cells by their trait in one feature, with a palette colour for each
+// trait value
+func renderFeature(cultures []int, f int) ([]color.RGBA, []legendEntry) {
+	colors := make([]color.RGBA, len(cultures))
+	var counts [0x10]int
+	for n, c := range cultures {
+		if c == culsim.Empty {
+			colors[n] = white
+			continue
+		}
+		trait := culsim.FeatureTrait(c, f)
+		colors[n] = PALETTE[trait]
+		counts[trait]++
+	}
+	var legend []legendEntry
+	for trait, count := range counts {
+		if count > 0 {
+			label := fmt.Sprintf("f%d=%X", f, trait)
+			if len(labels) > 0 {
+				label = traitColumns("f")[f] + "=" + labels.Trait(f, trait)
+			}
+			legend = append(legend, legendEntry{label, PALETTE[trait], count})
+		}
+	}
+	return colors, legend
+}
+
+// colour cells by the local diversity of their neighbourhood, either the
+// number of distinct cultures among the cell and its neighbours or the
+// average feature distance to its neighbours
+func renderHeat(cultures []int, mode string) ([]color.RGBA, []legendEntry) {
+	colors := make([]color.RGBA, len(cultures))
+	values := make([]float64, len(cultures))
+	for n, c := range cultures {
+		if c == culsim.Empty {
+			values[n] = -1
+			continue
+		}
+		neighbours := findNeighbours(n)
+		if mode == "diversity" {
+			distinct := map[int]bool{c: true}
+			for _, neighbour := range neighbours {
+				if cultures[neighbour] != culsim.Empty {
+					distinct[cultures[neighbour]] = true
+				}
+			}
+			values[n] = float64(len(distinct))
+		} else {
+			var dist, count int
+			for _, neighbour := range neighbours {
+				if cultures[neighbour] != culsim.Empty {
+					dist += culsim.Features - culsim.SharedFeatures(c, cultures[neighbour])
+					count++
+				}
+			}
+			if count > 0 {
+				values[n] = float64(dist) / float64(count)
+			}
+		}
+	}
+	// the scale runs from no diversity to the most the neighbourhood allows
+	low, high := 1.0, float64(len(findNeighbours(width+1))+1)
+	if mode == "distance" {
+		low, high = 0, float64(culsim.Features)
+	}
+	for n, v := range values {
+		if v < 0 {
+			colors[n] = white
+		} else {
+			colors[n] = heat((v - low) / (high - low))
+		}
+	}
+	legend := make([]legendEntry, 0, len(HEATMAP))
+	for i := range HEATMAP {
+		v := low + (high-low)*float64(i)/float64(len(HEATMAP)-1)
+		legend = append(legend, legendEntry{fmt.Sprintf("%s %.1f", mode, v), HEATMAP[i], 0})
+	}
+	return colors, legend
+}
+
+// colour of a value between 0 and 1 on the heatmap ramp
+func heat(v float64) color.RGBA {
+	v = math.Max(0, math.Min(1, v)) * float64(len(HEATMAP)-1)
+	i := int(v)
+	if i >= len(HEATMAP)-1 {
+		return HEATMAP[len(HEATMAP)-1]
+	}
+	f := v - float64(i)
+	a, b := HEATMAP[i], HEATMAP[i+1]
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xFF}
+}
+
+// render a grid of colours w cells wide for a 24-bit colour terminal, two
+// rows of cells per line of text
+func terminalGrid(colors []color.RGBA, w int) string {
+	var b strings.Builder
+	h := len(colors) / w
+	for y := 0; y < h; y += 2 {
+		for x := 0; x < w; x++ {
+			top, bottom := colors[y*w+x], white
+			if y+1 < h {
+				bottom = colors[(y+1)*w+x]
+			}
+			fmt.Fprintf(&b, "\033[38;2;%d;%d;%dm\033[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		b.WriteString("\033[0m\n")
+	}
+	return b.String()
+}
+
+// render the part of a grid of colours w cells wide in the viewport for a
+// colour terminal, followed by the minimap if only part of the grid is shown
+func terminalView(colors []color.RGBA, w int) string {
+	cropped, cw := port.crop(colors, w)
+	view := terminalGrid(cropped, cw)
+	if minimap && !port.whole(w, len(colors)/w) {
+		vw, vh := port.size(w, len(colors)/w)
+		view += fmt.Sprintf("\ncells (%d,%d) to (%d,%d), zoom 1:%d\n%s\n",
+			port.x, port.y, port.x+vw-1, port.y+vh-1, port.zoom, port.minimap(colors, w, 32))
+	}
+	return view
+}
+
+// render the legend for a colour terminal, one entry per line
+func terminalLegend(legend []legendEntry) string {
+	var b strings.Builder
+	for _, e := range legend {
+		fmt.Fprintf(&b, "\033[38;2;%d;%d;%dm██\033[0m %s\n", e.color.R, e.color.G, e.color.B, e.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// run Axelrod's model on grids of each of the -sizes for each of the
+// -scaling-traits, -replicates times each until the grid is stable, and
+// save the order parameter, the largest domain's share of the grid, with
+// the moments finite-size scaling needs in data/scaling-<run ID>.csv. Every
+// tick has an interaction per cell, so ticks mean the same on every grid.
+func scaling() error {
+	if *replicates < 2 {
+		return invalidError{errors.New("-replicates must be at least 2 for finite-size scaling")}
+	}
+	sizes, err := parseInts(*scalingSizes)
+	if err != nil {
+		return invalidError{fmt.Errorf("invalid -sizes: %w", err)}
+	}
+	traits, err := parseInts(*scalingTraits)
+	if err != nil {
+		return invalidError{fmt.Errorf("invalid -scaling-traits: %w", err)}
+	}
+	if *nu <= 0 {
+		return invalidError{errors.New("-nu must be positive")}
+	}
+
+	path := fmt.Sprintf("data/scaling-%s.csv", runID)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer csvfile.Close()
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"size", "features", "traits", "replicates", "order", "order_sd", "susceptibility",
+		"binder", "regions", "scaled_traits", "scaled_order"})
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tTRAITS\tORDER\tSTDDEV\tSUSCEPTIBILITY\tBINDER\tREGIONS")
+	for _, size := range sizes {
+		for _, q := range traits {
+			// moments of the order parameter over the replicates
+			cells := float64(size * size)
+			var s1, s2, s4, regions float64
+			for rep := 0; rep < *replicates; rep++ {
+				// every replicate has its own seed, drawn from the run's seed
+				domains, err := stableDomains(size, *scalingFeatures, q, size*size, rand.Int63())
+				if err != nil {
+					return err
+				}
+				var largest int
+				for _, d := range domains {
+					largest = max(largest, d)
+				}
+				s := float64(largest) / cells
+				s1, s2, s4 = s1+s, s2+s*s, s4+s*s*s*s
+				regions += float64(len(domains))
+			}
+			n := float64(*replicates)
+			s1, s2, s4, regions = s1/n, s2/n, s4/n, regions/n
+			sd := math.Sqrt(math.Max(0, s2-s1*s1) * n / (n - 1))
+			chi := cells * (s2 - s1*s1)
+			binder := 1 - s4/(3*s2*s2)
+			// with the critical traits -qc and the exponents -nu and -beta,
+			// curves of every size collapse onto one
+			l := float64(size)
+			x := (float64(q) - *qc) * math.Pow(l, 1 / *nu)
+			y := s1 * math.Pow(l, *beta / *nu)
+			slog.Info("scaled", "size", size, "traits", q, "order", s1, "susceptibility", chi, "binder", binder)
+			fmt.Fprintf(w, "%d\t%d\t%.3f\t%.3f\t%.2f\t%.3f\t%.1f\n", size, q, s1, sd, chi, binder, regions)
+			_ = csvwriter.Write([]string{strconv.Itoa(size), strconv.Itoa(*scalingFeatures), strconv.Itoa(q),
+				strconv.Itoa(*replicates), formatFloat(s1), formatFloat(sd), formatFloat(chi), formatFloat(binder),
+				formatFloat(regions), formatFloat(x), formatFloat(y)})
+		}
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), w.Flush()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("scaling table saved", "path", path)
+	return nil
+}
+
+// parse a comma-separated list of positive integers
+func parseInts(list string) ([]int, error) {
+	var ns []int
+	for _, s := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("%d is not positive", n)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+// shortest decimal form of a float
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// levels of the ranges the trajectories of Morris screening step between,
+// and the step, so every step from the lower half lands in the upper half
+var morrisLevels = []float64{0, 1.0 / 3, 2.0 / 3, 1}
+
+const morrisStep = 2.0 / 3
+
+// screen how much each of the -ranges of flags affects the final value of
+// the -objective metric with Morris's elementary effects. Each of -samples
+// trajectories starts at random levels of the ranges and moves one range at
+// a time, in random order, so every step measures the effect of one range.
+// The effects are in the metric's units per whole range, and the mean of
+// their absolute values, mu*, ranks the ranges, while their standard
+// deviation shows interactions with other ranges or non-linear effects.
+// They are saved in data/sensitivity-<run ID>.csv.
+func sensitivity() error {
+	if *sweepRanges == "" {
+		return invalidError{errors.New("-ranges are needed for sensitivity analysis")}
+	}
+	ranges, err := parseRanges(*sweepRanges)
+	if err != nil {
+		return invalidError{fmt.Errorf("invalid -ranges: %w", err)}
+	}
+	if *samples < 2 || *repeats < 1 {
+		return invalidError{errors.New("-samples must be at least 2 and -repeats at least 1")}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	fixed := passedFlags()
+
+	// mean of the objective over the runs of a point that finished, NaN if
+	// none did
+	measure := func(p []float64) (float64, error) {
+		var sum float64
+		var runs int
+		for rep := 0; rep < *repeats; rep++ {
+			result := runSample(self, sampleParams(fixed, ranges, p))
+			if result["status"] != "finished" {
+				slog.Warn("sample failed", "status", result["status"], "err", result["error"])
+				continue
+			}
+			m, _ := result["metrics"].(map[string]interface{})
+			v, ok := m[*objective].(float64)
+			if !ok {
+				return 0, invalidError{fmt.Errorf("runs have no metric %q to analyze", *objective)}
+			}
+			sum, runs = sum+v, runs+1
+		}
+		if runs == 0 {
+			return math.NaN(), nil
+		}
+		return sum / float64(runs), nil
+	}
+
+	effects := make([][]float64, len(ranges))
+	for t := 0; t < *samples; t++ {
+		p := make([]float64, len(ranges))
+		for d := range p {
+			p[d] = morrisLevels[rand.Intn(len(morrisLevels))]
+		}
+		y, err := measure(p)
+		if err != nil {
+			return err
+		}
+		for _, d := range rand.Perm(len(ranges)) {
+			step := morrisStep
+			if p[d] > 0.5 {
+				step = -step
+			}
+			p[d] += step
+			next, err := measure(p)
+			if err != nil {
+				return err
+			}
+			if !math.IsNaN(y) && !math.IsNaN(next) {
+				effects[d] = append(effects[d], (next-y)/step)
+			}
+			y = next
+		}
+		slog.Info("trajectory done", "trajectory", t+1, "of", *samples)
+	}
+
+	type screening struct {
+		name           string
+		mu, muStar, sd float64
+		effects        int
+	}
+	screened := make([]screening, len(ranges))
+	for d, r := range ranges {
+		s := screening{name: r.name, effects: len(effects[d])}
+		for _, e := range effects[d] {
+			s.mu += e
+			s.muStar += math.Abs(e)
+		}
+		n := float64(len(effects[d]))
+		s.mu, s.muStar = s.mu/n, s.muStar/n
+		for _, e := range effects[d] {
+			s.sd += (e - s.mu) * (e - s.mu)
+		}
+		s.sd = math.Sqrt(s.sd / (n - 1))
+		screened[d] = s
+	}
+	sort.SliceStable(screened, func(i, j int) bool { return screened[i].muStar > screened[j].muStar })
+
+	path := fmt.Sprintf("data/sensitivity-%s.csv", runID)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write([]string{"parameter", "effects", "mu_star", "mu", "sigma"})
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PARAMETER\tMU*\tMU\tSIGMA")
+	for _, s := range screened {
+		fmt.Fprintf(w, "%s\t%.3f\t%.3f\t%.3f\n", s.name, s.muStar, s.mu, s.sd)
+		_ = csvwriter.Write([]string{s.name, strconv.Itoa(s.effects), formatFloat(s.muStar), formatFloat(s.mu),
+			formatFloat(s.sd)})
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), csvfile.Close(), w.Flush()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("sensitivity saved", "path", path, "objective", *objective)
+	return nil
+}
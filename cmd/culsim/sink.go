@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// version of the JSON lines and databases the sinks write. Files of earlier
+// versions are still read: version 1 JSON lines had no header line with the
+// version and grid width, and version 1 databases had no user_version.
+const sinkVersion = 2
+
+// Sink receives the data of a run as it goes: the metrics of every tick and
+// snapshots of the grid
+type Sink interface {
+	WriteTick(tick int, names []string, values []float64) error
+	WriteSnapshot(tick int, cultures []int) error
+	Close() error
+}
+
+// open the sinks of a comma-separated list of kinds: csv, jsonl, sqlite or
+// stdout, writing to all of them at once
+func openSinks(kinds, name string) (Sink, error) {
+	var sinks multiSink
+	for _, kind := range strings.Split(kinds, ",") {
+		var s Sink
+		var err error
+		switch kind {
+		case "csv":
+			s, err = newCSVSink(name)
+		case "jsonl":
+			s, err = newJSONLSink(fmt.Sprintf("data/run-%s.jsonl", name))
+		case "sqlite":
+			s, err = newSQLiteSink(fmt.Sprintf("data/run-%s.db", name))
+		case "stdout":
+			s, err = newJSONLWriter(os.Stdout, nil)
+		default:
+			err = fmt.Errorf("unknown sink %q, use csv, jsonl, sqlite or stdout", kind)
+		}
+		if err != nil {
+			_ = sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// check if the sinks of a comma-separated list of kinds write to stdout
+func sinksStdout(kinds string) bool {
+	for _, kind := range strings.Split(kinds, ",") {
+		if kind == "stdout" {
+			return true
+		}
+	}
+	return false
+}
+
+// sinks that all receive the same data
+type multiSink []Sink
+
+func (m multiSink) WriteTick(tick int, names []string, values []float64) error {
+	var err error
+	for _, s := range m {
+		err = errors.Join(err, s.WriteTick(tick, names, values))
+	}
+	return err
+}
+
+func (m multiSink) WriteSnapshot(tick int, cultures []int) error {
+	var err error
+	for _, s := range m {
+		err = errors.Join(err, s.WriteSnapshot(tick, cultures))
+	}
+	return err
+}
+
+func (m multiSink) Close() error {
+	var err error
+	for _, s := range m {
+		err = errors.Join(err, s.Close())
+	}
+	return err
+}
+
+// CSV files of the metrics in data/ticks-<name>.csv, a row per tick, and of
+// the grid in data/grids-<name>.csv, in the format of CSV snapshots
+type csvSink struct {
+	ticksFile, gridsFile *os.File
+	ticks, grids         *csv.Writer
+	header               bool // the header of the ticks file is written
+}
+
+func newCSVSink(name string) (*csvSink, error) {
+	ticksFile, err := os.Create(fmt.Sprintf("data/ticks-%s.csv", name))
+	if err != nil {
+		return nil, err
+	}
+	gridsFile, err := os.Create(fmt.Sprintf("data/grids-%s.csv", name))
+	if err != nil {
+		ticksFile.Close()
+		return nil, err
+	}
+	addOutput(ticksFile.Name())
+	addOutput(gridsFile.Name())
+	s := &csvSink{ticksFile: ticksFile, gridsFile: gridsFile, ticks: csv.NewWriter(ticksFile), grids: csv.NewWriter(gridsFile)}
+	return s, s.grids.Write(snapshotHeader())
+}
+
+func (s *csvSink) WriteTick(tick int, names []string, values []float64) error {
+	if !s.header {
+		_ = s.ticks.Write(append([]string{"tick"}, names...))
+		s.header = true
+	}
+	row := []string{strconv.Itoa(tick)}
+	for _, v := range values {
+		row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return s.ticks.Write(row)
+}
+
+func (s *csvSink) WriteSnapshot(tick int, cultures []int) error {
+	return s.grids.Write(snapshotRow(tick, cultures))
+}
+
+func (s *csvSink) Close() error {
+	s.ticks.Flush()
+	s.grids.Flush()
+	return errors.Join(s.ticks.Error(), s.grids.Error(), s.ticksFile.Close(), s.gridsFile.Close())
+}
+
+// JSON lines of the metrics of every tick and the snapshots of the grid,
+// after a header line with the version and the grid width
+type jsonlSink struct {
+	w    *bufio.Writer
+	file *os.File // nil when writing to stdout
+}
+
+// a line of a JSONL sink
+type sinkLine struct {
+	Type     string             `json:"type"` // tick or snapshot
+	Tick     int                `json:"tick"`
+	Metrics  map[string]float64 `json:"metrics,omitempty"`
+	Cultures []int              `json:"cultures,omitempty"`
+}
+
+// the first line of a JSONL sink
+type sinkHeader struct {
+	Type    string `json:"type"` // header
+	Version int    `json:"version"`
+	Width   int    `json:"width"`
+}
+
+func newJSONLSink(path string) (*jsonlSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	addOutput(path)
+	return newJSONLWriter(file, file)
+}
+
+// start writing JSON lines to w with the header line
+func newJSONLWriter(w io.Writer, file *os.File) (*jsonlSink, error) {
+	s := &jsonlSink{w: bufio.NewWriter(w), file: file}
+	return s, s.write(sinkHeader{"header", sinkVersion, width})
+}
+
+func (s *jsonlSink) WriteTick(tick int, names []string, values []float64) error {
+	metrics := make(map[string]float64, len(names))
+	for i, name := range names {
+		metrics[name] = values[i]
+	}
+	return s.write(sinkLine{Type: "tick", Tick: tick, Metrics: metrics})
+}
+
+func (s *jsonlSink) WriteSnapshot(tick int, cultures []int) error {
+	return s.write(sinkLine{Type: "snapshot", Tick: tick, Cultures: cultures})
+}
+
+func (s *jsonlSink) write(line interface{}) error {
+	b, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	_, _ = s.w.Write(b)
+	return s.w.WriteByte('\n')
+}
+
+func (s *jsonlSink) Close() error {
+	err := s.w.Flush()
+	if s.file != nil {
+		err = errors.Join(err, s.file.Close())
+	}
+	return err
+}
+
+// read the snapshots of the grid in the JSON lines of a run
+func readJSONLSnapshots(path string) (int, []snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+	var w int
+	var snapshots []snapshot
+	for n := 1; scanner.Scan(); n++ {
+		var line sinkLine
+		if err = json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return 0, nil, fmt.Errorf("%s line %d: %w", path, n, err)
+		}
+		switch line.Type {
+		case "header":
+			var header sinkHeader
+			_ = json.Unmarshal(scanner.Bytes(), &header)
+			if err = checkVersion("run", strconv.Itoa(header.Version), sinkVersion); err != nil {
+				return 0, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			w = header.Width
+		case "snapshot":
+			// version 1 files have no header, their grids are square
+			if w == 0 {
+				w = int(math.Sqrt(float64(len(line.Cultures))))
+			}
+			if w <= 0 || len(line.Cultures) != w*w {
+				return 0, nil, fmt.Errorf("%s line %d: expected %d cells", path, n, w*w)
+			}
+			snapshots = append(snapshots, snapshot{line.Tick, line.Cultures})
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return 0, nil, err
+	}
+	if len(snapshots) == 0 {
+		return 0, nil, fmt.Errorf("%s has no snapshots recorded", path)
+	}
+	return w, snapshots, nil
+}
+
+// SQLite database with a ticks table of the metrics, a row per tick and
+// metric, and a cells table of the snapshots, a row per tick and cell, with
+// its version as the user_version. The SQL statements are piped to the
+// sqlite3 command.
+type sqliteSink struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	w   *bufio.Writer
+}
+
+func newSQLiteSink(path string) (*sqliteSink, error) {
+	// start from an empty database, like the other outputs
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	cmd := exec.Command("sqlite3", "-bail", path)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed starting sqlite3, is it installed? %w", err)
+	}
+	addOutput(path)
+	s := &sqliteSink{cmd: cmd, in: in, w: bufio.NewWriter(in)}
+	_, _ = fmt.Fprintf(s.w, "PRAGMA user_version = %d;\n", sinkVersion)
+	_, _ = s.w.WriteString("CREATE TABLE ticks (tick INTEGER, metric TEXT, value REAL);\n" +
+		"CREATE TABLE cells (tick INTEGER, cell INTEGER, culture INTEGER);\nBEGIN;\n")
+	return s, nil
+}
+
+func (s *sqliteSink) WriteTick(tick int, names []string, values []float64) error {
+	for i, name := range names {
+		_, err := fmt.Fprintf(s.w, "INSERT INTO ticks VALUES (%d, '%s', %s);\n", tick, strings.ReplaceAll(name, "'", "''"),
+			strconv.FormatFloat(values[i], 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteSink) WriteSnapshot(tick int, cultures []int) error {
+	for n, c := range cultures {
+		if _, err := fmt.Fprintf(s.w, "INSERT INTO cells VALUES (%d, %d, %d);\n", tick, n, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteSink) Close() error {
+	_, _ = s.w.WriteString("COMMIT;\n")
+	err := s.w.Flush()
+	return errors.Join(err, s.in.Close(), s.cmd.Wait())
+}
+
+// write the metrics of every tick and the snapshots to the sinks
+func (sim *CultureSim) writeSinks(m culsim.Message) {
+	if sim.sink == nil {
+		return
+	}
+	switch m := m.(type) {
+	case culsim.TickCompleted:
+		names, values := sim.engine.Latest()
+		sim.sinkFailed(sim.sink.WriteTick(m.Tick, names, values))
+	case culsim.SnapshotTaken:
+		sim.sinkFailed(sim.sink.WriteSnapshot(m.Tick, m.Cultures))
+	}
+}
+
+// handle the error of a write to the sinks, giving up on them after the
+// first one
+func (sim *CultureSim) sinkFailed(err error) {
+	if err != nil {
+		slog.Error("failed writing to sinks, no more data is written to them", "err", err)
+		sim.sinkErr = err
+		sim.closeSink()
+	}
+}
+
+// close the sinks, with the error of any failed write
+func (sim *CultureSim) closeSink() error {
+	if sim.sink == nil {
+		return sim.sinkErr
+	}
+	err := sim.sink.Close()
+	sim.sink = nil
+	return errors.Join(sim.sinkErr, err)
+}
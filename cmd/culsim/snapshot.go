@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// version of the snapshot files written. Files of earlier versions are
+// still read: version 1 CSV files had only the width in their header,
+// version 1 .npz archives had no version array and version 2 CSV files had
+// no delta rows.
+const snapshotVersion = 3
+
+// snapshot of the cultures of every cell at a tick
+type snapshot struct {
+	tick     int
+	cultures []int
+}
+
+// file the grid snapshots of a run are recorded in, a CSV file or a NumPy
+// archive
+type snapshotRecorder struct {
+	path     string
+	file     *os.File
+	writer   *csv.Writer
+	archive  *npyArchive
+	previous []int // cultures of the previous snapshot, with -keyframes
+	recorded int   // snapshots recorded
+}
+
+// start recording grid snapshots in data/snapshots-<name>.<format>. The first
+// row of a CSV file holds the version of the file and the grid width, every
+// following row a tick and the cultures of all cells in hex. NumPy .npy files hold a single array of
+// cultures, .npz archives compress it with an array of its ticks.
+func openSnapshots(name string) (*snapshotRecorder, error) {
+	var err error
+	if *keyframes < 0 || *keyframes > 0 && *snapshotFormat != "csv" {
+		return nil, invalidError{errors.New("-keyframes must be at least 0, and only applies to csv snapshots")}
+	}
+	r := &snapshotRecorder{path: fmt.Sprintf("data/snapshots-%s.%s", name, *snapshotFormat)}
+	switch *snapshotFormat {
+	case "csv":
+		if r.file, err = os.Create(r.path); err != nil {
+			return nil, err
+		}
+		r.writer = csv.NewWriter(r.file)
+		err = r.writer.Write(snapshotHeader())
+	case "npy":
+		r.archive, err = createNPY(r.path, width)
+	case "npz":
+		// recorded uncompressed, then compressed when the run ends
+		r.archive, err = createNPY(r.path+".tmp", width)
+	default:
+		return nil, fmt.Errorf("unknown snapshot format %q, use csv, npy or npz", *snapshotFormat)
+	}
+	if err != nil {
+		return nil, err
+	}
+	addOutput(r.path)
+	return r, nil
+}
+
+// record the grid at a tick
+func (r *snapshotRecorder) write(tick int, cultures []int) {
+	switch {
+	case r.writer != nil && *keyframes > 0:
+		_ = r.writer.Write(r.deltaRow(tick, cultures))
+	case r.writer != nil:
+		_ = r.writer.Write(snapshotRow(tick, cultures))
+	case r.archive != nil:
+		if err := r.archive.write(tick, cultures); err != nil {
+			slog.Error("failed recording snapshot", "path", r.path, "err", err)
+		}
+	}
+}
+
+// the row of a snapshot with -keyframes: a keyframe of every cell every
+// -keyframes snapshots, and in between a delta of the tick followed by the
+// cells that changed since the previous snapshot as cell:culture, such as
+// 117:0A3F21, or a keyframe if more than half the cells changed
+func (r *snapshotRecorder) deltaRow(tick int, cultures []int) []string {
+	defer func() { r.previous, r.recorded = append(r.previous[:0], cultures...), r.recorded+1 }()
+	if r.recorded%*keyframes == 0 || len(r.previous) != len(cultures) {
+		return snapshotRow(tick, cultures)
+	}
+	row := []string{strconv.Itoa(tick)}
+	for n, c := range cultures {
+		if c != r.previous[n] {
+			row = append(row, fmt.Sprintf("%d:%06X", n, c))
+		}
+	}
+	if len(row) > len(cultures)/2 {
+		return snapshotRow(tick, cultures)
+	}
+	return row
+}
+
+// finish recording grid snapshots
+func (r *snapshotRecorder) close() {
+	if r.writer != nil {
+		r.writer.Flush()
+		r.file.Close()
+		r.writer, r.file = nil, nil
+	}
+	if r.archive != nil {
+		err := r.archive.close()
+		if err == nil && *snapshotFormat == "npz" {
+			err = r.archive.compress(r.path)
+			os.Remove(r.archive.file.Name())
+		}
+		if err != nil {
+			slog.Error("failed saving snapshots", "path", r.path, "err", err)
+		}
+		r.archive = nil
+	}
+}
+
+// take a snapshot of the grid at the end of every tick with -snapshots
+func (sim *CultureSim) takeSnapshot(m culsim.Message) {
+	if t, ok := m.(culsim.TickCompleted); ok && *snapshots {
+		sim.engine.Bus().Publish(culsim.SnapshotTaken{Tick: t.Tick, Cultures: sim.cultures()})
+	}
+}
+
+// record the snapshots of the grid, if they are being recorded
+func (sim *CultureSim) writeSnapshot(m culsim.Message) {
+	if s, ok := m.(culsim.SnapshotTaken); ok && sim.snapshots != nil {
+		sim.snapshots.write(s.Tick, s.Cultures)
+	}
+}
+
+// finish recording the grid snapshots of the run
+func (sim *CultureSim) closeSnapshots() {
+	if sim.snapshots != nil {
+		sim.snapshots.close()
+		sim.snapshots = nil
+	}
+}
+
+// the first row of a CSV snapshot file
+func snapshotHeader() []string {
+	return []string{"version", strconv.Itoa(snapshotVersion), "width", strconv.Itoa(width)}
+}
+
+// the tick followed by the cultures of all cells in hex
+func snapshotRow(t int, cultures []int) []string {
+	row := make([]string, len(cultures)+1)
+	row[0] = strconv.Itoa(t)
+	for n, c := range cultures {
+		row[n+1] = fmt.Sprintf("%06X", c)
+	}
+	return row
+}
+
+// read the grid width and the snapshots recorded in a file
+func readSnapshots(path string) (int, []snapshot, error) {
+	switch filepath.Ext(path) {
+	case ".npy":
+		return readNPYSnapshots(path)
+	case ".npz":
+		return readNPZSnapshots(path)
+	case ".json":
+		return readCheckpoint(path)
+	case ".jsonl":
+		return readJSONLSnapshots(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s is not a snapshot file", path)
+	}
+	w, err := readSnapshotHeader(header)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var snapshots []snapshot
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		line := len(snapshots) + 2
+		s := snapshot{cultures: make([]int, w*w)}
+		if s.tick, err = strconv.Atoi(row[0]); err != nil {
+			return 0, nil, fmt.Errorf("%s line %d: invalid tick", path, line)
+		}
+		// a delta row holds the cells that changed since the previous row
+		if len(row) == 1 || strings.Contains(row[1], ":") {
+			if len(snapshots) == 0 {
+				return 0, nil, fmt.Errorf("%s line %d: delta before any keyframe", path, line)
+			}
+			copy(s.cultures, snapshots[len(snapshots)-1].cultures)
+			for _, cell := range row[1:] {
+				ns, c, _ := strings.Cut(cell, ":")
+				n, errN := strconv.Atoi(ns)
+				culture, errC := strconv.ParseInt(c, 16, 64)
+				if errN != nil || errC != nil || n < 0 || n >= w*w {
+					return 0, nil, fmt.Errorf("%s line %d: invalid cell %q", path, line, cell)
+				}
+				s.cultures[n] = int(culture)
+			}
+			snapshots = append(snapshots, s)
+			continue
+		}
+		if len(row) != w*w+1 {
+			return 0, nil, fmt.Errorf("%s line %d: expected %d cells", path, line, w*w)
+		}
+		for n, c := range row[1:] {
+			culture, err := strconv.ParseInt(c, 16, 64)
+			if err != nil {
+				return 0, nil, fmt.Errorf("%s line %d: invalid culture %q", path, line, c)
+			}
+			s.cultures[n] = int(culture)
+		}
+		snapshots = append(snapshots, s)
+	}
+	if len(snapshots) == 0 {
+		return 0, nil, errors.New("no snapshots recorded")
+	}
+	return w, snapshots, nil
+}
+
+// read the grid width from the header of a CSV snapshot file, a list of
+// names and values. Version 1 headers have only the width.
+func readSnapshotHeader(header []string) (int, error) {
+	fields := map[string]string{"version": "1"}
+	for i := 0; i+1 < len(header); i += 2 {
+		fields[header[i]] = header[i+1]
+	}
+	if len(header)%2 != 0 || fields["width"] == "" {
+		return 0, errors.New("not a snapshot file")
+	}
+	if err := checkVersion("snapshot", fields["version"], snapshotVersion); err != nil {
+		return 0, err
+	}
+	w, err := strconv.Atoi(fields["width"])
+	if err != nil || w <= 0 {
+		return 0, errors.New("invalid grid width")
+	}
+	return w, nil
+}
+
+// check a file of a format is of a version this culsim reads, the latest
+// or an earlier one
+func checkVersion(format, version string, latest int) error {
+	v, err := strconv.Atoi(version)
+	if err != nil || v < 1 {
+		return fmt.Errorf("invalid %s version %q", format, version)
+	}
+	if v > latest {
+		return fmt.Errorf("unsupported %s version %d, this culsim reads up to version %d", format, v, latest)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sausheong/culsim"
+)
+
+// connection to the NATS server the run's metrics are streamed to
+var stream struct {
+	sync.Mutex
+	conn    net.Conn
+	w       *bufio.Writer
+	subject string
+	run     string // identifies the run among the streams of many workers
+}
+
+// metrics of a tick published to the stream
+type tickMessage struct {
+	Run      string `json:"run"`
+	Tick     int    `json:"tick"`
+	Distance int    `json:"distance"`
+	Changes  int    `json:"changes"`
+	Unique   int    `json:"unique"`
+	Conquest int    `json:"conquest"`
+}
+
+// a trait copied from one cell to another, published to <subject>.exchanges
+type exchangeMessage struct {
+	Run     string `json:"run"`
+	Tick    int    `json:"tick"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+	Feature int    `json:"feature"`
+	Culture int    `json:"culture"`
+}
+
+// an intervention in the run, published to <subject>.interventions
+type interventionMessage struct {
+	Run string `json:"run"`
+	culsim.Intervention
+}
+
+// connect to the NATS server at a nats://host:port/subject URI, metrics are
+// published to the subject, culsim if the URI has none
+func openStream(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "nats" || u.Host == "" {
+		return fmt.Errorf("invalid NATS URI %q, use nats://host:port/subject", uri)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host += ":4222"
+	}
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(conn)
+	// the server greets with its INFO before anything else
+	if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "INFO") {
+		conn.Close()
+		return fmt.Errorf("no greeting from NATS server at %s", host)
+	}
+	connect := map[string]interface{}{"verbose": false, "pedantic": false, "name": "culsim"}
+	if u.User != nil {
+		connect["user"] = u.User.Username()
+		connect["pass"], _ = u.User.Password()
+	}
+	opts, _ := json.Marshal(connect)
+	if _, err = fmt.Fprintf(conn, "CONNECT %s\r\n", opts); err != nil {
+		conn.Close()
+		return err
+	}
+	hostname, _ := os.Hostname()
+	stream.conn, stream.w = conn, bufio.NewWriter(conn)
+	stream.subject = strings.Trim(strings.ReplaceAll(u.Path, "/", "."), ".")
+	if stream.subject == "" {
+		stream.subject = "culsim"
+	}
+	stream.run = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	go answerPings(r)
+	return nil
+}
+
+// keep the connection alive by answering the server's pings, and report
+// any errors it sends
+func answerPings(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			stream.Lock()
+			stream.w.WriteString("PONG\r\n")
+			stream.w.Flush()
+			stream.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			slog.Error("NATS server error", "err", strings.TrimSpace(line[4:]))
+		}
+	}
+}
+
+// publish a message to a subject, buffered until the end of the tick
+func publish(subject string, msg interface{}) {
+	data, _ := json.Marshal(msg)
+	stream.Lock()
+	fmt.Fprintf(stream.w, "PUB %s %d\r\n%s\r\n", subject, len(data), data)
+	stream.Unlock()
+}
+
+// stream the metrics of every tick and, with -stream-exchanges, every
+// exchange
+func streamMessages(m culsim.Message) {
+	switch m := m.(type) {
+	case culsim.TickCompleted:
+		publishTick(m)
+	case culsim.ExchangeHappened:
+		if *streamExchanges {
+			publish(stream.subject+".exchanges", exchangeMessage{stream.run, m.Tick, m.Src, m.Dst, m.Feature, m.Culture})
+		}
+	case culsim.InterventionApplied:
+		publish(stream.subject+".interventions", interventionMessage{stream.run, m.Intervention})
+	}
+}
+
+// publish the metrics of a tick
+func publishTick(t culsim.TickCompleted) {
+	stats := t.Stats
+	publish(stream.subject, tickMessage{stream.run, t.Tick, stats.Distance, stats.Exchanges, stats.Unique, stats.Conquered})
+	stream.Lock()
+	if err := stream.w.Flush(); err != nil {
+		slog.Error("failed streaming metrics", "err", err)
+	}
+	stream.Unlock()
+}
+
+// send what is left and disconnect from the stream
+func closeStream() {
+	if stream.conn == nil {
+		return
+	}
+	stream.Lock()
+	stream.w.Flush()
+	stream.conn.Close()
+	stream.conn = nil
+	stream.Unlock()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// render a grid of colours w cells wide as an SVG image with one rect per
+// cell, each cell 1 unit square. If domain labels are given, the borders
+// between cells of different domains are outlined and the largest domains
+// labelled with their sizes.
+func renderSVG(colors []color.RGBA, w int, labels, sizes []int) string {
+	h := len(colors) / w
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`+"\n",
+		w, h, w**scale, h**scale)
+	for n, c := range colors {
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="1" height="1" fill="#%02X%02X%02X"/>`+"\n", n%w, n/w, c.R, c.G, c.B)
+	}
+	if labels != nil {
+		b.WriteString(`<path fill="none" stroke="black" stroke-width="0.1" stroke-linecap="square" d="`)
+		for n := range labels {
+			x, y := n%w, n/w
+			if x+1 < w && labels[n] != labels[n+1] {
+				fmt.Fprintf(&b, "M%d %dv1", x+1, y)
+			}
+			if y+1 < h && labels[n] != labels[n+w] {
+				fmt.Fprintf(&b, "M%d %dh1", x, y+1)
+			}
+		}
+		b.WriteString("\"/>\n")
+		for _, d := range largestDomains(labels, sizes, *labelDomains) {
+			fmt.Fprintf(&b, `<text x="%.2f" y="%.2f" font-size="0.8" font-family="sans-serif" text-anchor="middle" dominant-baseline="central" fill="black" stroke="white" stroke-width="0.05" paint-order="stroke">%d</text>`+"\n",
+				d.cx, d.cy, d.size)
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// save the current grid as an SVG image in data/grid-<name>.svg
+func (sim *CultureSim) saveSVG(name string) error {
+	cultures := sim.cultures()
+	colors, _ := renderView(cultures)
+	var labels, sizes []int
+	if *outline {
+		labels, sizes = culsim.Domains(cultures, width, culsim.Topology(*topology))
+	}
+	path := fmt.Sprintf("data/grid-%s.svg", name)
+	if err := os.WriteFile(path, []byte(renderSVG(colors, width, labels, sizes)), 0644); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("grid saved", "path", path)
+	return nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// range of a flag swept, whole numbers if both ends are
+type sweepRange struct {
+	name     string
+	min, max float64
+	whole    bool
+}
+
+// value of the range at the fraction u of the way from its min to its max
+func (r sweepRange) at(u float64) string {
+	v := r.min + u*(r.max-r.min)
+	if r.whole {
+		return strconv.Itoa(int(math.Round(v)))
+	}
+	return formatFloat(v)
+}
+
+// parse comma-separated ranges of flags, such as n=100:1000,c=0.5:1
+func parseRanges(list string) ([]sweepRange, error) {
+	var ranges []sweepRange
+	for _, s := range strings.Split(list, ",") {
+		name, bounds, ok := strings.Cut(strings.TrimSpace(s), "=")
+		lo, hi, ok2 := strings.Cut(bounds, ":")
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("%q is not a range like n=100:1000", s)
+		}
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("no flag -%s to sweep", name)
+		}
+		from, err := strconv.ParseFloat(lo, 64)
+		if err != nil {
+			return nil, err
+		}
+		to, err := strconv.ParseFloat(hi, 64)
+		if err != nil {
+			return nil, err
+		}
+		_, errLo := strconv.Atoi(lo)
+		_, errHi := strconv.Atoi(hi)
+		ranges = append(ranges, sweepRange{name, from, to, errLo == nil && errHi == nil})
+	}
+	return ranges, nil
+}
+
+// fractions of the way along each range of the samples: -samples values of
+// every range evenly spaced and all their combinations for grid, -samples
+// points in strata of each range no 2 share for lhs, or -samples points
+// anywhere for random
+func samplePoints(method string, dims, n int) ([][]float64, error) {
+	var points [][]float64
+	switch method {
+	case "grid":
+		points = [][]float64{nil}
+		for d := 0; d < dims; d++ {
+			var next [][]float64
+			for _, p := range points {
+				for k := 0; k < n; k++ {
+					u := 0.0
+					if n > 1 {
+						u = float64(k) / float64(n-1)
+					}
+					next = append(next, append(append([]float64{}, p...), u))
+				}
+			}
+			points = next
+		}
+	case "lhs":
+		points = make([][]float64, n)
+		for i := range points {
+			points[i] = make([]float64, dims)
+		}
+		for d := 0; d < dims; d++ {
+			// every sample takes a stratum of the range at random
+			for i, stratum := range rand.Perm(n) {
+				points[i][d] = (float64(stratum) + rand.Float64()) / float64(n)
+			}
+		}
+	case "random":
+		points = make([][]float64, n)
+		for i := range points {
+			points[i] = make([]float64, dims)
+			for d := range points[i] {
+				points[i][d] = rand.Float64()
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown sampling %q, grid, lhs or random", method)
+	}
+	return points, nil
+}
+
+// flags set on the command line that the runs of samples are started with,
+// all but the ones of sweeps and of reporting the sweep itself
+func passedFlags() map[string]string {
+	fixed := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "ranges", "sampling", "samples", "objective", "goal", "generations", "repeats", "resume", "target",
+			"notify-url", "after", "status-file":
+		default:
+			fixed[f.Name] = f.Value.String()
+		}
+	})
+	return fixed
+}
+
+// flags of the run of a sample, the fixed flags and the values of the ranges
+// at the point p
+func sampleParams(fixed map[string]string, ranges []sweepRange, p []float64) map[string]string {
+	params := make(map[string]string)
+	for name, v := range fixed {
+		params[name] = v
+	}
+	for d, r := range ranges {
+		params[r.name] = r.at(p[d])
+	}
+	return params
+}
+
+// run culsim in its own process with the flags of a sample, returning the
+// summary of the run
+func runSample(self string, params map[string]string) map[string]interface{} {
+	slog.Info("running sample", "params", params)
+	return runJob(self, &job{ID: runID, Params: params})
+}
+
+// samples of a sweep, saved in data/sweep-<run ID>.json when it starts so
+// that it can be resumed
+type sweepPlan struct {
+	ID      string              `json:"id"`
+	Started time.Time           `json:"started"`
+	Ranges  []string            `json:"ranges"`
+	Samples []map[string]string `json:"samples"`
+}
+
+// the plan of a new sweep of the -ranges, or of the one -resume carries on
+// from, by its ID or its plan file
+func planSweep() (*sweepPlan, error) {
+	if *resumePath != "" {
+		path := *resumePath
+		if !strings.HasSuffix(path, ".json") {
+			path = fmt.Sprintf("data/sweep-%s.json", path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var plan sweepPlan
+		if err = json.Unmarshal(data, &plan); err != nil {
+			return nil, fmt.Errorf("corrupt sweep plan %s: %w", path, err)
+		}
+		return &plan, nil
+	}
+	if *sweepRanges == "" {
+		return nil, invalidError{errors.New("-ranges are needed for a sweep")}
+	}
+	ranges, err := parseRanges(*sweepRanges)
+	if err != nil {
+		return nil, invalidError{fmt.Errorf("invalid -ranges: %w", err)}
+	}
+	if *samples < 1 {
+		return nil, invalidError{errors.New("-samples must be at least 1")}
+	}
+	points, err := samplePoints(*sampling, len(ranges), *samples)
+	if err != nil {
+		return nil, invalidError{err}
+	}
+	plan := &sweepPlan{ID: runID, Started: started}
+	for _, r := range ranges {
+		plan.Ranges = append(plan.Ranges, r.name)
+	}
+	fixed := passedFlags()
+	for _, p := range points {
+		plan.Samples = append(plan.Samples, sampleParams(fixed, ranges, p))
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("data/sweep-%s.json", plan.ID)
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	addOutput(path)
+	return plan, nil
+}
+
+// summary of a finished run of the sample's flags in the run index, started
+// after the sweep, as runJob returns it, nil if there is none
+func finishedSample(runs []summary, plan *sweepPlan, params map[string]string) map[string]interface{} {
+	filters := make([]string, 0, len(params))
+	for name, v := range params {
+		filters = append(filters, name+"="+v)
+	}
+	for _, run := range runs {
+		if run.ID == plan.ID || run.Status != "finished" || run.Started.Before(plan.Started) || !matches(run, filters) {
+			continue
+		}
+		var result map[string]interface{}
+		data, _ := json.Marshal(run)
+		if json.Unmarshal(data, &result) != nil {
+			return nil
+		}
+		result["run"] = run.ID
+		return result
+	}
+	return nil
+}
+
+// run culsim on samples of the -ranges of flags, each in its own process
+// with the other flags set on the command line, and save their parameters
+// and final metrics in data/sweep-<run ID>.csv. The samples are planned in
+// data/sweep-<run ID>.json, and a sweep resumed with -resume skips the
+// samples with a finished run in the run index.
+func sweep() error {
+	plan, err := planSweep()
+	if err != nil {
+		return err
+	}
+	// a resumed sweep saves its results over the ones it started
+	runID = plan.ID
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	runs, err := readIndex()
+	if err != nil {
+		return err
+	}
+
+	results := make([]map[string]interface{}, len(plan.Samples))
+	metrics := make(map[string]bool)
+	var skipped int
+	for i, params := range plan.Samples {
+		if results[i] = finishedSample(runs, plan, params); results[i] != nil {
+			skipped++
+		} else {
+			results[i] = runSample(self, params)
+		}
+		if m, ok := results[i]["metrics"].(map[string]interface{}); ok {
+			for name := range m {
+				metrics[name] = true
+			}
+		}
+	}
+	if skipped > 0 {
+		slog.Info("skipped finished samples", "sweep", plan.ID, "samples", skipped)
+	}
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	path := fmt.Sprintf("data/sweep-%s.csv", runID)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	header := append([]string{"sample"}, plan.Ranges...)
+	_ = csvwriter.Write(append(append(header, "run", "status"), names...))
+	for i, params := range plan.Samples {
+		row := []string{strconv.Itoa(i)}
+		for _, name := range plan.Ranges {
+			row = append(row, params[name])
+		}
+		run, _ := results[i]["run"].(string)
+		status, _ := results[i]["status"].(string)
+		row = append(row, run, status)
+		m, _ := results[i]["metrics"].(map[string]interface{})
+		for _, name := range names {
+			v, ok := m[name].(float64)
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, formatFloat(v))
+		}
+		_ = csvwriter.Write(row)
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("sweep saved", "path", path, "samples", len(plan.Samples))
+	return nil
+}
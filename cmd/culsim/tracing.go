@@ -0,0 +1,44 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// provider of the exported spans, nil when not tracing
+var tracerProvider *sdktrace.TracerProvider
+
+// export spans to the OTLP endpoint set up by the standard OTEL_EXPORTER_OTLP_*
+// environment variables, http://localhost:4318 by default
+func setupTracing() error {
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		return err
+	}
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("culsim"))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	return nil
+}
+
+// export the spans still waiting to be sent
+func shutdownTracing() {
+	if tracerProvider == nil {
+		return
+	}
+	if err := tracerProvider.Shutdown(context.Background()); err != nil {
+		slog.Error("failed exporting spans", "err", err)
+	}
+	tracerProvider = nil
+}
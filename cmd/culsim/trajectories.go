@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sausheong/culsim"
+)
+
+// cells whose cultures are tracked every tick: the -track-cells, or a random
+// sample of -track populated cells
+func trackedCells(cultures []int) ([]int, error) {
+	if *trackCells != "" {
+		var cells []int
+		for _, xy := range strings.Fields(strings.ReplaceAll(*trackCells, ";", " ")) {
+			xs, ys, ok := strings.Cut(xy, ",")
+			x, errX := strconv.Atoi(xs)
+			y, errY := strconv.Atoi(ys)
+			if !ok || errX != nil || errY != nil {
+				return nil, invalidError{fmt.Errorf("invalid -track-cells %q, give cells as x,y", xy)}
+			}
+			if x < 0 || x >= width || y < 0 || y >= width {
+				return nil, invalidError{fmt.Errorf("-track-cells %d,%d is outside the grid", x, y)}
+			}
+			cells = append(cells, y*width+x)
+		}
+		return cells, nil
+	}
+	var populated []int
+	for n, c := range cultures {
+		if c != culsim.Empty {
+			populated = append(populated, n)
+		}
+	}
+	rand.Shuffle(len(populated), func(i, j int) { populated[i], populated[j] = populated[j], populated[i] })
+	return populated[:min(*track, len(populated))], nil
+}
+
+// add a row for every tracked cell at the current tick
+func (sim *CultureSim) appendTrajectories() {
+	tick, cultures := sim.engine.Tick(), sim.cultures()
+	for _, n := range sim.tracked {
+		x, y := coords(n)
+		row := []string{strconv.Itoa(tick), strconv.Itoa(n), strconv.Itoa(x), strconv.Itoa(y), ""}
+		if c := cultures[n]; c != culsim.Empty {
+			row[4] = labels.Culture(c)
+			row = append(row, traitValues(c)...)
+		} else {
+			row = append(row, make([]string, culsim.Features)...)
+		}
+		sim.trajectories = append(sim.trajectories, row)
+	}
+}
+
+// record the cultures of the tracked cells at the end of every tick
+func (sim *CultureSim) recordTrajectories(m culsim.Message) {
+	if _, ok := m.(culsim.TickCompleted); ok {
+		sim.appendTrajectories()
+	}
+}
+
+// save the trajectories of the tracked cells in data/trajectories-<name>.csv,
+// a row for every cell at every tick with its culture and traits, labelled
+// as in the config, which are empty while the cell is
+func (sim *CultureSim) saveTrajectories(name string) error {
+	path := fmt.Sprintf("data/trajectories-%s.csv", name)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	_ = csvwriter.Write(append([]string{"tick", "cell", "x", "y", "culture"}, traitColumns("trait")...))
+	_ = csvwriter.WriteAll(sim.trajectories)
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("trajectories saved", "path", path, "cells", len(sim.tracked))
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/sausheong/culsim"
+)
+
+// the config of the treated twin, the config of -config with the settings
+// of the -treatment file replacing its own
+func treatedConfig() (*culsim.Config, error) {
+	cfg, err := culsim.LoadConfig(*configFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(*treatment)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse treatment %s: %w", *treatment, err)
+	}
+	return cfg, nil
+}
+
+// fraction of the cells whose cultures differ between 2 grids, and the
+// fraction of the features of all cells that do, counting every feature of
+// a cell that is empty in only one of them
+func divergence(control, treated []int, features int) (float64, float64) {
+	var cells, differ int
+	for n, c := range control {
+		t := treated[n]
+		if c == t {
+			continue
+		}
+		cells++
+		if c == culsim.Empty || t == culsim.Empty {
+			differ += features
+			continue
+		}
+		for f := 0; f < features; f++ {
+			if culsim.FeatureTrait(c, f) != culsim.FeatureTrait(t, f) {
+				differ++
+			}
+		}
+	}
+	return float64(cells) / float64(len(control)), float64(differ) / float64(len(control)*features)
+}
+
+// the values of the metrics of an engine in its latest tick, empty for the
+// ones it hasn't recorded
+func latestValues(engine *culsim.Engine, names []string) []string {
+	latest := make(map[string]float64)
+	n, v := engine.Latest()
+	for i := range n {
+		latest[n[i]] = v[i]
+	}
+	values := make([]string, len(names))
+	for i, name := range names {
+		if value, ok := latest[name]; ok {
+			values[i] = formatFloat(value)
+		}
+	}
+	return values
+}
+
+// run 2 twins of the simulation of the flags for -d ticks from the same seed
+// and initial grid, the control and one treated with the -treatment config,
+// and save how far their grids diverge every tick, with the metrics of both,
+// in data/twin-<run ID>.csv. Until a treatment that starts later, such as
+// an event, acts, the twins draw the same random numbers, so the divergence
+// is the effect of the treatment alone.
+func twin() error {
+	if *treatment == "" {
+		return invalidError{errors.New("a -treatment config is needed for twin runs")}
+	}
+	cfg, err := treatedConfig()
+	if err != nil {
+		return invalidError{fmt.Errorf("invalid -treatment: %w", err)}
+	}
+	control, err := newEngine(seed)
+	if err != nil {
+		return err
+	}
+	treated, err := configuredEngine(seed, cfg)
+	if err != nil {
+		return err
+	}
+	// the treatment may seed cells, such as a minority, that the twins
+	// don't start from
+	if err = treated.SetCultures(control.Cultures()); err != nil {
+		return invalidError{fmt.Errorf("treatment: %w", err)}
+	}
+
+	path := fmt.Sprintf("data/twin-%s.csv", runID)
+	csvfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvwriter := csv.NewWriter(csvfile)
+	var controlNames, treatedNames []string
+	for _, s := range control.Metrics() {
+		controlNames = append(controlNames, s.Name)
+	}
+	for _, s := range treated.Metrics() {
+		treatedNames = append(treatedNames, s.Name)
+	}
+	header := []string{"tick", "differing", "divergence"}
+	for _, name := range controlNames {
+		header = append(header, "control-"+name)
+	}
+	for _, name := range treatedNames {
+		header = append(header, "treated-"+name)
+	}
+	_ = csvwriter.Write(header)
+	var differing, diverged float64
+	for tick := control.Tick(); tick < *duration; tick++ {
+		control.Step(context.Background())
+		treated.Step(context.Background())
+		differing, diverged = divergence(control.Cultures(), treated.Cultures(), control.Params().Features)
+		row := []string{strconv.Itoa(control.Tick()), formatFloat(differing), formatFloat(diverged)}
+		row = append(row, latestValues(control, controlNames)...)
+		_ = csvwriter.Write(append(row, latestValues(treated, treatedNames)...))
+	}
+	csvwriter.Flush()
+	if err = errors.Join(csvwriter.Error(), csvfile.Close()); err != nil {
+		return err
+	}
+	addOutput(path)
+	slog.Info("twin runs saved", "path", path, "differing", formatFloat(differing), "divergence", formatFloat(diverged))
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// number of attempts at uploading each file
+const uploadAttempts = 4
+
+// upload the files saved by this run to the -upload object store URI, an
+// s3://bucket/prefix/ or gs://bucket/prefix/. Credentials come from the
+// usual AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+// AWS_REGION environment variables for S3, and an OAuth access token in
+// GOOGLE_OAUTH_ACCESS_TOKEN for Google Cloud Storage.
+func uploadOutputs() {
+	if *uploadURI == "" {
+		return
+	}
+	dest, err := url.Parse(*uploadURI)
+	if err != nil || dest.Host == "" {
+		slog.Error("invalid upload URI", "uri", *uploadURI)
+		return
+	}
+	var put func(bucket, key string, body []byte) error
+	switch dest.Scheme {
+	case "s3":
+		put = putS3
+	case "gs":
+		put = putGCS
+	default:
+		slog.Error("unsupported upload URI, use s3:// or gs://", "uri", *uploadURI)
+		return
+	}
+	for _, file := range outputFiles() {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			slog.Error("failed reading file for upload", "path", file, "err", err)
+			continue
+		}
+		key := path.Join(strings.TrimPrefix(dest.Path, "/"), filepath.ToSlash(file))
+		for attempt := 1; ; attempt++ {
+			if err = put(dest.Host, key, body); err == nil {
+				slog.Info("file uploaded", "path", file, "uri", fmt.Sprintf("%s://%s/%s", dest.Scheme, dest.Host, key))
+				break
+			}
+			if attempt == uploadAttempts {
+				slog.Error("failed uploading file", "path", file, "err", err)
+				break
+			}
+			slog.Warn("retrying upload", "path", file, "attempt", attempt, "err", err)
+			// back off for 1, 2, 4... seconds before trying again
+			time.Sleep(time.Second << (attempt - 1))
+		}
+	}
+}
+
+// files saved by this run, with directories of images expanded
+func outputFiles() []string {
+	var files []string
+	for _, p := range outputs {
+		if !strings.HasSuffix(p, "/") {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				files = append(files, filepath.Join(p, e.Name()))
+			}
+		}
+	}
+	return files
+}
+
+// upload an object to S3, signing the request with AWS signature version 4
+func putS3(bucket, key string, body []byte) error {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	host := fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, region)
+	req, err := http.NewRequest(http.MethodPut, "https://"+host+"/"+escapeKey(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	date, stamp := now.Format("20060102"), now.Format("20060102T150405Z")
+	hash := sha256.Sum256(body)
+	payload := hex.EncodeToString(hash[:])
+	req.Header.Set("Host", host)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	req.Header.Set("X-Amz-Date", stamp)
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", host, payload, stamp)
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+		signed += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + token + "\n"
+	}
+	canonical := strings.Join([]string{http.MethodPut, "/" + escapeKey(key), "", canonicalHeaders, signed, payload}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := date + "/" + region + "/s3/aws4_request"
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", stamp, scope, hex.EncodeToString(canonicalHash[:])}, "\n")
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, s := range []string{region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signed, signature))
+	return send(req)
+}
+
+// upload an object to Google Cloud Storage through its XML API
+func putGCS(bucket, key string, body []byte) error {
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" {
+		return fmt.Errorf("GOOGLE_OAUTH_ACCESS_TOKEN must be set")
+	}
+	req, err := http.NewRequest(http.MethodPut,
+		fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucket, escapeKey(key)), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return send(req)
+}
+
+// send an upload request, failing on any response but success
+func send(req *http.Request) error {
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("upload failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// escape each segment of an object key for the request path
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
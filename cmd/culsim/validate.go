@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sausheong/culsim"
+)
+
+// average number of stable regions of Axelrod's 10x10 territories over 10
+// runs, from Table 1 of Axelrod (1997), The Dissemination of Culture, Journal
+// of Conflict Resolution 41(2), for the features and traits culsim has
+var axelrodRegions = []struct {
+	features, traits int
+	regions          float64
+}{
+	{5, 5, 1.0},
+	{5, 10, 3.2},
+	{5, 15, 20.0},
+}
+
+// runs behind each of Axelrod's averages
+const axelrodRuns = 10
+
+// ticks a validation run may take to become stable
+const validateTicks = 100000
+
+// run Axelrod's parameterizations -replicates times each under Axelrod's
+// rule until the grid is stable, and check the average number of stable
+// regions is within -tolerance standard errors of the published value. The
+// standard error takes in the spread of both the replicates and Axelrod's
+// own runs.
+func validate() error {
+	if *replicates < 2 {
+		return invalidError{errors.New("-replicates must be at least 2 to validate")}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FEATURES\tTRAITS\tPUBLISHED\tREGIONS\tSTDDEV\tRESULT")
+	var failed int
+	for _, ref := range axelrodRegions {
+		regions := make([]float64, *replicates)
+		for rep := range regions {
+			// every replicate has its own seed, drawn from the run's seed
+			sizes, err := stableDomains(10, ref.features, ref.traits, 100, rand.Int63())
+			if err != nil {
+				return err
+			}
+			regions[rep] = float64(len(sizes))
+		}
+		mean, sd := meanStdDev(regions)
+		se := sd * math.Sqrt(1/float64(*replicates)+1/float64(axelrodRuns))
+		result := "ok"
+		if math.Abs(mean-ref.regions) > *tolerance*se {
+			result = "FAIL"
+			failed++
+		}
+		slog.Info("validated", "features", ref.features, "traits", ref.traits, "published", ref.regions,
+			"regions", mean, "stddev", sd, "result", result)
+		fmt.Fprintf(w, "%d\t%d\t%.1f\t%.2f\t%.2f\t%s\n", ref.features, ref.traits, ref.regions, mean, sd, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d parameterizations are off their published stable regions", failed, len(axelrodRegions))
+	}
+	return nil
+}
+
+// sizes of the stable regions a grid of size x size cells settles into under
+// Axelrod's rule, with the 4 adjacent cells as neighbours and no wrapping
+// edges
+func stableDomains(size, features, traits, interactions int, seed int64) ([]int, error) {
+	engine, err := culsim.New(
+		culsim.WithGrid(size, size),
+		culsim.WithFeatures(features, traits),
+		culsim.WithRule(culsim.Axelrod),
+		culsim.WithTopology(culsim.VonNeumann),
+		culsim.WithInteractions(interactions),
+		culsim.WithSeed(seed),
+		culsim.WithoutMetrics("distance"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for !engine.Stable() {
+		if engine.Tick() == validateTicks {
+			return nil, fmt.Errorf("grid of %d cells a side, %d features and %d traits not stable after %d ticks, seed %d",
+				size, features, traits, validateTicks, seed)
+		}
+		engine.Step(context.Background())
+	}
+	_, sizes := culsim.Domains(engine.Cultures(), size, culsim.VonNeumann)
+	return sizes, nil
+}
+
+// mean and sample standard deviation
+func meanStdDev(xs []float64) (float64, float64) {
+	var sum, squares float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	for _, x := range xs {
+		squares += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(xs)-1))
+}
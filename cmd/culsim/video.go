@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// encode the grid snapshots recorded in a file into a video by piping the
+// frames to ffmpeg, the codec follows the extension of the output file
+func exportVideo(path, output string) {
+	w, snapshots, err := readSnapshots(path)
+	if err != nil {
+		fatal("failed reading snapshots", "path", path, "err", err)
+	}
+	setWidth(w)
+	if *fps <= 0 || *scale <= 0 {
+		fatal("fps and scale must be positive")
+	}
+
+	codec := []string{"-c:v", "libx264", "-pix_fmt", "yuv420p"}
+	switch filepath.Ext(output) {
+	case ".mp4":
+	case ".webm":
+		codec = []string{"-c:v", "libvpx-vp9", "-pix_fmt", "yuv420p"}
+	default:
+		fatal("unsupported video format, use .mp4 or .webm", "format", filepath.Ext(output))
+	}
+	size := w * *scale
+	args := []string{"-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba", "-s", fmt.Sprintf("%dx%d", size, size),
+		"-r", strconv.Itoa(*fps), "-i", "-",
+		// yuv420p needs even dimensions
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2"}
+	args = append(append(args, codec...), output)
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		fatal("failed starting ffmpeg", "err", err)
+	}
+	if err = cmd.Start(); err != nil {
+		fatal("failed starting ffmpeg, is it installed?", "err", err)
+	}
+	for _, s := range snapshots {
+		colors, _ := renderView(s.cultures)
+		if _, err = stdin.Write(renderImage(colors, w, *scale, nil).Pix); err != nil {
+			break
+		}
+	}
+	stdin.Close()
+	if err = cmd.Wait(); err != nil {
+		fatal("failed encoding video", "err", err)
+	}
+	slog.Info("video saved", "frames", len(snapshots), "path", output)
+}
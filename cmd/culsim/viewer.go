@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// a recorded run being replayed
+type replay struct {
+	path      string
+	width     int
+	snapshots []snapshot
+}
+
+// replay the grid snapshots recorded in one or more files in the terminal,
+// side by side with synchronised ticks, stepping through them with a
+// timeline scrubber
+func runViewer(paths []string) {
+	var replays []replay
+	for _, path := range paths {
+		w, snapshots, err := readSnapshots(path)
+		if err != nil {
+			fatal("failed reading snapshots", "path", path, "err", err)
+		}
+		replays = append(replays, replay{path, w, snapshots})
+	}
+	// the timeline follows the ticks of the longest run
+	timeline := replays[0].snapshots
+	for _, r := range replays[1:] {
+		if len(r.snapshots) > len(timeline) {
+			timeline = r.snapshots
+		}
+	}
+	listenKeys()
+	defer restoreTerminal()
+
+	frame, playing := 0, false
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	var escape []rune
+	drawReplays(replays, timeline, frame, playing)
+	for {
+		select {
+		case <-ticker.C:
+			if !playing {
+				continue
+			}
+			if frame < len(timeline)-1 {
+				frame++
+			} else {
+				playing = false
+			}
+		case k := <-keys:
+			// arrow keys arrive as the escape sequences ESC [ A to D
+			if k == 0x1B || len(escape) > 0 {
+				escape = append(escape, k)
+				if len(escape) < 3 {
+					continue
+				}
+				k, escape = map[rune]rune{'C': '.', 'D': ',', 'A': '>', 'B': '<'}[escape[2]], nil
+			}
+			switch k {
+			case 'q':
+				fmt.Println()
+				return
+			case ' ':
+				playing = !playing
+			case '.':
+				frame++
+			case ',':
+				frame--
+			case '>':
+				frame += 10
+			case '<':
+				frame -= 10
+			case 'g':
+				frame = 0
+			case 'G':
+				frame = len(timeline) - 1
+			default:
+				keys <- k
+				handleKeys()
+			}
+			if frame < 0 {
+				frame = 0
+			}
+			if frame >= len(timeline) {
+				frame = len(timeline) - 1
+			}
+		}
+		drawReplays(replays, timeline, frame, playing)
+	}
+}
+
+// the last snapshot of the replay at or before tick t
+func (r replay) at(t int) snapshot {
+	s := r.snapshots[0]
+	for _, snap := range r.snapshots {
+		if snap.tick > t {
+			break
+		}
+		s = snap
+	}
+	return s
+}
+
+// draw the replays side by side at one frame of the timeline, with the
+// timeline scrubber below them
+func drawReplays(replays []replay, timeline []snapshot, frame int, playing bool) {
+	t := timeline[frame].tick
+	var grids [][]string
+	var legends strings.Builder
+	for _, r := range replays {
+		// neighbourhoods in the heatmap views follow the recorded grid
+		setWidth(r.width)
+		s := r.at(t)
+		colors, legend := renderView(s.cultures)
+		lines := strings.Split(strings.TrimSuffix(terminalView(colors, r.width), "\n"), "\n")
+		grids = append(grids, lines)
+		fmt.Fprintf(&legends, "\n%s (tick %d)\n%s", r.path, s.tick, terminalLegend(legend))
+	}
+
+	fmt.Print("\033[H\033[2J")
+	fmt.Printf("Replaying tick %d (%d/%d)\n\n", t, frame+1, len(timeline))
+	columns := make([]int, len(grids))
+	for g, lines := range grids {
+		for _, l := range lines {
+			if vw := visibleWidth(l); vw > columns[g] {
+				columns[g] = vw
+			}
+		}
+	}
+	for i := 0; ; i++ {
+		var line strings.Builder
+		more := false
+		for g, lines := range grids {
+			if g > 0 {
+				line.WriteString("  ")
+			}
+			l := ""
+			if i < len(lines) {
+				l = lines[i]
+				more = true
+			}
+			line.WriteString(l + strings.Repeat(" ", columns[g]-visibleWidth(l)))
+		}
+		if !more {
+			break
+		}
+		fmt.Println(line.String())
+	}
+	fmt.Print(legends.String())
+	fmt.Printf("\n%s\n", scrubber(frame, len(timeline), 60))
+	state := "paused"
+	if playing {
+		state = "playing"
+	}
+	fmt.Printf("\n[%s] space play/pause, ←/→ step, ↑/↓ jump 10, g/G start/end, q quit\n", state)
+	fmt.Println("c, p, h, d or 0-5 to view cultures, the palette, diversity, distance or a feature.")
+	fmt.Println("+ and - to zoom, W, A, S and D to pan, m to show the minimap.")
+}
+
+// number of characters a line of text takes up in the terminal, leaving out
+// its colour escape sequences
+func visibleWidth(line string) int {
+	var count int
+	inEscape := false
+	for _, r := range line {
+		switch {
+		case r == 0x1B:
+			inEscape = true
+		case inEscape:
+			inEscape = r != 'm'
+		default:
+			count++
+		}
+	}
+	return count
+}
+
+// timeline bar size characters wide with a marker at the current frame
+func scrubber(frame, frames, size int) string {
+	pos := 0
+	if frames > 1 {
+		pos = frame * (size - 1) / (frames - 1)
+	}
+	return "|" + strings.Repeat("=", pos) + "●" + strings.Repeat("-", size-1-pos) + "|"
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+)
+
+// viewport is the part of the grid shown in the terminal. Each character
+// column shows zoom cells, and x and y are the cell at its top left.
+type viewport struct {
+	x, y, zoom int
+}
+
+// the viewport of the terminal views, panned and zoomed with the keys
+var port = viewport{zoom: 1}
+
+// size of the viewport in cells
+func (v viewport) size(w, h int) (int, int) {
+	vw, vh := *termWidth*v.zoom, *termHeight*v.zoom
+	if vw > w {
+		vw = w
+	}
+	if vh > h {
+		vh = h
+	}
+	return vw, vh
+}
+
+// keep the viewport on a grid w cells wide and h cells high
+func (v *viewport) fit(w, h int) {
+	vw, vh := v.size(w, h)
+	v.x, v.y = clamp(v.x, 0, w-vw), clamp(v.y, 0, h-vh)
+}
+
+// pan the viewport by a quarter of its size in the given direction
+func (v *viewport) pan(dx, dy int) {
+	v.x += dx * *termWidth * v.zoom / 4
+	v.y += dy * *termHeight * v.zoom / 4
+}
+
+// zoom the viewport in or out by a factor of 2, keeping its centre
+func (v *viewport) zoomBy(in bool) {
+	cx, cy := v.x+*termWidth*v.zoom/2, v.y+*termHeight*v.zoom/2
+	if in && v.zoom > 1 {
+		v.zoom /= 2
+	} else if !in && v.zoom < 64 {
+		v.zoom *= 2
+	}
+	v.x, v.y = cx-*termWidth*v.zoom/2, cy-*termHeight*v.zoom/2
+}
+
+// the colours in the viewport of a grid w cells wide, one for every zoom by
+// zoom block of cells, and the width of the result
+func (v *viewport) crop(colors []color.RGBA, w int) ([]color.RGBA, int) {
+	h := len(colors) / w
+	v.fit(w, h)
+	vw, vh := v.size(w, h)
+	cw, ch := (vw+v.zoom-1)/v.zoom, (vh+v.zoom-1)/v.zoom
+	cropped := make([]color.RGBA, 0, cw*ch)
+	for y := 0; y < ch; y++ {
+		for x := 0; x < cw; x++ {
+			// each block shows the colour of its top left cell
+			cropped = append(cropped, colors[(v.y+y*v.zoom)*w+v.x+x*v.zoom])
+		}
+	}
+	return cropped, cw
+}
+
+// check if the whole grid fits in the viewport
+func (v viewport) whole(w, h int) bool {
+	vw, vh := v.size(w, h)
+	return v.zoom == 1 && vw == w && vh == h
+}
+
+// an overview of a grid w cells wide, size characters wide, with the
+// viewport outlined
+func (v viewport) minimap(colors []color.RGBA, w, size int) string {
+	h := len(colors) / w
+	step := (w + size - 1) / size
+	if step < 1 {
+		step = 1
+	}
+	vw, vh := v.size(w, h)
+	var small []color.RGBA
+	mw := (w + step - 1) / step
+	for y := 0; y < h; y += step {
+		for x := 0; x < w; x += step {
+			c := colors[y*w+x]
+			// cells on the edge of the viewport are drawn black
+			inside := x+step > v.x && x < v.x+vw && y+step > v.y && y < v.y+vh
+			edge := x <= v.x || x+step >= v.x+vw || y <= v.y || y+step >= v.y+vh
+			if inside && edge {
+				c = color.RGBA{0, 0, 0, 0xFF}
+			}
+			small = append(small, c)
+		}
+	}
+	return strings.TrimSuffix(terminalGrid(small, mw), "\n")
+}
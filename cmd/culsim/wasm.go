@@ -0,0 +1,101 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"syscall/js"
+)
+
+// petri doesn't build for the browser, so the browser build keeps its own
+// grid of cells with the same 8 cell neighbourhood
+
+type cell interface {
+	RGB() int
+	SetRGB(int)
+}
+
+type browserCell struct {
+	rgb int
+}
+
+func (c *browserCell) RGB() int       { return c.rgb }
+func (c *browserCell) SetRGB(rgb int) { c.rgb = rgb }
+
+type grid struct {
+	Units []cell
+}
+
+func (g *grid) CreateCell(x, y, rgb, cellType int) cell {
+	return &browserCell{rgb}
+}
+
+var gridWidth = flag.Int("w", 36, "width of the simulation grid")
+
+// change the width of the grid, when replaying recorded runs
+func setWidth(w int) {
+	width = w
+}
+
+// run the simulation in the browser, drawing the grid on the page's canvas.
+// Flags are given as URL parameters, such as ?n=500&c=0.8&view=palette.
+func main() {
+	query, err := url.ParseQuery(js.Global().Get("location").Get("search").String()[1:])
+	if err != nil {
+		fatal("invalid parameters", "err", err)
+	}
+	var args []string
+	for name, values := range query {
+		for _, v := range values {
+			args = append(args, fmt.Sprintf("-%s=%s", name, v))
+		}
+	}
+	if err = flag.CommandLine.Parse(args); err != nil {
+		fatal("invalid parameters", "err", err)
+	}
+	setupLogging()
+	if err = applyPreset(); err != nil {
+		fatal("invalid preset", "err", err)
+	}
+	width = *gridWidth
+	seedRandom()
+	if *renderEvery < 1 {
+		*renderEvery = 1
+	}
+
+	sim := &CultureSim{}
+	sim.Init()
+	document := js.Global().Get("document")
+	canvas := document.Call("getElementById", "grid")
+	status := document.Call("getElementById", "status")
+	ctx := canvas.Call("getContext", "2d")
+
+	var frame js.Func
+	frame = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		for i := 0; i < *renderEvery && sim.engine.Tick() < *duration; i++ {
+			sim.step()
+		}
+		img := gridImage(sim.cultures())
+		size := img.Bounds().Size()
+		canvas.Set("width", size.X)
+		canvas.Set("height", size.Y)
+		pixels := js.Global().Get("Uint8ClampedArray").New(len(img.Pix))
+		js.CopyBytesToJS(pixels, img.Pix)
+		ctx.Call("putImageData", js.Global().Get("ImageData").New(pixels, size.X, size.Y), 0, 0)
+		status.Set("textContent", fmt.Sprintf("tick %d/%d, average distance %d, %d unique cultures, %d exchanges",
+			sim.engine.Tick(), *duration, sim.engine.Stats().Distance, sim.engine.Stats().Unique, sim.engine.Stats().Exchanges))
+		if sim.engine.Tick() < *duration {
+			js.Global().Call("requestAnimationFrame", frame)
+		}
+		return nil
+	})
+	js.Global().Call("requestAnimationFrame", frame)
+	// keep the program alive for the animation frames
+	select {}
+}
+
+// spans are not exported from the browser
+func shutdownTracing() {}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/sausheong/culsim"
+)
+
+// cultures of the latest tick shown in the web view, the lock also guards
+// the cell history read by the inspector
+var frame struct {
+	sync.Mutex
+	cultures []int
+}
+
+// serve the live web view of the grid in the background
+func serveWeb(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "public/grid.html")
+	})
+	mux.Handle("/public/", http.StripPrefix("/public/", http.FileServer(http.Dir("public"))))
+	mux.HandleFunc("/frame.png", serveFrame)
+	mux.HandleFunc("/cell", serveCell)
+	go func() {
+		fatal("web view stopped", "err", http.ListenAndServe(addr, mux))
+	}()
+}
+
+// make the grid of every tick available to the web view and the inspector
+func (sim *CultureSim) publishFrames(m culsim.Message) {
+	if t, ok := m.(culsim.TickCompleted); ok {
+		publishFrame(t.Tick, sim.cultures())
+	}
+}
+
+// make the grid of the latest tick available to the web view
+func publishFrame(tick int, cultures []int) {
+	frame.Lock()
+	recordHistory(tick, cultures)
+	frame.cultures = cultures
+	frame.Unlock()
+}
+
+// describe the cell at the px and py pixel coordinates of the frame image
+// for the inspector
+func serveCell(w http.ResponseWriter, r *http.Request) {
+	px, errx := strconv.Atoi(r.URL.Query().Get("px"))
+	py, erry := strconv.Atoi(r.URL.Query().Get("py"))
+	x, y := px / *scale, py / *scale
+	if errx != nil || erry != nil || px < 0 || py < 0 || x >= width || y >= width {
+		http.Error(w, "invalid cell", http.StatusBadRequest)
+		return
+	}
+	frame.Lock()
+	cultures := frame.cultures
+	var info cellInfo
+	if cultures != nil {
+		info = inspectCell(cultures, y*width+x)
+	}
+	frame.Unlock()
+	if cultures == nil {
+		http.Error(w, "simulation not started", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// render the latest grid as a PNG image in the current view
+func serveFrame(w http.ResponseWriter, r *http.Request) {
+	frame.Lock()
+	cultures := frame.cultures
+	frame.Unlock()
+	if cultures == nil {
+		http.Error(w, "simulation not started", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = png.Encode(w, gridImage(cultures))
+}
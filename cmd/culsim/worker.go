@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// a parameter set pulled from the job queue, params are flag names and
+// their values
+type job struct {
+	ID     string            `json:"id"`
+	Params map[string]string `json:"params"`
+}
+
+// run jobs from an HTTP job queue until it is empty. The worker takes a job
+// with GET <queue>/next, which answers 204 No Content when there are none,
+// runs it and reports its summary with POST <queue>/results/<id>.
+func runWorker(queue string) {
+	queue = strings.TrimSuffix(queue, "/")
+	self, err := os.Executable()
+	if err != nil {
+		fatal("failed finding the culsim executable", "err", err)
+	}
+	client := &http.Client{Timeout: time.Minute}
+	for {
+		j, err := nextJob(client, queue)
+		if err != nil {
+			slog.Error("failed fetching job", "queue", queue, "err", err)
+		}
+		if j == nil {
+			if *poll <= 0 {
+				slog.Info("job queue is empty", "queue", queue)
+				return
+			}
+			time.Sleep(*poll)
+			continue
+		}
+		slog.Info("running job", "id", j.ID, "params", j.Params)
+		result := runJob(self, j)
+		body, _ := json.Marshal(result)
+		resp, err := client.Post(queue+"/results/"+j.ID, "application/json", bytes.NewReader(body))
+		if err != nil {
+			slog.Error("failed reporting job", "id", j.ID, "err", err)
+			continue
+		}
+		resp.Body.Close()
+		slog.Info("job done", "id", j.ID, "status", result["status"])
+	}
+}
+
+// take the next job from the queue, nil if there is none
+func nextJob(client *http.Client, queue string) (*job, error) {
+	resp, err := client.Get(queue + "/next")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("queue answered %s", resp.Status)
+	}
+	var j job
+	if err = json.NewDecoder(resp.Body).Decode(&j); err != nil {
+		return nil, err
+	}
+	if j.ID == "" {
+		return nil, fmt.Errorf("job has no id")
+	}
+	return &j, nil
+}
+
+// run a job in its own culsim process and return the summary it posts when
+// it ends, or a failure if it ends without one
+func runJob(self string, j *job) map[string]interface{} {
+	// the job posts its summary to a listener of the worker, which passes it
+	// on to the queue
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return map[string]interface{}{"id": j.ID, "status": "failed", "error": err.Error()}
+	}
+	summaries := make(chan map[string]interface{}, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s map[string]interface{}
+		if json.NewDecoder(r.Body).Decode(&s) == nil {
+			select {
+			case summaries <- s:
+			default:
+			}
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	names := make([]string, 0, len(j.Params))
+	for name := range j.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := []string{"-notify-url", "http://" + listener.Addr().String()}
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("-%s=%s", name, j.Params[name]))
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(self, args...)
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	err = cmd.Run()
+
+	var result map[string]interface{}
+	select {
+	case result = <-summaries:
+	default:
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" && err != nil {
+			msg = err.Error()
+		}
+		result = map[string]interface{}{"status": "failed", "error": msg}
+	}
+	// the ID of the job replaces the ID of the run it started
+	if id, ok := result["id"]; ok {
+		result["run"] = id
+	}
+	result["id"] = j.ID
+	return result
+}
@@ -0,0 +1,173 @@
+package culsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config holds the optional simulation settings, which culsim loads from the
+// JSON file given with -config
+type Config struct {
+	Fitness       *FitnessConfig `json:"fitness,omitempty"`
+	Constraints   *Constraints   `json:"constraints,omitempty"`
+	Noise         *NoiseSchedule `json:"noise,omitempty"`
+	Events        []Event        `json:"events,omitempty"`
+	Institutions  *Institutions  `json:"institutions,omitempty"`
+	Minority      *Minority      `json:"minority,omitempty"`
+	FeatureRates  []float64      `json:"featureRates,omitempty"`
+	Decay         *Decay         `json:"decay,omitempty"`
+	Init          *InitConfig    `json:"init,omitempty"`
+	Rule          *ScriptedRule  `json:"rule,omitempty"`
+	Labels        Labels         `json:"labels,omitempty"`
+	Window        *Region        `json:"window,omitempty"`
+	Interventions []Intervention `json:"interventions,omitempty"`
+}
+
+// LoadConfig loads the simulation config from a JSON file, an empty path
+// gives the defaults
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse config %s: %w", path, err)
+	}
+	if err = cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// check the config values are within the model's bounds
+func (cfg *Config) validate() error {
+	if cfg.Fitness != nil {
+		if err := cfg.Fitness.validate(); err != nil {
+			return fmt.Errorf("fitness: %w", err)
+		}
+	}
+	if cfg.Constraints != nil {
+		if err := cfg.Constraints.validate(); err != nil {
+			return fmt.Errorf("constraints: %w", err)
+		}
+	}
+	if cfg.Noise != nil {
+		if err := cfg.Noise.validate(); err != nil {
+			return fmt.Errorf("noise: %w", err)
+		}
+	}
+	for i := range cfg.Events {
+		if err := cfg.Events[i].validate(); err != nil {
+			return fmt.Errorf("event %d: %w", i, err)
+		}
+	}
+	if cfg.Institutions != nil {
+		if err := cfg.Institutions.validate(); err != nil {
+			return fmt.Errorf("institutions: %w", err)
+		}
+	}
+	if cfg.Minority != nil {
+		if err := cfg.Minority.validate(); err != nil {
+			return fmt.Errorf("minority: %w", err)
+		}
+	}
+	if cfg.FeatureRates != nil {
+		if err := validateFeatureRates(cfg.FeatureRates); err != nil {
+			return fmt.Errorf("featureRates: %w", err)
+		}
+	}
+	if cfg.Decay != nil {
+		if err := cfg.Decay.validate(); err != nil {
+			return fmt.Errorf("decay: %w", err)
+		}
+	}
+	if cfg.Init != nil {
+		if err := cfg.Init.validate(); err != nil {
+			return fmt.Errorf("init: %w", err)
+		}
+	}
+	if cfg.Rule != nil {
+		if err := cfg.Rule.validate(); err != nil {
+			return fmt.Errorf("rule: %w", err)
+		}
+	}
+	if err := cfg.Labels.validate(); err != nil {
+		return fmt.Errorf("labels: %w", err)
+	}
+	return nil
+}
+
+// check the cultures, features and traits the config sets fit the features
+// and traits of a run
+func (cfg *Config) fit(features, traits int) error {
+	if cfg.Fitness != nil {
+		for _, s := range cfg.Fitness.Scores {
+			if err := fitsTrait(s.Feature, s.Trait, features, traits); err != nil {
+				return fmt.Errorf("fitness: %w", err)
+			}
+		}
+	}
+	if cfg.Constraints != nil {
+		for _, t := range cfg.Constraints.Taboos {
+			for _, tr := range t.Traits {
+				if err := fitsTrait(tr.Feature, tr.Trait, features, traits); err != nil {
+					return fmt.Errorf("constraints: taboo: %w", err)
+				}
+			}
+		}
+		for _, f := range cfg.Constraints.NonTransmissible {
+			if err := fitsTrait(f, 0, features, traits); err != nil {
+				return fmt.Errorf("constraints: non-transmissible: %w", err)
+			}
+		}
+	}
+	for i := range cfg.Events {
+		if err := cfg.Events[i].fit(features, traits); err != nil {
+			return fmt.Errorf("event %d: %w", i, err)
+		}
+	}
+	if cfg.Minority != nil {
+		if err := fits(cfg.Minority.Culture, features, traits); err != nil {
+			return fmt.Errorf("minority: %w", err)
+		}
+	}
+	if cfg.Institutions != nil {
+		for _, r := range cfg.Institutions.Regions {
+			if r.Culture == nil {
+				continue
+			}
+			if err := fits(*r.Culture, features, traits); err != nil {
+				return fmt.Errorf("institutions: institution %s: %w", r.Name, err)
+			}
+		}
+	}
+	if cfg.Decay != nil {
+		if err := fits(cfg.Decay.Baseline, features, traits); err != nil {
+			return fmt.Errorf("decay: baseline: %w", err)
+		}
+	}
+	if cfg.Init != nil {
+		for _, s := range cfg.Init.Seeds {
+			if err := fits(s, features, traits); err != nil {
+				return fmt.Errorf("init: seed: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// check that a feature and trait are within the culture encoding
+func validTrait(feature, trait int) error {
+	if feature < 0 || feature >= len(masks) {
+		return fmt.Errorf("feature %d out of range [0,%d)", feature, len(masks))
+	}
+	if trait < 0 || trait > 0xF {
+		return fmt.Errorf("trait %d out of range [0,16)", trait)
+	}
+	return nil
+}
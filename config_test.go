@@ -0,0 +1,30 @@
+package culsim
+
+import "testing"
+
+// runs whose config sets cultures, features or traits that don't fit their
+// features and traits are refused instead of drawing traits the cultures
+// can't have
+func TestNewRefusesConfigCulturesThatDontFit(t *testing.T) {
+	big := 0x100000
+	configs := map[string]*Config{
+		"minority": {Minority: &Minority{Culture: big, Region: Region{W: 4, H: 4}, Media: 1}},
+		"institution": {Institutions: &Institutions{Strength: 1,
+			Regions: []Institution{{Name: "x", Region: Region{W: 4, H: 4}, Culture: &big}}}},
+		"decay":             {Decay: &Decay{Baseline: 0x000700, Rate: 0.1}},
+		"seeds":             {Init: &InitConfig{Seeds: []int{0x000001, 0x000005}, K: 2}},
+		"policy":            {Events: []Event{{Type: "policy", Tick: 1, Region: Region{W: 4, H: 4}, Feature: 5, Trait: 12, Rate: 1}}},
+		"policy trait":      {Events: []Event{{Type: "policy", Tick: 1, Region: Region{W: 4, H: 4}, Feature: 1, Trait: 4, Rate: 1}}},
+		"lock":              {Events: []Event{{Type: "lock", Tick: 1, Region: Region{W: 4, H: 4}, Feature: 3}}},
+		"fitness":           {Fitness: &FitnessConfig{Strength: 1, Scores: []TraitScore{{Feature: 0, Trait: 9, Score: 1}}}},
+		"taboo":             {Constraints: &Constraints{Taboos: []Taboo{{Traits: []Trait{{Feature: 4, Trait: 0}}}}}},
+		"non-transmissible": {Constraints: &Constraints{NonTransmissible: []int{3}}},
+	}
+	for name, cfg := range configs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(WithFeatures(3, 4), WithInitial("clusters"), WithConfig(cfg)); err == nil {
+				t.Error("created a run with a config of other features and traits")
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package culsim
+
+// let neighbouring domains of sufficiently different cultures contest the
+// cells on their border. Each contest happens with the conquest rate and is
+// won by either domain with probability proportional to its size, the
+// loser's border cell taking the winner's culture. Returns the number of cells
+// conquered.
+func (e *Engine) conquer() int {
+	labels, sizes := Domains(e.cultures, e.width, e.params.Topology)
+	var conquered int
+	for a := range e.cultures {
+		if labels[a] == -1 {
+			continue
+		}
+		for _, b := range e.neighbours(a) {
+			// visit each border once, between cells that are still populated
+			if b < a || labels[b] == -1 || labels[a] == labels[b] {
+				continue
+			}
+			ca, cb := e.cultures[a], e.cultures[b]
+			if ca == Empty || cb == Empty || e.featureDistance(ca, cb) < e.params.ConquestDistance {
+				continue
+			}
+			if e.rng.Float64() >= e.params.Conquest {
+				continue
+			}
+			sa, sb := sizes[labels[a]], sizes[labels[b]]
+			loser, culture := a, cb
+			if e.rng.Float64()*float64(sa+sb) < float64(sa) {
+				loser, culture = b, ca
+			}
+			// cells frozen outside the window hold out, and conquered cells
+			// keep their locked traits
+			if e.frozen(loser) {
+				continue
+			}
+			if kept, _ := e.keepLocked(loser, culture); kept != culture {
+				if kept == e.cultures[loser] || e.cfg.Constraints.forbids(kept) {
+					continue
+				}
+				culture = kept
+			}
+			e.cultures[loser] = culture
+			conquered++
+		}
+	}
+	return conquered
+}
@@ -0,0 +1,119 @@
+package culsim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Constraints restrict which cultures can exist and which features can be
+// transmitted. A culture carrying every trait of a taboo is invalid, and
+// non-transmissible features are never copied between cells of different
+// groups.
+type Constraints struct {
+	Groups           []Group `json:"groups"`
+	Taboos           []Taboo `json:"taboos"`
+	NonTransmissible []int   `json:"nontransmissible"`
+}
+
+// Group is a named region of the grid, cells outside all groups are treated
+// as a group of their own
+type Group struct {
+	Name   string `json:"name"`
+	Region Region `json:"region"`
+}
+
+// Taboo is a combination of traits that cannot appear together in a culture
+type Taboo struct {
+	Traits []Trait `json:"traits"`
+}
+
+// Trait is a trait value in one feature
+type Trait struct {
+	Feature int `json:"feature"`
+	Trait   int `json:"trait"`
+}
+
+func (c *Constraints) validate() error {
+	for _, g := range c.Groups {
+		if err := g.Region.validate(); err != nil {
+			return fmt.Errorf("group %s: %w", g.Name, err)
+		}
+	}
+	for _, t := range c.Taboos {
+		if len(t.Traits) == 0 {
+			return errors.New("taboo without traits")
+		}
+		for _, tr := range t.Traits {
+			if err := validTrait(tr.Feature, tr.Trait); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range c.NonTransmissible {
+		if err := validTrait(f, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check if a culture breaks any taboo
+func (c *Constraints) forbids(culture int) bool {
+	if c == nil {
+		return false
+	}
+	for _, t := range c.Taboos {
+		broken := true
+		for _, tr := range t.Traits {
+			if extract(culture, uint(tr.Feature)) != tr.Trait {
+				broken = false
+				break
+			}
+		}
+		if broken {
+			return true
+		}
+	}
+	return false
+}
+
+// check if a feature can be copied from the cell at src to the cell at dst
+func (e *Engine) transmissible(src, dst, feature int) bool {
+	c := e.cfg.Constraints
+	if c == nil || e.groups == nil || e.groups[src] == e.groups[dst] {
+		return true
+	}
+	for _, f := range c.NonTransmissible {
+		if f == feature {
+			return false
+		}
+	}
+	return true
+}
+
+// index of the group the cell at n belongs to, -1 if it is in none
+func (c *Constraints) group(n, width int) int {
+	for i, g := range c.Groups {
+		if g.Region.contains(n, width) {
+			return i
+		}
+	}
+	return -1
+}
+
+// the group of every cell of a grid, row by row, looked up once so that
+// exchanges don't search the regions of the groups, nil if there are none
+func (c *Constraints) cellGroups(width, height int) []int {
+	if c == nil || len(c.Groups) == 0 {
+		return nil
+	}
+	groups := make([]int, width*height)
+	for n := range groups {
+		groups[n] = c.group(n, width)
+	}
+	return groups
+}
+
+// Groups is the index of the constraint group of each cell, row by row, -1
+// for cells in none, or nil if the config has no groups
+func (e *Engine) Groups() []int { return append([]int(nil), e.groups...) }
@@ -0,0 +1,31 @@
+package culsim
+
+// copy the traits of up to Copies - 1 more features the culture of dst
+// differs from src in, in random order, into culture, which already has the
+// trait of feature i copied. Features that feature rates, constraints or
+// locks keep from being copied are skipped.
+func (e *Engine) copyMore(src, dst, i, culture int) int {
+	from := e.cultures[src]
+	var differing [Features]int
+	features := differing[:0]
+	for f := 0; f < e.features; f++ {
+		if f != i && extract(from, uint(f)) != extract(culture, uint(f)) {
+			features = append(features, f)
+		}
+	}
+	e.rng.Shuffle(len(features), func(a, b int) { features[a], features[b] = features[b], features[a] })
+	copied := 1
+	for _, f := range features {
+		if copied == e.params.Copies {
+			break
+		}
+		rp := replace(culture, extract(from, uint(f)), uint(f))
+		if !e.featureUpdates(f) || !e.transmissible(src, dst, f) ||
+			e.cfg.Constraints.forbids(rp) || e.locked(dst, f) {
+			continue
+		}
+		culture = rp
+		copied++
+	}
+	return culture
+}
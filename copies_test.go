@@ -0,0 +1,64 @@
+package culsim
+
+import "testing"
+
+// an exchange copies the trait of its feature and of up to Copies - 1 more
+// features the cultures differ in, skipping those that locks, feature
+// rates, constraints and taboos keep from being copied
+func TestCopies(t *testing.T) {
+	lock := func(features ...int) []Event {
+		var events []Event
+		for _, f := range features {
+			events = append(events, Event{Type: "lock", Region: Region{W: 2, H: 1}, Feature: f})
+		}
+		return events
+	}
+	cases := []struct {
+		name    string
+		copies  int
+		dst     int // culture copying from 0x000000
+		cfg     Config
+		copied  int
+		blocked []int // features keeping their traits
+	}{
+		{name: "one trait", copies: 1, dst: 0x111111, copied: 1},
+		{name: "some traits", copies: 3, dst: 0x111111, copied: 3},
+		{name: "every trait", copies: 6, dst: 0x111111, copied: 6},
+		{name: "more than there are", copies: 10, dst: 0x111111, copied: 6},
+		{name: "every differing trait", copies: 4, dst: 0x000111, copied: 3},
+		{name: "locks", copies: 6, dst: 0x111111, cfg: Config{Events: lock(2, 3)}, copied: 4, blocked: []int{2, 3}},
+		{name: "locks leave others to copy", copies: 3, dst: 0x111111, cfg: Config{Events: lock(2, 3)}, copied: 3,
+			blocked: []int{2, 3}},
+		{name: "feature rates", copies: 6, dst: 0x111111, cfg: Config{FeatureRates: []float64{1, 1, 1, 1, 0, 1}},
+			copied: 5, blocked: []int{4}},
+		{name: "non-transmissible", copies: 6, dst: 0x111111, cfg: Config{Constraints: &Constraints{
+			Groups: []Group{{Name: "src", Region: Region{W: 1, H: 1}}}, NonTransmissible: []int{5}}},
+			copied: 5, blocked: []int{5}},
+		{name: "taboo", copies: 6, dst: 0x111111, cfg: Config{Constraints: &Constraints{
+			Taboos: []Taboo{{Traits: []Trait{{Feature: 1, Trait: 0}, {Feature: 2, Trait: 0}}}}}}, copied: 5},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := c.cfg
+			e := newEngine(Params{Width: 2, Height: 1, Features: Features, Traits: 0x10, Copies: c.copies, Config: &cfg})
+			for run := 0; run < 20; run++ {
+				e.cultures[0], e.cultures[1] = 0x000000, c.dst
+				culture := e.copyMore(0, 1, 0, replace(c.dst, 0, 0))
+				var copied int
+				for f := 0; f < Features; f++ {
+					if extract(c.dst, uint(f)) != 0 && extract(culture, uint(f)) == 0 {
+						copied++
+					}
+				}
+				if copied != c.copied {
+					t.Fatalf("copied %d traits into %06X, %06X, want %d", copied, c.dst, culture, c.copied)
+				}
+				for _, f := range c.blocked {
+					if extract(culture, uint(f)) != extract(c.dst, uint(f)) {
+						t.Fatalf("copied the trait of blocked feature %d, %06X", f, culture)
+					}
+				}
+			}
+		})
+	}
+}
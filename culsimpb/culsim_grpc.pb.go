@@ -0,0 +1,297 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: culsimpb/culsim.proto
+
+package culsimpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SimulationClient is the client API for Simulation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SimulationClient interface {
+	// start a new simulation, with flags to set on top of the server's own
+	CreateSim(ctx context.Context, in *CreateSimRequest, opts ...grpc.CallOption) (*CreateSimResponse, error)
+	// run a number of ticks
+	Step(ctx context.Context, in *StepRequest, opts ...grpc.CallOption) (*Metrics, error)
+	// cultures of all the cells
+	GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*State, error)
+	// metrics of the latest tick
+	GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*Metrics, error)
+	// save a checkpoint that culsim render can replay and -resume carry on
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotResponse, error)
+	// change the simulation before the next tick
+	Intervene(ctx context.Context, in *InterveneRequest, opts ...grpc.CallOption) (*InterveneResponse, error)
+}
+
+type simulationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSimulationClient(cc grpc.ClientConnInterface) SimulationClient {
+	return &simulationClient{cc}
+}
+
+func (c *simulationClient) CreateSim(ctx context.Context, in *CreateSimRequest, opts ...grpc.CallOption) (*CreateSimResponse, error) {
+	out := new(CreateSimResponse)
+	err := c.cc.Invoke(ctx, "/culsim.v1.Simulation/CreateSim", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulationClient) Step(ctx context.Context, in *StepRequest, opts ...grpc.CallOption) (*Metrics, error) {
+	out := new(Metrics)
+	err := c.cc.Invoke(ctx, "/culsim.v1.Simulation/Step", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulationClient) GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*State, error) {
+	out := new(State)
+	err := c.cc.Invoke(ctx, "/culsim.v1.Simulation/GetState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulationClient) GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*Metrics, error) {
+	out := new(Metrics)
+	err := c.cc.Invoke(ctx, "/culsim.v1.Simulation/GetMetrics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulationClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotResponse, error) {
+	out := new(SnapshotResponse)
+	err := c.cc.Invoke(ctx, "/culsim.v1.Simulation/Snapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulationClient) Intervene(ctx context.Context, in *InterveneRequest, opts ...grpc.CallOption) (*InterveneResponse, error) {
+	out := new(InterveneResponse)
+	err := c.cc.Invoke(ctx, "/culsim.v1.Simulation/Intervene", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SimulationServer is the server API for Simulation service.
+// All implementations must embed UnimplementedSimulationServer
+// for forward compatibility
+type SimulationServer interface {
+	// start a new simulation, with flags to set on top of the server's own
+	CreateSim(context.Context, *CreateSimRequest) (*CreateSimResponse, error)
+	// run a number of ticks
+	Step(context.Context, *StepRequest) (*Metrics, error)
+	// cultures of all the cells
+	GetState(context.Context, *GetStateRequest) (*State, error)
+	// metrics of the latest tick
+	GetMetrics(context.Context, *GetMetricsRequest) (*Metrics, error)
+	// save a checkpoint that culsim render can replay and -resume carry on
+	Snapshot(context.Context, *SnapshotRequest) (*SnapshotResponse, error)
+	// change the simulation before the next tick
+	Intervene(context.Context, *InterveneRequest) (*InterveneResponse, error)
+	mustEmbedUnimplementedSimulationServer()
+}
+
+// UnimplementedSimulationServer must be embedded to have forward compatible implementations.
+type UnimplementedSimulationServer struct {
+}
+
+func (UnimplementedSimulationServer) CreateSim(context.Context, *CreateSimRequest) (*CreateSimResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSim not implemented")
+}
+func (UnimplementedSimulationServer) Step(context.Context, *StepRequest) (*Metrics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Step not implemented")
+}
+func (UnimplementedSimulationServer) GetState(context.Context, *GetStateRequest) (*State, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetState not implemented")
+}
+func (UnimplementedSimulationServer) GetMetrics(context.Context, *GetMetricsRequest) (*Metrics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetrics not implemented")
+}
+func (UnimplementedSimulationServer) Snapshot(context.Context, *SnapshotRequest) (*SnapshotResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Snapshot not implemented")
+}
+func (UnimplementedSimulationServer) Intervene(context.Context, *InterveneRequest) (*InterveneResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Intervene not implemented")
+}
+func (UnimplementedSimulationServer) mustEmbedUnimplementedSimulationServer() {}
+
+// UnsafeSimulationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SimulationServer will
+// result in compilation errors.
+type UnsafeSimulationServer interface {
+	mustEmbedUnimplementedSimulationServer()
+}
+
+func RegisterSimulationServer(s grpc.ServiceRegistrar, srv SimulationServer) {
+	s.RegisterService(&Simulation_ServiceDesc, srv)
+}
+
+func _Simulation_CreateSim_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSimRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulationServer).CreateSim(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/culsim.v1.Simulation/CreateSim",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulationServer).CreateSim(ctx, req.(*CreateSimRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulation_Step_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StepRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulationServer).Step(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/culsim.v1.Simulation/Step",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulationServer).Step(ctx, req.(*StepRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulation_GetState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulationServer).GetState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/culsim.v1.Simulation/GetState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulationServer).GetState(ctx, req.(*GetStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulation_GetMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulationServer).GetMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/culsim.v1.Simulation/GetMetrics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulationServer).GetMetrics(ctx, req.(*GetMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulation_Snapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulationServer).Snapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/culsim.v1.Simulation/Snapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulationServer).Snapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulation_Intervene_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InterveneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulationServer).Intervene(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/culsim.v1.Simulation/Intervene",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulationServer).Intervene(ctx, req.(*InterveneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Simulation_ServiceDesc is the grpc.ServiceDesc for Simulation service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Simulation_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "culsim.v1.Simulation",
+	HandlerType: (*SimulationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSim",
+			Handler:    _Simulation_CreateSim_Handler,
+		},
+		{
+			MethodName: "Step",
+			Handler:    _Simulation_Step_Handler,
+		},
+		{
+			MethodName: "GetState",
+			Handler:    _Simulation_GetState_Handler,
+		},
+		{
+			MethodName: "GetMetrics",
+			Handler:    _Simulation_GetMetrics_Handler,
+		},
+		{
+			MethodName: "Snapshot",
+			Handler:    _Simulation_Snapshot_Handler,
+		},
+		{
+			MethodName: "Intervene",
+			Handler:    _Simulation_Intervene_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "culsimpb/culsim.proto",
+}
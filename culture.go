@@ -0,0 +1,104 @@
+package culsim
+
+// Empty is the culture of an unpopulated cell
+const Empty = 0xFFFFFF
+
+// Features is the number of features of a culture, each a 4 bit trait
+const Features = 6
+
+// masks used to replace the traits
+var masks = []int{0xFFFFF0, 0xFFFF0F, 0xFFF0FF, 0xFF0FFF, 0xF0FFFF, 0x0FFFFF}
+
+// FeatureTrait is the trait of a culture in one feature
+func FeatureTrait(culture, feature int) int {
+	return extract(culture, uint(feature))
+}
+
+// SharedFeatures is the number of features on which 2 cultures have the
+// same trait
+func SharedFeatures(a, b int) int {
+	return sharedFeatures(a, b, Features)
+}
+
+// extract trait for 1 feature
+func extract(n int, pos uint) int {
+	return (n >> (4 * pos)) & 0x00000F
+}
+
+// replace the trait in 1 feature, only the 4 bits of a trait are taken from
+// the replacement so it can't spill into the other features
+func replace(n, replacement int, pos uint) int {
+	i1 := n & masks[pos]
+	mask2 := (replacement & 0x00000F) << (4 * pos)
+	return (i1 | mask2)
+}
+
+// find the distance of 2 numbers at position pos
+func traitDistance(n1, n2 int, pos uint) int {
+	d := extract(n1, pos) - extract(n2, pos)
+	if d < 0 {
+		return d * -1
+	}
+	return d
+}
+
+// distance between 2 cultures, the number of features they differ in
+func (e *Engine) featureDistance(n1, n2 int) int {
+	var features int = 0
+	for i := 0; i < e.features; i++ {
+		f1, f2 := extract(n1, uint(i)), extract(n2, uint(i))
+		if f1 == f2 {
+			features++
+		}
+	}
+	return e.features - features
+}
+
+// number of the engine's features on which 2 cultures have the same trait
+func (e *Engine) sharedFeatures(n1, n2 int) int {
+	return sharedFeatures(n1, n2, e.features)
+}
+
+// number of the first features on which 2 cultures have the same trait
+func sharedFeatures(n1, n2, features int) int {
+	var shared int
+	for i := 0; i < features; i++ {
+		if extract(n1, uint(i)) == extract(n2, uint(i)) {
+			shared++
+		}
+	}
+	return shared
+}
+
+// randomly choose one of the features on which 2 different cultures differ
+func (e *Engine) randomDifferingFeature(n1, n2 int) int {
+	var features []int
+	for i := 0; i < e.features; i++ {
+		if extract(n1, uint(i)) != extract(n2, uint(i)) {
+			features = append(features, i)
+		}
+	}
+	return features[e.rng.Intn(len(features))]
+}
+
+// a random culture that breaks no taboo
+func (e *Engine) randomCulture() int {
+	culture := e.drawCulture()
+	for e.cfg.Constraints.forbids(culture) {
+		culture = e.drawCulture()
+	}
+	return culture
+}
+
+// a random culture, with the traits of features the engine doesn't have
+// left at 0
+func (e *Engine) drawCulture() int {
+	if e.features == Features && e.traits == 0x10 {
+		return e.rng.Intn(0xFFFFFF)
+	}
+	var culture int
+	for i := 0; i < e.features; i++ {
+		culture = replace(culture, e.rng.Intn(e.traits), uint(i))
+	}
+	return culture
+}
@@ -0,0 +1,130 @@
+package culsim
+
+import (
+	"testing"
+	"testing/quick"
+)
+
+// every mask clears the 4 bits of its feature and keeps the other features
+func TestMasks(t *testing.T) {
+	if len(masks) != Features {
+		t.Fatalf("%d masks for %d features", len(masks), Features)
+	}
+	for pos, mask := range masks {
+		slot := 0xF << (4 * pos)
+		if mask&slot != 0 || mask|slot != 0xFFFFFF {
+			t.Errorf("mask %d is %06X, want all bits but %06X", pos, mask, slot)
+		}
+	}
+}
+
+// a replaced trait is extracted as it was put in, the other features are
+// left as they were and the culture stays within 24 bits
+func TestReplaceExtract(t *testing.T) {
+	f := func(culture uint32, trait, pos uint8) bool {
+		return checkReplace(t, int(culture&0xFFFFFF), int(trait&0xF), uint(pos%Features))
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// traits too large for 4 bits don't spill into the next feature
+func TestReplaceOutOfRange(t *testing.T) {
+	for pos := uint(0); pos < Features; pos++ {
+		if got := replace(0x000000, 0x1F, pos); got != 0xF<<(4*pos) {
+			t.Errorf("replace(0, 0x1F, %d) = %06X, want %06X", pos, got, 0xF<<(4*pos))
+		}
+		if got := replace(0xFFFFFF, 0x10, pos); got != masks[pos] {
+			t.Errorf("replace(FFFFFF, 0x10, %d) = %06X, want %06X", pos, got, masks[pos])
+		}
+	}
+}
+
+// the distance between traits is symmetric, 0 only for the same trait, at
+// most 15 and obeys the triangle inequality
+func TestTraitDistance(t *testing.T) {
+	f := func(a, b, c uint32, pos uint8) bool {
+		x, y, z, p := int(a&0xFFFFFF), int(b&0xFFFFFF), int(c&0xFFFFFF), uint(pos%Features)
+		d := traitDistance(x, y, p)
+		return d == traitDistance(y, x, p) &&
+			(d == 0) == (extract(x, p) == extract(y, p)) &&
+			d >= 0 && d <= 0xF &&
+			traitDistance(x, z, p) <= d+traitDistance(y, z, p)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// the features 2 cultures share are those whose traits are the same
+func TestSharedFeatures(t *testing.T) {
+	f := func(a, b uint32) bool {
+		x, y := int(a&0xFFFFFF), int(b&0xFFFFFF)
+		var same int
+		for pos := uint(0); pos < Features; pos++ {
+			if traitDistance(x, y, pos) == 0 {
+				same++
+			}
+		}
+		return SharedFeatures(x, y) == same && SharedFeatures(y, x) == same && SharedFeatures(x, x) == Features
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// the distance between 2 cultures counts every feature they differ in, the
+// last one too, and the total distance adds up the traits of every feature
+func TestFeatureDistance(t *testing.T) {
+	for _, features := range []int{1, 3, Features} {
+		e := newEngine(Params{Width: 2, Height: 1, Features: features, Traits: 0x10})
+		f := func(a, b uint32) bool {
+			x, y := int(a&0xFFFFFF), int(b&0xFFFFFF)
+			e.cultures[0], e.cultures[1] = x, y
+			var differ, d int
+			for pos := uint(0); pos < uint(features); pos++ {
+				if dist := traitDistance(x, y, pos); dist > 0 {
+					differ++
+					d += dist
+				}
+			}
+			return e.featureDistance(x, y) == differ && e.featureDistance(x, x) == 0 && e.diff(0, 1) == d
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Errorf("%d features: %v", features, err)
+		}
+	}
+}
+
+func FuzzReplace(f *testing.F) {
+	f.Add(0x000000, 0, uint(0))
+	f.Add(0xFFFFFF, 15, uint(5))
+	f.Add(0x123456, 0x1F, uint(2))
+	f.Add(Empty, 7, uint(3))
+	f.Fuzz(func(t *testing.T, culture, trait int, pos uint) {
+		checkReplace(t, culture&0xFFFFFF, trait&0xF, pos%Features)
+	})
+}
+
+// check replacing the trait of a culture at pos, reporting what breaks
+func checkReplace(t *testing.T, culture, trait int, pos uint) bool {
+	t.Helper()
+	got := replace(culture, trait, pos)
+	ok := true
+	if got < 0 || got > 0xFFFFFF {
+		t.Errorf("replace(%06X, %X, %d) = %X is not 24 bits", culture, trait, pos, got)
+		ok = false
+	}
+	if extract(got, pos) != trait {
+		t.Errorf("replace(%06X, %X, %d) = %06X has trait %X", culture, trait, pos, got, extract(got, pos))
+		ok = false
+	}
+	for other := uint(0); other < Features; other++ {
+		if other != pos && extract(got, other) != extract(culture, other) {
+			t.Errorf("replace(%06X, %X, %d) = %06X changed feature %d", culture, trait, pos, got, other)
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,60 @@
+package culsim
+
+import "errors"
+
+// Decay relaxes traits back towards a baseline culture. Every tick, each
+// trait that differs from the baseline and isn't shared by any neighbour moves
+// one step towards the baseline trait with probability rate.
+type Decay struct {
+	Baseline int     `json:"baseline"`
+	Rate     float64 `json:"rate"`
+}
+
+func (d *Decay) validate() error {
+	if d.Baseline < 0 || d.Baseline >= Empty {
+		return errors.New("baseline out of range")
+	}
+	if d.Rate < 0 || d.Rate > 1 {
+		return errors.New("rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// let unreinforced traits drift towards the baseline culture
+func (e *Engine) decay() {
+	if e.cfg.Decay == nil || e.cfg.Decay.Rate == 0 {
+		return
+	}
+	for n, culture := range e.cultures {
+		if culture == Empty || e.frozen(n) {
+			continue
+		}
+		neighbours := e.neighbours(n)
+		for i := 0; i < e.features; i++ {
+			f := uint(i)
+			trait, base := extract(culture, f), extract(e.cfg.Decay.Baseline, f)
+			if trait == base || e.locked(n, i) || e.reinforced(neighbours, f, trait) || e.rng.Float64() >= e.cfg.Decay.Rate {
+				continue
+			}
+			if trait < base {
+				trait++
+			} else {
+				trait--
+			}
+			if rp := replace(culture, trait, f); !e.cfg.Constraints.forbids(rp) {
+				culture = rp
+			}
+		}
+		e.cultures[n] = culture
+	}
+}
+
+// check if any of the neighbouring cells shares a trait
+func (e *Engine) reinforced(neighbours []int, f uint, trait int) bool {
+	for _, neighbour := range neighbours {
+		if c := e.cultures[neighbour]; c != Empty && extract(c, f) == trait {
+			return true
+		}
+	}
+	return false
+}
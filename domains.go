@@ -0,0 +1,85 @@
+package culsim
+
+// Domains labels the connected domains of identical culture in a grid of
+// cultures of the given width, connected through the neighbours of the
+// topology, returning the domain of each cell and the size of each domain.
+// Empty cells are in no domain and labelled -1.
+func Domains(cultures []int, width int, topology Topology) ([]int, []int) {
+	height := len(cultures) / width
+	labels := make([]int, len(cultures))
+	for n := range labels {
+		labels[n] = -1
+	}
+	var sizes []int
+	for n, culture := range cultures {
+		if labels[n] != -1 || culture == Empty {
+			continue
+		}
+		// flood fill the domain starting from this cell
+		d := len(sizes)
+		labels[n] = d
+		size, stack := 0, []int{n}
+		for len(stack) > 0 {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			size++
+			for _, neighbour := range neighbours(c, width, height, topology) {
+				if labels[neighbour] == -1 && cultures[neighbour] == culture {
+					labels[neighbour] = d
+					stack = append(stack, neighbour)
+				}
+			}
+		}
+		sizes = append(sizes, size)
+	}
+	return labels, sizes
+}
+
+// Neighbours are the indexes of the neighbours of the cell at index n in a
+// grid of the given width and height under the topology, in row order, the
+// neighbourhood of the model's interactions and of its domains
+func Neighbours(n, width, height int, topology Topology) []int {
+	return neighbours(n, width, height, topology)
+}
+
+// indexes of the neighbours of the cell at index n in a grid of the given
+// width and height, in row order
+func neighbours(n, width, height int, topology Topology) []int {
+	x, y := n%width, n/width
+	var found []int
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 || topology == VonNeumann && dx != 0 && dy != 0 {
+				continue
+			}
+			nx, ny := x+dx, y+dy
+			if topology == Torus {
+				nx, ny = (nx+width)%width, (ny+height)%height
+			} else if nx < 0 || nx >= width || ny < 0 || ny >= height {
+				continue
+			}
+			found = append(found, ny*width+nx)
+		}
+	}
+	return found
+}
+
+// Stable reports if the grid can no longer change under Axelrod's rule, with
+// every pair of neighbouring cells sharing either all of their features or
+// none of them
+func (e *Engine) Stable() bool {
+	for n, culture := range e.cultures {
+		if culture == Empty {
+			continue
+		}
+		for _, neighbour := range e.neighbours(n) {
+			if e.cultures[neighbour] == Empty {
+				continue
+			}
+			if shared := e.sharedFeatures(culture, e.cultures[neighbour]); shared != 0 && shared != e.features {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,427 @@
+// Package culsim simulates the dissemination of culture on a grid of cells,
+// after Axelrod's model. Every culture is a 24 bit number of up to 6 features
+// with up to 16 traits each, neighbouring cells interact and copy traits from
+// each other until the grid settles into cultural domains.
+package culsim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/sausheong/culsim")
+
+// Stats are the metrics of the latest tick, Distance and Unique are 0 if
+// their metrics are left out
+type Stats struct {
+	Distance  int // average feature distance between neighbours
+	Exchanges int // number of cultural exchanges
+	Unique    int // number of unique cultures
+	Conquered int // number of cells conquered
+	Mutations int // number of traits changed by noise
+}
+
+// Engine holds the state of a simulation
+type Engine struct {
+	params   Params
+	cfg      *Config
+	width    int
+	height   int
+	features int
+	traits   int
+	tick     int
+	seed     int64
+	source   *countingSource // source of rng, counting the numbers drawn
+	rng      *rand.Rand
+	cultures []int
+	groups   []int    // constraint group of every cell, nil without groups
+	registry []Metric // metrics measured every tick
+	metrics  []Series
+	stats    Stats
+
+	lastExchange        *exchangeLog   // tick each pair of cells last exchanged a trait
+	influences          []int          // successful influences of each cell
+	institutions        []int          // current cultures of the institutions
+	minorityPersistence int            // last tick the minority culture was present
+	invader             int            // culture of the invaders, empty if there are none
+	interactions        int            // interactions of the current tick
+	interventions       []Intervention // interventions applied so far
+
+	bus     *Bus           // where ticks and exchanges are published
+	tile    *tile          // part of the grid exchanges are limited to in a parallel tick
+	checked *checked       // state at the previous Check
+	tally   *exchangeStats // interactions of the tick by outcome, nil unless counted
+	perf    *performance   // wall time and allocations of the tick, nil unless measured
+}
+
+// Series is a metric recorded at every tick
+type Series struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// New creates an engine and populates its grid, with the default parameters
+// changed by the options
+func New(opts ...Option) (*Engine, error) {
+	p := defaultParams()
+	for _, opt := range opts {
+		opt(&p)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	e := newEngine(p)
+	sample, err := e.sampler(p.Initial, e.cfg.Init)
+	if err != nil {
+		return nil, err
+	}
+	for n := range e.cultures {
+		if e.rng.Float64() < p.Coverage {
+			e.cultures[n] = sample()
+		}
+	}
+	if p.Initial == "blobs" {
+		if err = e.placeBlobs(e.cfg.Init); err != nil {
+			return nil, err
+		}
+	}
+	e.initInstitutions()
+	e.seedMinority()
+	if err = e.registerBuiltins(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// an engine with an empty grid
+func newEngine(p Params) *Engine {
+	if p.Config == nil {
+		p.Config = &Config{}
+	}
+	source := &countingSource{Source64: rand.NewSource(p.Seed).(rand.Source64)}
+	e := &Engine{
+		params:       p,
+		cfg:          p.Config,
+		width:        p.Width,
+		height:       p.Height,
+		features:     p.Features,
+		traits:       p.Traits,
+		seed:         p.Seed,
+		source:       source,
+		rng:          rand.New(source),
+		cultures:     make([]int, p.Width*p.Height),
+		lastExchange: &exchangeLog{ticks: make(map[[2]int]int)},
+		influences:   make([]int, p.Width*p.Height),
+		groups:       p.Config.Constraints.cellGroups(p.Width, p.Height),
+		invader:      Empty,
+		bus:          &Bus{},
+	}
+	for n := range e.cultures {
+		e.cultures[n] = Empty
+	}
+	return e
+}
+
+// Run steps the simulation until it reaches the tick given by
+// Params.Duration, returning the metrics recorded so far. If ctx is
+// cancelled or its deadline passes first, Run stops between ticks and
+// returns the partial metrics with the context's error. A stopped engine
+// can carry on with another call to Run.
+func (e *Engine) Run(ctx context.Context) ([]Series, error) {
+	for e.tick < e.params.Duration {
+		if err := ctx.Err(); err != nil {
+			return e.Metrics(), err
+		}
+		e.Step(ctx)
+	}
+	return e.Metrics(), nil
+}
+
+// Step runs one tick of the simulation and records its metrics. The tick
+// and each of its phases are traced as spans, children of any span in ctx.
+func (e *Engine) Step(ctx context.Context) {
+	var chg, mutated int
+
+	e.replayInterventions()
+	e.tick++
+	e.perf.start()
+	ctx, span := tracer.Start(ctx, "tick", trace.WithAttributes(attribute.Int("tick", e.tick)))
+	defer span.End()
+	phase(ctx, "events", e.applyEvents)
+	phase(ctx, "institutions", e.governInstitutions)
+	phase(ctx, "minority", e.broadcastMinority)
+	phase(ctx, "decay", e.decay)
+
+	var conquered int
+	if e.params.Conquest > 0 {
+		phase(ctx, "conquest", func() { conquered = e.conquer() })
+	}
+
+	_, exchangeSpan := tracer.Start(ctx, "exchanges")
+	e.tally.reset()
+	e.interactions = e.tickInteractions()
+	if e.params.Workers > 0 {
+		chg, mutated = e.exchangeTiles()
+	} else {
+		chg, mutated = e.exchange(e.interactions)
+	}
+	exchangeSpan.SetAttributes(attribute.Int("exchanges", chg))
+	exchangeSpan.End()
+
+	e.perf.stop()
+	phase(ctx, "record", func() {
+		e.stats = Stats{Exchanges: chg, Conquered: conquered, Mutations: mutated}
+		e.measureMetrics()
+		e.trackMinority()
+		e.stats.Distance, e.stats.Unique = int(e.value("distance")), int(e.value("unique"))
+	})
+	phase(ctx, "publish", func() { e.bus.Publish(TickCompleted{e.tick, e.stats}) })
+}
+
+// run the interactions of a tick between cells of the grid, or of the tile
+// of a parallel tick, returning the number of exchanges and of mutations
+func (e *Engine) exchange(interactions int) (int, int) {
+	var chg, mutated int
+	rate := e.noiseRate()
+	axelrod := e.params.Rule == Axelrod && e.cfg.Rule == nil
+	var drawn []int
+	if e.params.Shuffle {
+		drawn = e.shuffled(interactions)
+		interactions = len(drawn)
+	}
+	for c := 0; c < interactions; c++ {
+		// randomly choose one cell
+		var r int
+		if drawn != nil {
+			r = drawn[c]
+		} else {
+			r = e.initiator()
+		}
+		if rate > 0 && e.rng.Float64() < rate && e.mutate(r) {
+			mutated++
+		}
+		if e.cultures[r] != Empty {
+			if e.params.Colonization > 0 {
+				e.colonize(r)
+			}
+			// find all its neighbours, or the ones the partner strategy chooses
+			neighbours := e.partners(r, axelrod)
+			for _, neighbour := range neighbours {
+				if e.cultures[neighbour] == Empty || e.resting(r, neighbour) {
+					continue
+				}
+				e.tally.attempt()
+				// cultural exchange happens on the feature the rule chooses
+				i := e.interact(r, neighbour)
+				if i < 0 {
+					continue
+				}
+				if !e.featureUpdates(i) {
+					e.tally.block()
+					continue
+				}
+				a, b := r, neighbour
+				if axelrod {
+					// the chosen cell takes on the trait of its neighbour
+					a, b = neighbour, r
+				}
+				src, dst := e.direction(a, b)
+				replacement := extract(e.cultures[src], uint(i))
+				rp := replace(e.cultures[dst], replacement, uint(i))
+				// taboo cultures, non-transmissible and locked features block the
+				// exchange as do protected minority cells that keep their culture
+				// and cells frozen outside the window
+				if e.frozen(dst) || !e.transmissible(src, dst, i) || e.cfg.Constraints.forbids(rp) || e.retains(dst) ||
+					e.locked(dst, i) {
+					e.tally.block()
+					continue
+				}
+				if e.params.Copies > 1 {
+					rp = e.copyMore(src, dst, i, rp)
+				}
+				e.cultures[dst] = rp
+				e.exchanged(r, neighbour)
+				e.influences[src]++
+				e.tally.copy(i)
+				if e.bus.active() {
+					e.bus.Publish(ExchangeHappened{e.tick, src, dst, i, rp})
+				}
+				chg++
+			}
+		}
+	}
+	return chg, mutated
+}
+
+// run a phase of a tick in its own span
+func phase(ctx context.Context, name string, f func()) {
+	_, span := tracer.Start(ctx, name)
+	f()
+	span.End()
+}
+
+// add the value of a metric in the current tick
+func (e *Engine) record(name string, v float64) {
+	for i := range e.metrics {
+		if e.metrics[i].Name == name {
+			e.metrics[i].Values = append(e.metrics[i].Values, v)
+			return
+		}
+	}
+}
+
+// the feature whose trait 2 neighbouring cells exchange under the rule of
+// the engine, or the scripted rule of its config, -1 if they don't interact
+func (e *Engine) interact(a, b int) int {
+	if e.cfg.Rule != nil {
+		return e.cfg.Rule.interact(e, a, b)
+	}
+	if e.params.Rule == Axelrod {
+		shared := e.sharedFeatures(e.cultures[a], e.cultures[b])
+		if shared == e.features {
+			e.tally.same()
+			return -1
+		}
+		if e.rng.Float64() >= float64(shared)/float64(e.features) {
+			e.tally.reject()
+			return -1
+		}
+		return e.randomDifferingFeature(e.cultures[a], e.cultures[b])
+	}
+	// cultural differences between the neighbour
+	d := e.diff(a, b)
+	// probability of a cultural exchange happening
+	var shared int
+	if e.params.Exchange == Overlap {
+		shared = e.sharedFeatures(e.cultures[a], e.cultures[b])
+	}
+	if e.rng.Float64() >= e.exchangeProbability(d, shared) {
+		e.tally.reject()
+		return -1
+	}
+	if e.params.DifferingOnly {
+		if d == 0 {
+			e.tally.same()
+			return -1
+		}
+		return e.randomDifferingFeature(e.cultures[a], e.cultures[b])
+	}
+	// randomly select one of the features
+	i := e.rng.Intn(e.features)
+	if d == 0 {
+		e.tally.same()
+		return -1
+	}
+	return i
+}
+
+// choose which of 2 neighbouring cells is copied from in an exchange,
+// returning the source and the destination
+func (e *Engine) direction(a, b int) (int, int) {
+	if e.cfg.Fitness == nil && e.params.ReputationBias == 0 && e.invader == Empty {
+		// randomly select either trait to be replaced by the neighbour's
+		if e.rng.Intn(1) == 0 {
+			return a, b
+		}
+		return b, a
+	}
+	// otherwise the fitter or more reputable cell is more likely to be copied
+	wa, wb := e.reputationWeight(a)*e.prestige(a), e.reputationWeight(b)*e.prestige(b)
+	if e.cfg.Fitness != nil {
+		wa *= e.cfg.Fitness.weight(e.cultures[a])
+		wb *= e.cfg.Fitness.weight(e.cultures[b])
+	}
+	if wa+wb == 0 || e.rng.Float64()*(wa+wb) < wa {
+		return a, b
+	}
+	return b, a
+}
+
+// total distance between traits for all features, between 2 cultures
+func (e *Engine) diff(a1, a2 int) int {
+	var d int
+	for i := 0; i < e.features; i++ {
+		d = d + traitDistance(e.cultures[a1], e.cultures[a2], uint(i))
+	}
+	return d
+}
+
+// average feature distance for the whole grid
+func (e *Engine) featureDistAvg() int {
+	var count int
+	var dist int
+	for c := range e.cultures {
+		neighbours := e.neighbours(c)
+		for _, neighbour := range neighbours {
+			if e.cultures[neighbour] != 0x0000 {
+				count++
+				dist = dist + e.featureDistance(e.cultures[c], e.cultures[neighbour])
+			}
+		}
+	}
+	return int(float64(dist/e.width) * e.params.Coverage)
+}
+
+// indexes of the neighbours of the cell at index n
+func (e *Engine) neighbours(n int) []int {
+	return neighbours(n, e.width, e.height, e.params.Topology)
+}
+
+// Bus the engine publishes a TickCompleted at the end of every tick and an
+// ExchangeHappened after every exchange on
+func (e *Engine) Bus() *Bus { return e.bus }
+
+// Params the engine was created with
+func (e *Engine) Params() Params { return e.params }
+
+// Width of the grid
+func (e *Engine) Width() int { return e.width }
+
+// Height of the grid
+func (e *Engine) Height() int { return e.height }
+
+// Tick the simulation is at
+func (e *Engine) Tick() int { return e.tick }
+
+// Seed of the random numbers of the run
+func (e *Engine) Seed() int64 { return e.seed }
+
+// Cultures of all the cells, row by row
+func (e *Engine) Cultures() []int { return append([]int(nil), e.cultures...) }
+
+// SetCultures replaces the cultures of all the cells, row by row, such as
+// with the final grid of another run to carry on from with other
+// parameters. The tick and metrics are kept. The cultures must fill the grid
+// and fit the features and traits of the engine.
+func (e *Engine) SetCultures(cultures []int) error {
+	if len(cultures) != len(e.cultures) {
+		return fmt.Errorf("%d cultures do not fill a grid of %dx%d cells", len(cultures), e.width, e.height)
+	}
+	previous := e.Cultures()
+	copy(e.cultures, cultures)
+	e.checked = nil
+	err := e.Check()
+	e.checked = nil
+	if err != nil {
+		copy(e.cultures, previous)
+		return err
+	}
+	return nil
+}
+
+// Metrics recorded so far
+func (e *Engine) Metrics() []Series {
+	metrics := make([]Series, len(e.metrics))
+	for i, s := range e.metrics {
+		metrics[i] = Series{s.Name, append([]float64(nil), s.Values...)}
+	}
+	return metrics
+}
+
+// Stats are the metrics of the latest tick
+func (e *Engine) Stats() Stats { return e.stats }
@@ -0,0 +1,50 @@
+package culsim
+
+import (
+	"context"
+	"testing"
+)
+
+// with the differing-only option every exchange of the distance rule changes
+// the trait it copies, while without it some copy a trait the cells share
+func TestDifferingOnly(t *testing.T) {
+	for _, differing := range []bool{false, true} {
+		opts := []Option{WithGrid(24, 24), WithSeed(1)}
+		if differing {
+			opts = append(opts, WithDifferingOnly())
+		}
+		e, err := New(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the grid as the exchanges leave it
+		grid := e.Cultures()
+		var exchanges, unchanged int
+		e.Bus().Subscribe(func(m Message) {
+			if x, ok := m.(ExchangeHappened); ok {
+				exchanges++
+				if FeatureTrait(grid[x.Dst], x.Feature) == FeatureTrait(x.Culture, x.Feature) {
+					unchanged++
+				}
+				grid[x.Dst] = x.Culture
+			}
+		})
+		for e.Tick() < 30 {
+			e.Step(context.Background())
+		}
+		for n, c := range e.Cultures() {
+			if grid[n] != c {
+				t.Fatalf("cell %d has culture %06X, the exchanges left it %06X", n, c, grid[n])
+			}
+		}
+		if exchanges == 0 {
+			t.Fatal("no exchanges")
+		}
+		if differing && unchanged > 0 {
+			t.Errorf("%d of %d exchanges copied a trait the cells shared", unchanged, exchanges)
+		}
+		if !differing && unchanged == 0 {
+			t.Errorf("none of %d exchanges copied a trait the cells shared", exchanges)
+		}
+	}
+}
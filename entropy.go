@@ -0,0 +1,43 @@
+package culsim
+
+import (
+	"fmt"
+	"math"
+)
+
+// Shannon entropy in bits of the traits of feature f over the populated
+// cells, 0 when every culture has the same trait and log2 of the traits when
+// each trait is as common as any other
+func (e *Engine) featureEntropy(f int) float64 {
+	var counts [16]float64
+	var populated float64
+	for _, c := range e.cultures {
+		if c == Empty {
+			continue
+		}
+		counts[extract(c, uint(f))]++
+		populated++
+	}
+	var h float64
+	for _, n := range counts {
+		if n > 0 {
+			p := n / populated
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// metrics of the trait entropy of each feature, entropy-0 to entropy-5, with
+// WithEntropy
+func (e *Engine) entropyMetrics() []Metric {
+	if !e.params.Entropy {
+		return nil
+	}
+	var metrics []Metric
+	for i := 0; i < e.features; i++ {
+		i := i
+		metrics = append(metrics, NewMetric(fmt.Sprintf("entropy-%d", i), func(e *Engine) float64 { return e.featureEntropy(i) }))
+	}
+	return metrics
+}
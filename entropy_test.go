@@ -0,0 +1,45 @@
+package culsim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// the entropy of a feature is 0 when every populated cell has the same
+// trait, log2 of the traits when the traits are equally common, and leaves
+// out empty cells
+func TestFeatureEntropy(t *testing.T) {
+	e := newEngine(Params{Width: 5, Height: 2, Features: 3, Traits: 4})
+	copy(e.cultures, []int{0x000, 0x001, 0x002, 0x003, Empty, 0x010, 0x011, 0x012, 0x013, Empty})
+	for f, want := range []float64{2, 1, 0} {
+		if got := e.featureEntropy(f); math.Abs(got-want) > 1e-12 {
+			t.Errorf("entropy of feature %d %v, want %v", f, got, want)
+		}
+	}
+}
+
+// the entropy metrics are those of the grid of every tick: near log2 of the
+// traits for a random grid, 0 for a converged one
+func TestEntropyMetrics(t *testing.T) {
+	for _, initial := range []string{"random", "converged"} {
+		e, err := New(WithGrid(40, 40), WithFeatures(3, 8), WithInitial(initial), WithEntropy(), WithSeed(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for e.Tick() < 10 {
+			e.Step(context.Background())
+			for f := 0; f < 3; f++ {
+				values := series(t, e, fmt.Sprintf("entropy-%d", f))
+				got, want := values[len(values)-1], e.featureEntropy(f)
+				if got != want {
+					t.Fatalf("%s: entropy of feature %d %v at tick %d, %v on the grid", initial, f, got, e.Tick(), want)
+				}
+				if initial == "converged" && got != 0 || initial == "random" && e.Tick() == 1 && got < 2.9 {
+					t.Errorf("%s: entropy of feature %d %v at tick %d", initial, f, got, e.Tick())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package culsim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Event is a scenario event applied to the grid from its tick until its end
+// tick (inclusive). One-off events happen only at their tick, ongoing events
+// with an end of 0 last to the end of the simulation.
+//
+// A "policy" event is ongoing and makes each populated cell in the region
+// adopt the given trait in the given feature with probability rate every tick.
+//
+// A "disaster" event is one-off and, depending on its mode, either empties
+// ("empty") or gives random cultures to ("randomize") every cell in the region.
+//
+// A "lock" event is ongoing and keeps the trait of the given feature of every
+// cell in the region from changing by any mechanism of the model, while the
+// other features keep evolving. Only interventions change it.
+type Event struct {
+	Type    string  `json:"type"`
+	Tick    int     `json:"tick"`
+	End     int     `json:"end,omitempty"`
+	Region  Region  `json:"region"`
+	Feature int     `json:"feature"`
+	Trait   int     `json:"trait"`
+	Rate    float64 `json:"rate"`
+	Mode    string  `json:"mode,omitempty"`
+}
+
+func (e *Event) validate() error {
+	if err := e.Region.validate(); err != nil {
+		return err
+	}
+	if e.End != 0 && e.End < e.Tick {
+		return errors.New("end is before the start tick")
+	}
+	switch e.Type {
+	case "policy":
+		if e.Rate < 0 || e.Rate > 1 {
+			return errors.New("rate must be between 0 and 1")
+		}
+		return validTrait(e.Feature, e.Trait)
+	case "disaster":
+		if e.Mode != "empty" && e.Mode != "randomize" {
+			return errors.New(`mode must be "empty" or "randomize"`)
+		}
+		return nil
+	case "lock":
+		return validTrait(e.Feature, 0)
+	}
+	return fmt.Errorf("unknown event type %q", e.Type)
+}
+
+// check the trait a policy event spreads or the feature a lock event keeps
+// fits the features and traits of a run
+func (e *Event) fit(features, traits int) error {
+	switch e.Type {
+	case "policy":
+		return fitsTrait(e.Feature, e.Trait, features, traits)
+	case "lock":
+		return fitsTrait(e.Feature, 0, features, traits)
+	}
+	return nil
+}
+
+// Active tells if the event happens in tick t
+func (e *Event) Active(t int) bool {
+	if e.Type == "disaster" {
+		return t == e.Tick
+	}
+	return t >= e.Tick && (e.End == 0 || t <= e.End)
+}
+
+// names of the data series the event reports, i is its index in the config
+func (e *Event) series(i int) []string {
+	switch e.Type {
+	case "policy":
+		return []string{fmt.Sprintf("adoption-%d", i)}
+	case "disaster":
+		return []string{fmt.Sprintf("populated-%d", i), fmt.Sprintf("cultures-%d", i)}
+	}
+	return nil
+}
+
+// apply all the scenario events that happen in the current tick
+func (e *Engine) applyEvents() {
+	for i := range e.cfg.Events {
+		ev := &e.cfg.Events[i]
+		if !ev.Active(e.tick) {
+			continue
+		}
+		switch ev.Type {
+		case "policy":
+			e.applyPolicy(ev)
+		case "disaster":
+			e.applyDisaster(ev)
+		}
+	}
+}
+
+// make populated cells in the region adopt the policy trait
+func (e *Engine) applyPolicy(ev *Event) {
+	for n, c := range e.cultures {
+		if !ev.Region.contains(n, e.width) || c == Empty || e.frozen(n) || e.locked(n, ev.Feature) {
+			continue
+		}
+		if e.rng.Float64() < ev.Rate {
+			culture := replace(c, ev.Trait, uint(ev.Feature))
+			if !e.cfg.Constraints.forbids(culture) {
+				e.cultures[n] = culture
+			}
+		}
+	}
+}
+
+// empty or randomize every cell in the region, but for the locked traits
+func (e *Engine) applyDisaster(ev *Event) {
+	for n := range e.cultures {
+		if !ev.Region.contains(n, e.width) || e.frozen(n) {
+			continue
+		}
+		culture := Empty
+		if ev.Mode == "randomize" {
+			culture = e.randomCulture()
+		}
+		if culture, ok := e.keepLocked(n, culture); ok {
+			e.cultures[n] = culture
+		}
+	}
+}
+
+// check if a lock event keeps the trait of the feature of the cell at n from
+// changing in the current tick
+func (e *Engine) locked(n, feature int) bool {
+	for i := range e.cfg.Events {
+		ev := &e.cfg.Events[i]
+		if ev.Type == "lock" && ev.Feature == feature && ev.Active(e.tick) && ev.Region.contains(n, e.width) {
+			return true
+		}
+	}
+	return false
+}
+
+// the culture the cell at n takes instead of a culture replacing its own, to
+// keep the traits of the features locked at the cell, and whether it can
+// take it. A locked cell is neither emptied nor populated, as that would
+// take or give the traits of its locked features.
+func (e *Engine) keepLocked(n, culture int) (int, bool) {
+	for i := range e.cfg.Events {
+		ev := &e.cfg.Events[i]
+		if ev.Type != "lock" || !ev.Active(e.tick) || !ev.Region.contains(n, e.width) {
+			continue
+		}
+		if e.cultures[n] == Empty || culture == Empty {
+			return e.cultures[n], false
+		}
+		culture = replace(culture, extract(e.cultures[n], uint(ev.Feature)), uint(ev.Feature))
+	}
+	return culture, true
+}
+
+// fraction of populated cells in the region carrying the event's trait
+func (e *Engine) adoption(ev *Event) float64 {
+	var count, adopted int
+	for n, c := range e.cultures {
+		if !ev.Region.contains(n, e.width) || c == Empty {
+			continue
+		}
+		count++
+		if extract(c, uint(ev.Feature)) == ev.Trait {
+			adopted++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return float64(adopted) / float64(count)
+}
+
+// fraction of cells in the region that are populated, and how many distinct
+// cultures populate them
+func (e *Engine) recolonization(ev *Event) (float64, int) {
+	var count, populated int
+	cultures := make(map[int]bool)
+	for n, c := range e.cultures {
+		if !ev.Region.contains(n, e.width) {
+			continue
+		}
+		count++
+		if c != Empty {
+			populated++
+			cultures[c] = true
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return float64(populated) / float64(count), len(cultures)
+}
+
+// measurements for the event's data series in the current tick, fractions
+// are rounded to 4 decimal places
+func (e *Engine) measure(ev *Event) []float64 {
+	switch ev.Type {
+	case "policy":
+		return []float64{round4(e.adoption(ev))}
+	case "disaster":
+		populated, cultures := e.recolonization(ev)
+		return []float64{round4(populated), float64(cultures)}
+	}
+	return nil
+}
+
+// metrics of the data series of all scenario events
+func (e *Engine) eventMetrics() []Metric {
+	var metrics []Metric
+	for i := range e.cfg.Events {
+		ev := &e.cfg.Events[i]
+		for j, name := range ev.series(i) {
+			j := j
+			metrics = append(metrics, NewMetric(name, func(e *Engine) float64 { return e.measure(ev)[j] }))
+		}
+	}
+	return metrics
+}
+
+// colonize empty neighbours of the cell at n with its whole culture
+func (e *Engine) colonize(n int) {
+	for _, neighbour := range e.neighbours(n) {
+		if e.cultures[neighbour] != Empty || e.frozen(neighbour) {
+			continue
+		}
+		if _, ok := e.keepLocked(neighbour, e.cultures[n]); ok && e.rng.Float64() < e.params.Colonization {
+			e.cultures[neighbour] = e.cultures[n]
+		}
+	}
+}
+
+// round a fraction to 4 decimal places
+func round4(f float64) float64 {
+	return math.Round(f*1e4) / 1e4
+}
@@ -0,0 +1,62 @@
+package culsim
+
+import (
+	"context"
+	"testing"
+)
+
+// no mechanism changes the trait of a locked feature, or empties or
+// populates a locked cell, while the cells outside the lock keep changing
+func TestLocksHoldAgainstEveryMechanism(t *testing.T) {
+	lock := Event{Type: "lock", Region: Region{W: 6, H: 12}, Feature: 0}
+	grid := Region{W: 12, H: 12}
+	institution := 0x000123
+	runs := []struct {
+		name string
+		cfg  Config
+		opts []Option
+	}{
+		{name: "exchanges and noise", opts: []Option{WithNoise(0.05)}},
+		{name: "policy", cfg: Config{Events: []Event{{Type: "policy", Region: grid, Feature: 0, Trait: 3, Rate: 1}}}},
+		{name: "disaster empty", cfg: Config{Events: []Event{{Type: "disaster", Tick: 5, Region: grid, Mode: "empty"}}}},
+		{name: "disaster randomize", cfg: Config{Events: []Event{{Type: "disaster", Tick: 5, Region: grid, Mode: "randomize"}}}},
+		{name: "decay", cfg: Config{Decay: &Decay{Baseline: 0x000000, Rate: 1}}},
+		{name: "institutions", cfg: Config{Institutions: &Institutions{Strength: 1,
+			Regions: []Institution{{Name: "all", Region: grid, Culture: &institution}}}}},
+		{name: "minority", cfg: Config{Minority: &Minority{Culture: 0x000123, Region: Region{W: 2, H: 2}, Media: 1}}},
+		{name: "conquest", opts: []Option{WithConquest(1, 1)}},
+		{name: "colonization", opts: []Option{WithColonization(1)}},
+	}
+	for _, run := range runs {
+		t.Run(run.name, func(t *testing.T) {
+			cfg := run.cfg
+			cfg.Events = append([]Event{lock}, cfg.Events...)
+			opts := append([]Option{WithGrid(12, 12), WithFeatures(3, 4), WithCoverage(0.7), WithSeed(1),
+				WithDuration(20), WithConfig(&cfg)}, run.opts...)
+			e, err := New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := e.Cultures()
+			if _, err = e.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			var changed int
+			for n, c := range e.Cultures() {
+				switch {
+				case !lock.Region.contains(n, 12):
+					if c != before[n] {
+						changed++
+					}
+				case (c == Empty) != (before[n] == Empty):
+					t.Fatalf("locked cell %d went from %06X to %06X", n, before[n], c)
+				case c != Empty && extract(c, 0) != extract(before[n], 0):
+					t.Fatalf("locked feature of cell %d went from %06X to %06X", n, before[n], c)
+				}
+			}
+			if changed == 0 {
+				t.Error("no cell outside the lock changed")
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package culsim
+
+import "math"
+
+// probability that 2 cultures at a total trait distance d, sharing shared
+// features, exchange a trait under the distance rule. Every function but
+// overlap takes the distance as a fraction of the features times the
+// traits.
+func (e *Engine) exchangeProbability(d, shared int) float64 {
+	x := float64(d) / float64(e.features*e.traits)
+	switch e.params.Exchange {
+	case Overlap:
+		return float64(shared) / float64(e.features)
+	case Sigmoid:
+		return 1 / (1 + math.Exp(e.params.Steepness*(x-e.params.Threshold)))
+	case Threshold:
+		if x <= e.params.Threshold {
+			return 1
+		}
+		return 0
+	}
+	return 1 - x
+}
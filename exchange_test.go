@@ -0,0 +1,75 @@
+package culsim
+
+import (
+	"math"
+	"testing"
+)
+
+// the probability of an exchange between cultures at each distance under
+// each exchange function, with 6 features of 16 traits, so a distance of 96
+// is the largest
+func TestExchangeProbability(t *testing.T) {
+	sigmoid := func(x, steepness, threshold float64) float64 {
+		return 1 / (1 + math.Exp(steepness*(x-threshold)))
+	}
+	cases := []struct {
+		name      string
+		exchange  Exchange
+		steepness float64
+		threshold float64
+		d, shared int
+		want      float64
+	}{
+		{name: "linear at distance 0", exchange: Linear, d: 0, shared: 6, want: 1},
+		{name: "linear halfway", exchange: Linear, d: 48, shared: 0, want: 0.5},
+		{name: "linear at the largest distance", exchange: Linear, d: 96, shared: 0, want: 0},
+		{name: "overlap sharing every feature", exchange: Overlap, d: 0, shared: 6, want: 1},
+		{name: "overlap sharing half the features", exchange: Overlap, d: 40, shared: 3, want: 0.5},
+		{name: "overlap sharing none", exchange: Overlap, d: 7, shared: 0, want: 0},
+		{name: "overlap ignores the distance", exchange: Overlap, d: 90, shared: 5, want: 5.0 / 6},
+		{name: "threshold at distance 0", exchange: Threshold, threshold: 0.25, d: 0, shared: 6, want: 1},
+		{name: "threshold below it", exchange: Threshold, threshold: 0.25, d: 23, shared: 2, want: 1},
+		{name: "threshold at it", exchange: Threshold, threshold: 0.25, d: 24, shared: 2, want: 1},
+		{name: "threshold above it", exchange: Threshold, threshold: 0.25, d: 25, shared: 2, want: 0},
+		{name: "threshold of 0", exchange: Threshold, threshold: 0, d: 1, shared: 5, want: 0},
+		{name: "sigmoid at the threshold", exchange: Sigmoid, steepness: 20, threshold: 0.25, d: 24, want: 0.5},
+		{name: "sigmoid at distance 0", exchange: Sigmoid, steepness: 20, threshold: 0.25, d: 0, shared: 6,
+			want: sigmoid(0, 20, 0.25)},
+		{name: "sigmoid below the threshold", exchange: Sigmoid, steepness: 20, threshold: 0.25, d: 12,
+			want: sigmoid(0.125, 20, 0.25)},
+		{name: "sigmoid above the threshold", exchange: Sigmoid, steepness: 20, threshold: 0.25, d: 36,
+			want: 1 - sigmoid(0.125, 20, 0.25)},
+		{name: "flat sigmoid", exchange: Sigmoid, steepness: 0, threshold: 0.25, d: 80, want: 0.5},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newEngine(Params{Width: 1, Height: 1, Features: Features, Traits: 0x10, Exchange: c.exchange,
+				Steepness: c.steepness, Threshold: c.threshold})
+			if got := e.exchangeProbability(c.d, c.shared); math.Abs(got-c.want) > 1e-12 {
+				t.Errorf("probability %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// the sigmoid falls from near 1 to near 0 around its threshold, steeper the
+// steeper it is
+func TestSigmoidFalls(t *testing.T) {
+	for _, steepness := range []float64{5, 20, 80} {
+		e := newEngine(Params{Width: 1, Height: 1, Features: Features, Traits: 0x10, Exchange: Sigmoid,
+			Steepness: steepness, Threshold: 0.5})
+		previous := 1.0
+		for d := 0; d <= 96; d++ {
+			p := e.exchangeProbability(d, 0)
+			if p > previous || p < 0 || p > 1 {
+				t.Fatalf("steepness %v: probability %v at distance %d after %v", steepness, p, d, previous)
+			}
+			previous = p
+		}
+	}
+	gentle := newEngine(Params{Width: 1, Height: 1, Features: Features, Traits: 0x10, Exchange: Sigmoid, Steepness: 5, Threshold: 0.5})
+	steep := newEngine(Params{Width: 1, Height: 1, Features: Features, Traits: 0x10, Exchange: Sigmoid, Steepness: 80, Threshold: 0.5})
+	if gentle.exchangeProbability(24, 0) >= steep.exchangeProbability(24, 0) {
+		t.Error("a steeper sigmoid is no closer to 1 below its threshold")
+	}
+}
@@ -0,0 +1,121 @@
+package culsim
+
+import (
+	"fmt"
+	"sync"
+)
+
+// counts of the interactions of a tick by how they turned out, and of the
+// exchanges by feature. Every attempt is rejected by the probability of the
+// rule, blocked because the 2 cultures have no distance between them,
+// blocked by anything else that keeps a trait from being copied, or copies
+// a trait. The tiles of a parallel tick count on their own and add their
+// counts when done.
+type exchangeStats struct {
+	sync.Mutex
+	attempts  int   // pairs of populated neighbours that tried to interact
+	rejected  int   // attempts the probability of the rule turned down
+	identical int   // attempts of cultures with no distance between them
+	blocked   int   // attempts blocked by feature rates, constraints or minorities
+	copied    []int // exchanges that copied the trait of each feature
+}
+
+// start counting the interactions of a new tick
+func (s *exchangeStats) reset() {
+	if s == nil {
+		return
+	}
+	s.attempts, s.rejected, s.identical, s.blocked = 0, 0, 0, 0
+	for i := range s.copied {
+		s.copied[i] = 0
+	}
+}
+
+// count an attempt to interact
+func (s *exchangeStats) attempt() {
+	if s != nil {
+		s.attempts++
+	}
+}
+
+// count an attempt turned down by the probability of the rule
+func (s *exchangeStats) reject() {
+	if s != nil {
+		s.rejected++
+	}
+}
+
+// count an attempt of cultures with no distance between them
+func (s *exchangeStats) same() {
+	if s != nil {
+		s.identical++
+	}
+}
+
+// count an attempt blocked after the rule chose a feature
+func (s *exchangeStats) block() {
+	if s != nil {
+		s.blocked++
+	}
+}
+
+// count an exchange of the trait of feature i
+func (s *exchangeStats) copy(i int) {
+	if s != nil {
+		s.copied[i]++
+	}
+}
+
+// exchanges of all features
+func (s *exchangeStats) copies() int {
+	var n int
+	for _, c := range s.copied {
+		n += c
+	}
+	return n
+}
+
+// add the counts of a tile of a parallel tick
+func (s *exchangeStats) add(t *exchangeStats) {
+	s.Lock()
+	defer s.Unlock()
+	s.attempts += t.attempts
+	s.rejected += t.rejected
+	s.identical += t.identical
+	s.blocked += t.blocked
+	for i, c := range t.copied {
+		s.copied[i] += c
+	}
+}
+
+// metrics of the interactions of every tick: with feature stats the
+// exchanges by feature, copied-0 to copied-5, and with interaction stats the
+// attempts, rejected, blocked and copies, both with blocked-identical
+func (e *Engine) exchangeMetrics() []Metric {
+	if !e.params.FeatureStats && !e.params.InteractionStats && !e.params.Performance {
+		return nil
+	}
+	e.tally = &exchangeStats{copied: make([]int, e.features)}
+	count := func(name string, n func(s *exchangeStats) int) Metric {
+		return NewMetric(name, func(e *Engine) float64 { return float64(n(e.tally)) })
+	}
+	var metrics []Metric
+	if e.params.FeatureStats {
+		for i := 0; i < e.features; i++ {
+			i := i
+			metrics = append(metrics, count(fmt.Sprintf("copied-%d", i), func(s *exchangeStats) int { return s.copied[i] }))
+		}
+	}
+	if e.params.InteractionStats {
+		metrics = append(metrics,
+			count("attempts", func(s *exchangeStats) int { return s.attempts }),
+			count("rejected", func(s *exchangeStats) int { return s.rejected }),
+			count("blocked", func(s *exchangeStats) int { return s.blocked }),
+			count("copies", (*exchangeStats).copies),
+		)
+	}
+	if e.params.FeatureStats || e.params.InteractionStats {
+		metrics = append(metrics, count("blocked-identical", func(s *exchangeStats) int { return s.identical }))
+	}
+	return metrics
+}
@@ -0,0 +1,63 @@
+package culsim
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// every interaction of a tick is counted once, as rejected, blocked by
+// identical cultures, blocked otherwise or copied, and the exchanges by
+// feature are those that happened, in serial and parallel ticks
+func TestExchangeStats(t *testing.T) {
+	runs := map[string][]Option{
+		"serial":    {WithGrid(24, 24), WithCoverage(0.9)},
+		"parallel":  {WithGrid(40, 40), WithInteractions(1000), WithWorkers(4)},
+		"axelrod":   {WithGrid(24, 24), WithRule(Axelrod), WithFeatures(3, 3)},
+		"converged": {WithGrid(24, 24), WithInitial("converged")},
+	}
+	for name, opts := range runs {
+		t.Run(name, func(t *testing.T) {
+			opts = append([]Option{WithSeed(1), WithFeatureStats(), WithInteractionStats()}, opts...)
+			e, err := New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var exchanges [][Features]int
+			e.Bus().Subscribe(func(m Message) {
+				if x, ok := m.(ExchangeHappened); ok {
+					for len(exchanges) < x.Tick {
+						exchanges = append(exchanges, [Features]int{})
+					}
+					exchanges[x.Tick-1][x.Feature]++
+				}
+			})
+			for e.Tick() < 30 {
+				e.Step(context.Background())
+				for len(exchanges) < e.Tick() {
+					exchanges = append(exchanges, [Features]int{})
+				}
+			}
+			value := func(name string, tick int) int { return int(series(t, e, name)[tick]) }
+			for tick := 0; tick < 30; tick++ {
+				attempts, copies := value("attempts", tick), value("copies", tick)
+				if outcomes := value("rejected", tick) + value("blocked-identical", tick) + value("blocked", tick) + copies; outcomes != attempts {
+					t.Fatalf("tick %d: %d outcomes of %d attempts", tick+1, outcomes, attempts)
+				}
+				var copied int
+				for f := 0; f < e.Params().Features; f++ {
+					if got, want := value(fmt.Sprintf("copied-%d", f), tick), exchanges[tick][f]; got != want {
+						t.Fatalf("tick %d: %d exchanges of feature %d counted, %d happened", tick+1, got, f, want)
+					}
+					copied += exchanges[tick][f]
+				}
+				if copies != copied {
+					t.Fatalf("tick %d: %d copies counted, %d happened", tick+1, copies, copied)
+				}
+				if name == "converged" && value("blocked-identical", tick) != attempts {
+					t.Fatalf("tick %d: %d of %d attempts of identical cultures counted", tick+1, value("blocked-identical", tick), attempts)
+				}
+			}
+		})
+	}
+}
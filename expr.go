@@ -0,0 +1,330 @@
+package culsim
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// compiled expression of a scripted rule, evaluated on the variables of an
+// interaction
+type expr func(v *ruleVars) float64
+
+// functions that can be called in expressions, by name and number of
+// arguments
+var exprFuncs = map[string]struct {
+	args int
+	f    func(a []float64) float64
+}{
+	"abs":  {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt": {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":  {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":  {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"min":  {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":  {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"pow":  {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+}
+
+// operators of 2 characters
+var exprOperators = map[string]bool{"<=": true, ">=": true, "==": true, "!=": true, "&&": true, "||": true}
+
+// compile an expression of numbers, variables, the arithmetic operators
+// + - * / %, the comparisons < <= > >= == !=, the logical operators && || !,
+// the conditional c ? a : b, parentheses and the functions in exprFuncs.
+// Comparisons and logical operators give 1 for true and 0 for false.
+func compileExpr(src string, vars map[string]func(v *ruleVars) float64) (expr, error) {
+	p := &exprParser{vars: vars}
+	if err := p.tokenize(src); err != nil {
+		return nil, err
+	}
+	e, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+// recursive descent parser of expressions, from the lowest precedence to
+// the highest
+type exprParser struct {
+	tokens []string
+	pos    int
+	vars   map[string]func(v *ruleVars) float64
+}
+
+// split the source into numbers, names, operators and parentheses
+func (p *exprParser) tokenize(src string) error {
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, src[i:j])
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			p.tokens = append(p.tokens, src[i:j])
+			i = j
+		case i+1 < len(src) && exprOperators[src[i:i+2]]:
+			p.tokens = append(p.tokens, src[i:i+2])
+			i += 2
+		case strings.ContainsRune("+-*/%<>!?:(),", c):
+			p.tokens = append(p.tokens, string(c))
+			i++
+		default:
+			return fmt.Errorf("unexpected %q", c)
+		}
+	}
+	if len(p.tokens) == 0 {
+		return fmt.Errorf("empty expression")
+	}
+	return nil
+}
+
+// the next token if it is one of the given ones
+func (p *exprParser) accept(tokens ...string) (string, bool) {
+	if p.pos < len(p.tokens) {
+		for _, t := range tokens {
+			if p.tokens[p.pos] == t {
+				p.pos++
+				return t, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(token string) error {
+	if _, ok := p.accept(token); !ok {
+		if p.pos < len(p.tokens) {
+			return fmt.Errorf("expected %q, got %q", token, p.tokens[p.pos])
+		}
+		return fmt.Errorf("expected %q at the end", token)
+	}
+	return nil
+}
+
+// c ? a : b
+func (p *exprParser) conditional() (expr, error) {
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+	a, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	b, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	return func(v *ruleVars) float64 {
+		if cond(v) != 0 {
+			return a(v)
+		}
+		return b(v)
+	}, nil
+}
+
+func (p *exprParser) or() (expr, error) {
+	left, err := p.and()
+	for err == nil {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+		var right expr
+		if right, err = p.and(); err == nil {
+			l := left
+			left = func(v *ruleVars) float64 { return truth(l(v) != 0 || right(v) != 0) }
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) and() (expr, error) {
+	left, err := p.comparison()
+	for err == nil {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+		var right expr
+		if right, err = p.comparison(); err == nil {
+			l := left
+			left = func(v *ruleVars) float64 { return truth(l(v) != 0 && right(v) != 0) }
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) comparison() (expr, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("<", "<=", ">", ">=", "==", "!=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	compare := map[string]func(a, b float64) bool{
+		"<":  func(a, b float64) bool { return a < b },
+		"<=": func(a, b float64) bool { return a <= b },
+		">":  func(a, b float64) bool { return a > b },
+		">=": func(a, b float64) bool { return a >= b },
+		"==": func(a, b float64) bool { return a == b },
+		"!=": func(a, b float64) bool { return a != b },
+	}[op]
+	return func(v *ruleVars) float64 { return truth(compare(left(v), right(v))) }, nil
+}
+
+func (p *exprParser) sum() (expr, error) {
+	left, err := p.product()
+	for err == nil {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		var right expr
+		if right, err = p.product(); err == nil {
+			l := left
+			if op == "+" {
+				left = func(v *ruleVars) float64 { return l(v) + right(v) }
+			} else {
+				left = func(v *ruleVars) float64 { return l(v) - right(v) }
+			}
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) product() (expr, error) {
+	left, err := p.unary()
+	for err == nil {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		var right expr
+		if right, err = p.unary(); err == nil {
+			l := left
+			switch op {
+			case "*":
+				left = func(v *ruleVars) float64 { return l(v) * right(v) }
+			case "/":
+				left = func(v *ruleVars) float64 { return l(v) / right(v) }
+			default:
+				left = func(v *ruleVars) float64 { return math.Mod(l(v), right(v)) }
+			}
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) unary() (expr, error) {
+	op, ok := p.accept("-", "!")
+	if !ok {
+		return p.primary()
+	}
+	operand, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if op == "-" {
+		return func(v *ruleVars) float64 { return -operand(v) }, nil
+	}
+	return func(v *ruleVars) float64 { return truth(operand(v) == 0) }, nil
+}
+
+// a number, a variable, a function call or an expression in parentheses
+func (p *exprParser) primary() (expr, error) {
+	if p.pos == len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch {
+	case t == "(":
+		e, err := p.conditional()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case unicode.IsDigit(rune(t[0])) || t[0] == '.':
+		n, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t)
+		}
+		return func(*ruleVars) float64 { return n }, nil
+	case unicode.IsLetter(rune(t[0])) || t[0] == '_':
+		if _, ok := p.accept("("); ok {
+			return p.call(t)
+		}
+		if v, ok := p.vars[t]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("unknown variable %q", t)
+	}
+	return nil, fmt.Errorf("unexpected %q", t)
+}
+
+// a call of the function name, after its opening parenthesis
+func (p *exprParser) call(name string) (expr, error) {
+	fn, ok := exprFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	var args []expr
+	for {
+		arg, err := p.conditional()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if _, ok := p.accept(","); !ok {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if len(args) != fn.args {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name, fn.args, len(args))
+	}
+	return func(v *ruleVars) float64 {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg(v)
+		}
+		return fn.f(values)
+	}, nil
+}
+
+// 1 for true and 0 for false
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
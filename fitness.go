@@ -0,0 +1,50 @@
+package culsim
+
+import (
+	"errors"
+	"math"
+)
+
+// FitnessConfig biases cultural exchanges towards fitter cultures. A culture's
+// fitness is 1 plus the score of every trait it carries, and the fitter of two
+// cultures is more likely to be the one whose trait is copied. Strength 0 is
+// neutral drift, larger values make selection stronger.
+type FitnessConfig struct {
+	Strength float64      `json:"strength"`
+	Scores   []TraitScore `json:"scores"`
+}
+
+// TraitScore is the fitness contributed by a trait value in one feature
+type TraitScore struct {
+	Feature int     `json:"feature"`
+	Trait   int     `json:"trait"`
+	Score   float64 `json:"score"`
+}
+
+func (f *FitnessConfig) validate() error {
+	if f.Strength < 0 {
+		return errors.New("strength cannot be negative")
+	}
+	for _, s := range f.Scores {
+		if err := validTrait(s.Feature, s.Trait); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fitness of a culture, never less than 0
+func (f *FitnessConfig) fitness(culture int) float64 {
+	fit := 1.0
+	for _, s := range f.Scores {
+		if extract(culture, uint(s.Feature)) == s.Trait {
+			fit += s.Score
+		}
+	}
+	return math.Max(fit, 0)
+}
+
+// copying weight of a culture from its fitness and the selection strength
+func (f *FitnessConfig) weight(culture int) float64 {
+	return math.Pow(f.fitness(culture), f.Strength)
+}
@@ -0,0 +1,143 @@
+package culsim
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the golden runs with their current results")
+
+// short seeded runs whose metrics and final grid are kept in
+// testdata/golden/<name>.json, covering the rules, topologies and mechanisms
+// of the model. Changes that aren't meant to change the model, such as
+// refactoring or parallelizing it, must leave their results as they are.
+var goldenRuns = []struct {
+	name   string
+	config string // config file in testdata, if any
+	invade int    // width of the block of invaders seeded, if any
+	opts   []Option
+}{
+	{name: "distance", opts: []Option{WithGrid(24, 24)}},
+	{name: "axelrod-torus", opts: []Option{WithGrid(24, 24), WithRule(Axelrod), WithTopology(Torus), WithFeatures(5, 10)}},
+	{name: "von-neumann", opts: []Option{WithGrid(30, 20), WithTopology(VonNeumann), WithInteractions(300)}},
+	{name: "mechanisms", opts: []Option{WithGrid(24, 24), WithCoverage(0.8), WithNoise(0.02), WithColonization(0.1),
+		WithConquest(0.05, 3), WithReputation(1), WithRefractory(2)}},
+	{name: "invasion", invade: 6, opts: []Option{WithGrid(24, 24), WithInitial("converged"), WithInvader(1, 1)}},
+	{name: "parallel", opts: []Option{WithGrid(40, 40), WithInteractions(1000), WithWorkers(4)}},
+	{name: "feature-stats", opts: []Option{WithGrid(40, 40), WithInteractions(1000), WithWorkers(4), WithFeatureStats()}},
+	{name: "entropy", opts: []Option{WithGrid(24, 24), WithRule(Axelrod), WithTopology(Torus), WithFeatures(5, 10), WithEntropy()}},
+	{name: "without-replacement", opts: []Option{WithGrid(24, 24), WithCoverage(0.8), WithInteractions(600), WithoutReplacement()}},
+	{name: "most-similar", opts: []Option{WithGrid(24, 24), WithRule(Axelrod), WithPartner(MostSimilar)}},
+	{name: "weighted", opts: []Option{WithGrid(24, 24), WithCoverage(0.8), WithPartner(SimilarityWeighted)}},
+	{name: "config", config: "config.json", opts: []Option{WithCoverage(0.9), WithInitial("zipf")}},
+	{name: "scripted-rule", config: "rule.json", opts: []Option{WithGrid(24, 24)}},
+	{name: "lock", config: "lock.json", opts: []Option{WithGrid(24, 24), WithNoise(0.01), WithEntropy()}},
+	{name: "blobs", config: "blobs.json", opts: []Option{WithGrid(24, 24), WithCoverage(0.9), WithInitial("blobs")}},
+	{name: "overlap", opts: []Option{WithExchange(Overlap, 0, 0)}},
+	{name: "sigmoid", opts: []Option{WithExchange(Sigmoid, 20, 0.3)}},
+	{name: "threshold", opts: []Option{WithExchange(Threshold, 0, 0.25)}},
+	{name: "copies", opts: []Option{WithCopies(3)}},
+	{name: "assimilation", opts: []Option{WithRule(Axelrod), WithCopies(Features)}},
+	{name: "differing-only", opts: []Option{WithDifferingOnly()}},
+	{name: "adaptive", opts: []Option{WithGrid(24, 24), WithRule(Axelrod), WithFeatures(5, 10), WithAdaptiveInteractions()}},
+	{name: "window", config: "window.json", opts: []Option{WithGrid(24, 24), WithCoverage(0.9), WithColonization(0.1)}},
+	{name: "marginals", config: "marginals.json", opts: []Option{WithInitial("marginals")}},
+}
+
+// results of a golden run
+type goldenResult struct {
+	Metrics  []Series `json:"metrics"`
+	Cultures []int    `json:"cultures"`
+}
+
+// run the golden runs and compare their results with the golden files, or
+// rewrite them with -update
+func TestGoldenRuns(t *testing.T) {
+	for _, run := range goldenRuns {
+		t.Run(run.name, func(t *testing.T) {
+			opts := append([]Option{WithSeed(1), WithDuration(50)}, run.opts...)
+			if run.config != "" {
+				cfg, err := LoadConfig(filepath.Join("testdata", run.config))
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts = append(opts, WithConfig(cfg))
+			}
+			e, err := New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if run.invade > 0 {
+				if err = e.Invade(run.invade); err != nil {
+					t.Fatal(err)
+				}
+			}
+			metrics, err := e.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := goldenResult{metrics, e.Cultures()}
+			path := filepath.Join("testdata", "golden", run.name+".json")
+			if *update {
+				data, err := json.MarshalIndent(got, "", " ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v, create it with go test -run TestGoldenRuns -update", err)
+			}
+			var want goldenResult
+			if err = json.Unmarshal(data, &want); err != nil {
+				t.Fatalf("invalid golden file %s: %v", path, err)
+			}
+			compareGolden(t, got, want)
+		})
+	}
+}
+
+// report where the results of a golden run first differ from its golden file
+func compareGolden(t *testing.T, got, want goldenResult) {
+	t.Helper()
+	if len(got.Metrics) != len(want.Metrics) {
+		t.Errorf("recorded %d metrics, want %d", len(got.Metrics), len(want.Metrics))
+	}
+	for i := 0; i < len(got.Metrics) && i < len(want.Metrics); i++ {
+		g, w := got.Metrics[i], want.Metrics[i]
+		if g.Name != w.Name {
+			t.Errorf("metric %d is %s, want %s", i, g.Name, w.Name)
+			continue
+		}
+		if len(g.Values) != len(w.Values) {
+			t.Errorf("%s has %d values, want %d", g.Name, len(g.Values), len(w.Values))
+			continue
+		}
+		for tick := range g.Values {
+			if g.Values[tick] != w.Values[tick] {
+				t.Errorf("%s first differs at tick %d: %v, want %v", g.Name, tick+1, g.Values[tick], w.Values[tick])
+				break
+			}
+		}
+	}
+	if len(got.Cultures) != len(want.Cultures) {
+		t.Fatalf("final grid has %d cells, want %d", len(got.Cultures), len(want.Cultures))
+	}
+	var cells int
+	for n := range got.Cultures {
+		if got.Cultures[n] != want.Cultures[n] {
+			cells++
+		}
+	}
+	if cells > 0 {
+		t.Errorf("%d cells of the final grid differ", cells)
+	}
+}
@@ -0,0 +1,536 @@
+package culsim
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// InitConfig sets the parameters of the initial culture distributions
+// chosen with Params.Initial
+//
+//   - zipf draws from K seed cultures with probability proportional to
+//     1/rank^exponent
+//   - clusters draws one of K seed cultures uniformly, then gives each feature
+//     a random trait with probability noise
+//   - file draws from the cultures and probabilities in a CSV file of
+//     culture,probability rows
+//   - blobs places exactly K distinct seed cultures in contiguous blobs of
+//     size populated cells each, at least one for each culture and no 2 of a
+//     culture touching, with blobs of an equal share of the populated cells
+//     if size is 0
+//   - marginals draws the trait of each feature independently with the
+//     probabilities of the traits in marginals, such as the frequencies of
+//     answers in a survey, and uniformly for features without any. With a
+//     concentration, the probabilities of the run are drawn from a
+//     Dirichlet distribution around them, closer the larger it is.
+//
+// Seed cultures are random unless given in seeds.
+type InitConfig struct {
+	Seeds    []int   `json:"seeds"`
+	K        int     `json:"k"`
+	Exponent float64 `json:"exponent"`
+	Noise    float64 `json:"noise"`
+	File     string  `json:"file"`
+	Size     int     `json:"size"`
+
+	Marginals     [][]float64 `json:"marginals"`
+	Concentration float64     `json:"concentration"`
+}
+
+func (ic *InitConfig) validate() error {
+	for _, s := range ic.Seeds {
+		if s < 0 || s >= Empty {
+			return fmt.Errorf("seed culture %X out of range", s)
+		}
+	}
+	if ic.K < 0 || ic.Exponent < 0 || ic.Size < 0 {
+		return errors.New("k, exponent and size cannot be negative")
+	}
+	if ic.Noise < 0 || ic.Noise > 1 {
+		return errors.New("noise must be between 0 and 1")
+	}
+	if len(ic.Marginals) > Features {
+		return fmt.Errorf("marginals of %d features, cultures have at most %d", len(ic.Marginals), Features)
+	}
+	for f, m := range ic.Marginals {
+		if len(m) > 0x10 {
+			return fmt.Errorf("marginals of %d traits in feature %d, features have at most 16", len(m), f)
+		}
+		var total float64
+		for _, p := range m {
+			if p < 0 {
+				return fmt.Errorf("negative marginal probability in feature %d", f)
+			}
+			total += p
+		}
+		if len(m) > 0 && total == 0 {
+			return fmt.Errorf("marginals of feature %d are all 0", f)
+		}
+	}
+	if ic.Concentration < 0 {
+		return errors.New("concentration cannot be negative")
+	}
+	return nil
+}
+
+// create the function that draws the culture of each populated cell when the
+// grid is initialised
+func (e *Engine) sampler(kind string, ic *InitConfig) (func() int, error) {
+	if ic == nil {
+		ic = &InitConfig{}
+	}
+	switch kind {
+	case "random":
+		return e.randomCulture, nil
+	case "converged":
+		// every populated cell shares one culture
+		culture := e.randomCulture()
+		return func() int { return culture }, nil
+	case "zipf":
+		seeds := e.seeds(ic)
+		weights := make([]float64, len(seeds))
+		for i := range seeds {
+			weights[i] = 1 / math.Pow(float64(i+1), ic.Exponent)
+		}
+		return e.weightedSampler(seeds, weights), nil
+	case "clusters":
+		seeds := e.seeds(ic)
+		return func() int {
+			culture := seeds[e.rng.Intn(len(seeds))]
+			for i := 0; i < e.features; i++ {
+				if e.rng.Float64() < ic.Noise {
+					if rp := replace(culture, e.rng.Intn(e.traits), uint(i)); !e.cfg.Constraints.forbids(rp) {
+						culture = rp
+					}
+				}
+			}
+			return culture
+		}, nil
+	case "blobs":
+		// populated cells are given their cultures by placeBlobs once the
+		// grid is populated
+		return func() int { return 0 }, nil
+	case "marginals":
+		return e.marginalSampler(ic)
+	case "file":
+		cultures, weights, err := readDistribution(ic.File)
+		if err != nil {
+			return nil, err
+		}
+		return e.weightedSampler(cultures, weights), nil
+	}
+	return nil, fmt.Errorf("unknown initial distribution %q", kind)
+}
+
+// the configured seed cultures, or K random ones
+func (e *Engine) seeds(ic *InitConfig) []int {
+	if len(ic.Seeds) > 0 {
+		return ic.Seeds
+	}
+	k := ic.K
+	if k == 0 {
+		k = 1
+	}
+	seeds := make([]int, k)
+	for i := range seeds {
+		seeds[i] = e.randomCulture()
+	}
+	return seeds
+}
+
+// check there are as many distinct cultures as the blobs of the initial
+// distribution need
+func (p *Params) validateBlobs() error {
+	if p.Initial != "blobs" || p.Config == nil || p.Config.Init == nil {
+		return nil
+	}
+	ic := p.Config.Init
+	distinct := make(map[int]bool)
+	for _, s := range ic.Seeds {
+		if distinct[s] {
+			return fmt.Errorf("seed culture %06X given twice, blobs need distinct cultures", s)
+		}
+		distinct[s] = true
+	}
+	cultures := 1
+	for f := 0; f < p.Features; f++ {
+		cultures *= p.Traits
+	}
+	if p.Features == Features && p.Traits == 0x10 {
+		// the culture with every trait set is Empty
+		cultures--
+	}
+	if len(ic.Seeds) == 0 && ic.K > cultures {
+		return fmt.Errorf("k of %d distinct cultures, more than the %d cultures of %d features of %d traits",
+			ic.K, cultures, p.Features, p.Traits)
+	}
+	return nil
+}
+
+// give the populated cells of the grid exactly K distinct seed cultures in
+// contiguous blobs of about the configured size. The blobs grow at the same
+// time from random populated cells, one for every size populated cells and
+// at least K, each taking a random populated neighbour in turn until the
+// grid is taken. Populated cells no blob can reach start blobs of their own.
+// Every seed culture is given to at least one blob, and no 2 blobs of a
+// culture touch and merge.
+func (e *Engine) placeBlobs(ic *InitConfig) error {
+	if ic == nil {
+		ic = &InitConfig{}
+	}
+	seeds := ic.Seeds
+	if len(seeds) == 0 {
+		k := max(ic.K, 1)
+		distinct := make(map[int]bool)
+		for len(seeds) < k {
+			if c := e.randomCulture(); !distinct[c] {
+				distinct[c] = true
+				seeds = append(seeds, c)
+			}
+		}
+	}
+	var populated []int
+	for n, c := range e.cultures {
+		if c != Empty {
+			populated = append(populated, n)
+		}
+	}
+	if len(populated) == 0 {
+		return nil
+	}
+	if len(populated) < len(seeds) {
+		return fmt.Errorf("%d populated cells cannot hold blobs of %d cultures", len(populated), len(seeds))
+	}
+	size := ic.Size
+	if size == 0 {
+		size = max(len(populated)/len(seeds), 1)
+	}
+	e.rng.Shuffle(len(populated), func(i, j int) { populated[i], populated[j] = populated[j], populated[i] })
+
+	// blob of every cell, -1 for none yet, and the cells each blob can take
+	owner := make([]int, len(e.cultures))
+	for n := range owner {
+		owner[n] = -1
+	}
+	var frontiers [][]int
+	take := func(b, n int) {
+		owner[n] = b
+		for _, neighbour := range e.neighbours(n) {
+			if e.cultures[neighbour] != Empty && owner[neighbour] < 0 {
+				frontiers[b] = append(frontiers[b], neighbour)
+			}
+		}
+	}
+	start := func(n int) {
+		frontiers = append(frontiers, nil)
+		take(len(frontiers)-1, n)
+	}
+	blobs := max(int(math.Round(float64(len(populated))/float64(size))), len(seeds))
+	for _, n := range populated[:min(blobs, len(populated))] {
+		start(n)
+	}
+	next := 0
+	for {
+		growing := false
+		for b := range frontiers {
+			for len(frontiers[b]) > 0 {
+				j := e.rng.Intn(len(frontiers[b]))
+				n := frontiers[b][j]
+				frontiers[b][j] = frontiers[b][len(frontiers[b])-1]
+				frontiers[b] = frontiers[b][:len(frontiers[b])-1]
+				if owner[n] < 0 {
+					take(b, n)
+					growing = true
+					break
+				}
+			}
+		}
+		if growing {
+			continue
+		}
+		// the blobs can't reach the rest of the populated cells
+		for next < len(populated) && owner[populated[next]] >= 0 {
+			next++
+		}
+		if next == len(populated) {
+			break
+		}
+		start(populated[next])
+	}
+
+	// the blobs each blob touches
+	touching := make([]map[int]bool, len(frontiers))
+	for b := range touching {
+		touching[b] = make(map[int]bool)
+	}
+	for _, n := range populated {
+		for _, neighbour := range e.neighbours(n) {
+			if b := owner[neighbour]; b >= 0 && b != owner[n] {
+				touching[owner[n]][b] = true
+			}
+		}
+	}
+	cultures := e.colourBlobs(touching, len(seeds))
+	for _, n := range populated {
+		e.cultures[n] = seeds[cultures[owner[n]]]
+	}
+	return nil
+}
+
+// steps the search for the cultures of the blobs takes before giving up,
+// besides one for every blob
+const colouringSteps = 10000
+
+// give every blob one of k cultures, by their index, so that every culture
+// has a blob and no 2 touching blobs share one. The search takes the blob
+// with the fewest cultures left first, trying them in random order and
+// backtracking when a blob has none left. If it finds no way in
+// colouringSteps steps, the blobs are given cultures in the same order
+// without backtracking, and a blob with none left takes the culture the
+// fewest of the blobs it touches have, merging with them into one blob.
+func (e *Engine) colourBlobs(touching []map[int]bool, k int) []int {
+	cultures := make([]int, len(touching))
+	order := make([][]int, len(touching))
+	for b := range order {
+		order[b] = e.rng.Perm(k)
+	}
+	// blobs of each culture, blobs of each culture each blob touches, and
+	// the cultures left for each blob
+	var blobs []int
+	var counts [][]int
+	var left []int
+	reset := func() {
+		blobs, counts, left = make([]int, k), make([][]int, len(touching)), make([]int, len(touching))
+		for b := range cultures {
+			cultures[b], counts[b], left[b] = -1, make([]int, k), k
+		}
+	}
+	colour := func(b, c int) {
+		cultures[b] = c
+		blobs[c]++
+		for t := range touching[b] {
+			if counts[t][c]++; counts[t][c] == 1 {
+				left[t]--
+			}
+		}
+	}
+	uncolour := func(b int) {
+		c := cultures[b]
+		cultures[b] = -1
+		blobs[c]--
+		for t := range touching[b] {
+			if counts[t][c]--; counts[t][c] == 0 {
+				left[t]++
+			}
+		}
+	}
+	// the uncoloured blob with the fewest cultures left, and those cultures,
+	// the ones without a blob first
+	next := func() (int, []int) {
+		best := -1
+		for b, c := range cultures {
+			if c < 0 && (best == -1 || left[b] < left[best]) {
+				best = b
+			}
+		}
+		var free []int
+		for _, c := range order[best] {
+			if counts[best][c] == 0 {
+				free = append(free, c)
+			}
+		}
+		sort.SliceStable(free, func(i, j int) bool { return blobs[free[i]] == 0 && blobs[free[j]] > 0 })
+		return best, free
+	}
+	steps := 0
+	var search func(uncoloured int) bool
+	search = func(uncoloured int) bool {
+		if uncoloured == 0 {
+			for _, n := range blobs {
+				if n == 0 {
+					return false
+				}
+			}
+			return true
+		}
+		if steps++; steps > len(touching)+colouringSteps {
+			return false
+		}
+		b, free := next()
+		for _, c := range free {
+			colour(b, c)
+			if search(uncoloured - 1) {
+				return true
+			}
+			uncolour(b)
+		}
+		return false
+	}
+	reset()
+	if search(len(touching)) {
+		return cultures
+	}
+	reset()
+	for range cultures {
+		b, free := next()
+		if len(free) > 0 {
+			colour(b, free[0])
+			continue
+		}
+		culture := order[b][0]
+		for _, c := range order[b] {
+			if counts[b][c] < counts[b][culture] {
+				culture = c
+			}
+		}
+		colour(b, culture)
+	}
+	return cultures
+}
+
+// draw cultures whose traits follow the marginals of each feature, drawn
+// from a Dirichlet distribution around them with a concentration
+func (e *Engine) marginalSampler(ic *InitConfig) (func() int, error) {
+	samplers := make([]func() int, e.features)
+	for f := range samplers {
+		weights := make([]float64, e.traits)
+		if f < len(ic.Marginals) && len(ic.Marginals[f]) > 0 {
+			for t, p := range ic.Marginals[f] {
+				if t >= e.traits && p > 0 {
+					return nil, fmt.Errorf("marginals of feature %d give trait %d, traits are 0 to %d", f, t, e.traits-1)
+				}
+				if t < e.traits {
+					weights[t] = p
+				}
+			}
+		} else {
+			for t := range weights {
+				weights[t] = 1
+			}
+		}
+		if ic.Concentration > 0 {
+			weights = e.dirichlet(weights, ic.Concentration)
+		}
+		traits := make([]int, e.traits)
+		for t := range traits {
+			traits[t] = t
+		}
+		samplers[f] = e.weightedSampler(traits, weights)
+	}
+	draw := func() int {
+		var culture int
+		for f, sample := range samplers {
+			culture = replace(culture, sample(), uint(f))
+		}
+		return culture
+	}
+	return func() int {
+		culture := draw()
+		for e.cfg.Constraints.forbids(culture) {
+			culture = draw()
+		}
+		return culture
+	}, nil
+}
+
+// probabilities drawn from a Dirichlet distribution with the mean of the
+// weights and the given concentration, the sum of its parameters. Traits of
+// weight 0 keep a probability of 0.
+func (e *Engine) dirichlet(weights []float64, concentration float64) []float64 {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	drawn := make([]float64, len(weights))
+	for i, w := range weights {
+		if w > 0 {
+			drawn[i] = e.gamma(concentration * w / total)
+		}
+	}
+	return drawn
+}
+
+// a gamma distributed number of the given shape and scale 1, by Marsaglia
+// and Tsang's method
+func (e *Engine) gamma(shape float64) float64 {
+	if shape < 1 {
+		// boost the shape above 1 and scale back down
+		return e.gamma(shape+1) * math.Pow(e.rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := e.rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := e.rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// draw one of the cultures with probability proportional to its weight
+func (e *Engine) weightedSampler(cultures []int, weights []float64) func() int {
+	cumulative := make([]float64, len(weights))
+	var total float64
+	for i, w := range weights {
+		total += w
+		cumulative[i] = total
+	}
+	return func() int {
+		p := e.rng.Float64() * total
+		i := sort.SearchFloat64s(cumulative, p)
+		if i == len(cumulative) {
+			i--
+		}
+		return cultures[i]
+	}
+}
+
+// read cultures and their probabilities from a CSV file of
+// culture,probability rows, cultures can be decimal or 0x prefixed hex
+func readDistribution(path string) ([]int, []float64, error) {
+	if path == "" {
+		return nil, nil, errors.New("no distribution file given")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	var cultures []int
+	var weights []float64
+	var total float64
+	for i, row := range rows {
+		culture, err := strconv.ParseInt(row[0], 0, 64)
+		if err != nil || culture < 0 || culture >= Empty {
+			return nil, nil, fmt.Errorf("%s line %d: invalid culture %q", path, i+1, row[0])
+		}
+		p, err := strconv.ParseFloat(row[1], 64)
+		if err != nil || p < 0 {
+			return nil, nil, fmt.Errorf("%s line %d: invalid probability %q", path, i+1, row[1])
+		}
+		cultures = append(cultures, int(culture))
+		weights = append(weights, p)
+		total += p
+	}
+	if total == 0 {
+		return nil, nil, fmt.Errorf("%s has no cultures with a positive probability", path)
+	}
+	return cultures, weights, nil
+}
@@ -0,0 +1,112 @@
+package culsim
+
+import "testing"
+
+// blobs of K cultures, at least one for each, with no 2 blobs of a culture
+// touching, so every blob is a domain of its own, unless there are too few
+// cultures to go round and some blobs merge
+func TestBlobs(t *testing.T) {
+	runs := []struct {
+		name  string
+		k     int
+		size  int
+		blobs int // domains the blobs make, 0 if some merge
+		opts  []Option
+	}{
+		{name: "equal shares", k: 5, blobs: 5},
+		{name: "size", k: 6, size: 25, blobs: 16},
+		{name: "larger than the grid", k: 6, size: 1000, blobs: 6},
+		{name: "von neumann", k: 4, size: 25, blobs: 16, opts: []Option{WithTopology(VonNeumann)}},
+		{name: "torus", k: 5, size: 40, blobs: 10, opts: []Option{WithTopology(Torus)}},
+		{name: "too few cultures to go round", k: 2, size: 10},
+	}
+	for _, run := range runs {
+		t.Run(run.name, func(t *testing.T) {
+			cfg := &Config{Init: &InitConfig{K: run.k, Size: run.size}}
+			opts := append([]Option{WithGrid(20, 20), WithInitial("blobs"), WithSeed(1), WithConfig(cfg)}, run.opts...)
+			e, err := New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, sizes := Domains(e.Cultures(), e.Width(), e.Params().Topology)
+			if run.blobs > 0 && len(sizes) != run.blobs {
+				t.Errorf("%d domains, want %d blobs", len(sizes), run.blobs)
+			}
+			if len(sizes) < run.k || len(sizes) > max(400/max(run.size, 1), run.k) {
+				t.Errorf("%d domains, want between %d cultures and the blobs placed", len(sizes), run.k)
+			}
+			cultures := make(map[int]bool)
+			for _, c := range e.Cultures() {
+				cultures[c] = true
+			}
+			if len(cultures) != run.k {
+				t.Errorf("%d cultures, want %d", len(cultures), run.k)
+			}
+		})
+	}
+}
+
+// blobs that can't all have distinct cultures are refused
+func TestBlobsRefused(t *testing.T) {
+	runs := map[string]struct {
+		init *InitConfig
+		opts []Option
+	}{
+		"more cultures than there are": {init: &InitConfig{K: 5}, opts: []Option{WithFeatures(2, 2)}},
+		"seeds given twice":            {init: &InitConfig{Seeds: []int{0x000012, 0x000012}}},
+		"more cultures than cells":     {init: &InitConfig{K: 5}, opts: []Option{WithGrid(2, 2)}},
+	}
+	for name, run := range runs {
+		t.Run(name, func(t *testing.T) {
+			opts := append([]Option{WithGrid(12, 12), WithInitial("blobs"), WithConfig(&Config{Init: run.init})}, run.opts...)
+			if _, err := New(opts...); err == nil {
+				t.Error("placed the blobs")
+			}
+		})
+	}
+}
+
+// traits drawn from the marginals of each feature follow them, uniformly for
+// features without any, and from marginals of their own in every run with a
+// concentration
+func TestMarginals(t *testing.T) {
+	frequencies := func(seed int64, concentration float64) [][]float64 {
+		cfg := &Config{Init: &InitConfig{Marginals: [][]float64{{0, 0, 1}, {0.7, 0.3}}, Concentration: concentration}}
+		e, err := New(WithGrid(100, 100), WithCoverage(1), WithFeatures(6, 4), WithInitial("marginals"), WithSeed(seed),
+			WithConfig(cfg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		freqs := make([][]float64, 6)
+		for f := range freqs {
+			freqs[f] = make([]float64, 4)
+			for _, c := range e.Cultures() {
+				freqs[f][FeatureTrait(c, f)]++
+			}
+			for trait := range freqs[f] {
+				freqs[f][trait] /= 10000
+			}
+		}
+		return freqs
+	}
+	want := [][]float64{{0, 0, 1, 0}, {0.7, 0.3, 0, 0}}
+	for f := 2; f < 6; f++ {
+		want = append(want, []float64{0.25, 0.25, 0.25, 0.25})
+	}
+	got := frequencies(1, 0)
+	for f := range want {
+		for trait, p := range want[f] {
+			if q := got[f][trait]; q < p-0.02 || q > p+0.02 {
+				t.Errorf("trait %d of feature %d in %.3f of the cells, want %.3f", trait, f, q, p)
+			}
+		}
+	}
+
+	a, b := frequencies(1, 5), frequencies(2, 5)
+	if a[0][2] != 1 || b[0][2] != 1 || a[1][2]+a[1][3] != 0 || b[1][2]+b[1][3] != 0 {
+		t.Error("perturbed marginals drew traits of probability 0")
+	}
+	if d := a[1][0] - b[1][0]; d > -0.02 && d < 0.02 {
+		t.Errorf("trait 0 of feature 1 in %.3f and %.3f of the cells of runs of perturbed marginals", a[1][0], b[1][0])
+	}
+}
@@ -0,0 +1,139 @@
+package culsim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Institutions are group-level culture attractors. Each institution has a
+// culture of its own and governs the cells in its region. Every tick each
+// member is influenced by the institution with probability strength, copying
+// one differing trait with probability equal to their cultural similarity,
+// and the institution moves one feature to its members' most common trait
+// with probability adaptation.
+type Institutions struct {
+	Strength   float64       `json:"strength"`
+	Adaptation float64       `json:"adaptation"`
+	Regions    []Institution `json:"regions"`
+}
+
+// Institution is the culture of a region's institution, a random culture is
+// used if none is given
+type Institution struct {
+	Name    string `json:"name"`
+	Region  Region `json:"region"`
+	Culture *int   `json:"culture"`
+}
+
+func (in *Institutions) validate() error {
+	if in.Strength < 0 || in.Strength > 1 || in.Adaptation < 0 || in.Adaptation > 1 {
+		return errors.New("strength and adaptation must be between 0 and 1")
+	}
+	for _, r := range in.Regions {
+		if err := r.Region.validate(); err != nil {
+			return fmt.Errorf("institution %s: %w", r.Name, err)
+		}
+		if r.Culture != nil && (*r.Culture < 0 || *r.Culture >= Empty) {
+			return fmt.Errorf("institution %s: culture out of range", r.Name)
+		}
+	}
+	return nil
+}
+
+// set up the institutions' cultures and data series
+func (e *Engine) initInstitutions() {
+	if e.cfg.Institutions == nil {
+		return
+	}
+	for _, r := range e.cfg.Institutions.Regions {
+		culture := e.randomCulture()
+		if r.Culture != nil {
+			culture = *r.Culture
+		}
+		e.institutions = append(e.institutions, culture)
+	}
+}
+
+// let institutions and their members influence each other for one tick
+func (e *Engine) governInstitutions() {
+	if e.cfg.Institutions == nil {
+		return
+	}
+	for i, r := range e.cfg.Institutions.Regions {
+		for n, culture := range e.cultures {
+			if !r.Region.contains(n, e.width) || culture == Empty || e.frozen(n) ||
+				e.rng.Float64() >= e.cfg.Institutions.Strength {
+				continue
+			}
+			shared := e.sharedFeatures(culture, e.institutions[i])
+			if shared == e.features || e.rng.Float64() >= float64(shared)/float64(e.features) {
+				continue
+			}
+			f := uint(e.randomDifferingFeature(culture, e.institutions[i]))
+			rp := replace(culture, extract(e.institutions[i], f), f)
+			if !e.cfg.Constraints.forbids(rp) && !e.locked(n, int(f)) {
+				e.cultures[n] = rp
+			}
+		}
+		if e.rng.Float64() < e.cfg.Institutions.Adaptation {
+			f := uint(e.rng.Intn(e.features))
+			if trait, ok := e.commonTrait(r.Region, f); ok {
+				e.institutions[i] = replace(e.institutions[i], trait, f)
+			}
+		}
+	}
+}
+
+// most common trait of a feature among the populated cells in a region
+func (e *Engine) commonTrait(region Region, f uint) (int, bool) {
+	var counts [0x10]int
+	var found bool
+	for n, c := range e.cultures {
+		if region.contains(n, e.width) && c != Empty {
+			counts[extract(c, f)]++
+			found = true
+		}
+	}
+	best := 0
+	for t, c := range counts {
+		if c > counts[best] {
+			best = t
+		}
+	}
+	return best, found
+}
+
+// metrics of the mean similarity between each institution and its members,
+// rounded to 4 decimal places
+func (e *Engine) institutionMetrics() []Metric {
+	if e.cfg.Institutions == nil {
+		return nil
+	}
+	var metrics []Metric
+	for i := range e.cfg.Institutions.Regions {
+		i := i
+		metrics = append(metrics, NewMetric(fmt.Sprintf("institution-%d", i), func(e *Engine) float64 {
+			return round4(e.agreement(i))
+		}))
+	}
+	return metrics
+}
+
+// mean similarity between the institution i and its members
+func (e *Engine) agreement(i int) float64 {
+	r := e.cfg.Institutions.Regions[i]
+	var members, shared int
+	for n, c := range e.cultures {
+		if r.Region.contains(n, e.width) && c != Empty {
+			members++
+			shared += e.sharedFeatures(c, e.institutions[i])
+		}
+	}
+	if members == 0 {
+		return 0
+	}
+	return float64(shared) / float64(members*e.features)
+}
+
+// Institutions are the current cultures of the institutions, in config order
+func (e *Engine) Institutions() []int { return append([]int(nil), e.institutions...) }
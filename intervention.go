@@ -0,0 +1,142 @@
+package culsim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Intervention changes a running simulation between ticks, applied after its
+// tick. A "culture" intervention sets the culture of the cell at index Cell,
+// row by row, to Culture, which may be Empty. A "noise" intervention sets
+// the noise rate to Rate from the next tick on, replacing any noise schedule
+// of the config. An "event" intervention adds the scenario Event, which
+// starts after the tick, such as a policy event over the whole grid for a
+// media campaign. Events added by interventions report no data series.
+type Intervention struct {
+	Tick    int     `json:"tick"`
+	Type    string  `json:"type"`
+	Cell    int     `json:"cell,omitempty"`
+	Culture int     `json:"culture,omitempty"`
+	Rate    float64 `json:"rate,omitempty"`
+	Event   *Event  `json:"event,omitempty"`
+}
+
+// Intervene applies an intervention to the simulation now, after the current
+// tick, records it in Interventions and publishes an InterventionApplied on
+// the bus. Putting the recorded interventions in the interventions of the
+// config of a run with the same seed and parameters redoes them at their
+// ticks, so the run can be reproduced.
+func (e *Engine) Intervene(iv Intervention) error {
+	iv.Tick = e.tick
+	if err := e.intervene(iv); err != nil {
+		return err
+	}
+	e.interventions = append(e.interventions, iv)
+	e.bus.Publish(InterventionApplied{iv})
+	return nil
+}
+
+// check an intervention can be applied to a grid of width by height cells
+// with cultures of the given features and traits after its tick
+func (iv *Intervention) validate(width, height, features, traits int) error {
+	switch iv.Type {
+	case "culture":
+		if iv.Cell < 0 || iv.Cell >= width*height {
+			return fmt.Errorf("cell %d is outside the grid of %dx%d cells", iv.Cell, width, height)
+		}
+		return fits(iv.Culture, features, traits)
+	case "noise":
+		if iv.Rate < 0 || iv.Rate > 1 {
+			return errors.New("noise rate must be between 0 and 1")
+		}
+		return nil
+	case "event":
+		if iv.Event == nil {
+			return errors.New("event intervention without an event")
+		}
+		if err := iv.Event.validate(); err != nil {
+			return fmt.Errorf("event: %w", err)
+		}
+		if err := iv.Event.fit(features, traits); err != nil {
+			return fmt.Errorf("event: %w", err)
+		}
+		if iv.Event.Tick <= iv.Tick {
+			return fmt.Errorf("event at tick %d, which has already run at tick %d", iv.Event.Tick, iv.Tick)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown intervention type %q", iv.Type)
+}
+
+// apply an intervention
+func (e *Engine) intervene(iv Intervention) error {
+	if err := iv.validate(e.width, e.height, e.features, e.traits); err != nil {
+		return err
+	}
+	switch iv.Type {
+	case "culture":
+		e.cultures[iv.Cell] = iv.Culture
+		// the check carries on from the changed cell
+		if e.checked != nil {
+			e.checked.cultures[iv.Cell] = iv.Culture
+		}
+	case "noise", "event":
+		e.reconfigure(iv)
+	}
+	return nil
+}
+
+// change the config as a noise or event intervention does. The config is
+// copied before it is changed, as it may be shared with other engines, and
+// the parameters are left as the run started with them.
+func (e *Engine) reconfigure(iv Intervention) {
+	cfg := *e.cfg
+	switch iv.Type {
+	case "noise":
+		// a schedule of the one rate replaces any other
+		cfg.Noise = &NoiseSchedule{Schedule: "step", Points: []NoisePoint{{Tick: iv.Tick, Rate: iv.Rate}}}
+	case "event":
+		cfg.Events = append(cfg.Events[:len(cfg.Events):len(cfg.Events)], *iv.Event)
+	}
+	e.cfg = &cfg
+}
+
+// check a culture is empty or has traits below the given traits in the
+// given features and none in the others
+func fits(c, features, traits int) error {
+	if c == Empty {
+		return nil
+	}
+	if c < 0 || c > Empty {
+		return fmt.Errorf("culture %X is not 24 bits", c)
+	}
+	for f := 0; f < Features; f++ {
+		if t := extract(c, uint(f)); f < features && t >= traits || f >= features && t != 0 {
+			return fmt.Errorf("culture %06X does not fit %d features of %d traits", c, features, traits)
+		}
+	}
+	return nil
+}
+
+// check a trait is below the given traits in one of the given features
+func fitsTrait(feature, trait, features, traits int) error {
+	if feature >= features || trait >= traits {
+		return fmt.Errorf("trait %d of feature %d does not fit %d features of %d traits", trait, feature, features, traits)
+	}
+	return nil
+}
+
+// apply the interventions of the config after their tick
+func (e *Engine) replayInterventions() {
+	for _, iv := range e.cfg.Interventions {
+		if iv.Tick == e.tick {
+			// validated with the parameters of the engine
+			_ = e.Intervene(iv)
+		}
+	}
+}
+
+// Interventions applied to the simulation so far, in order
+func (e *Engine) Interventions() []Intervention {
+	return append([]Intervention(nil), e.interventions...)
+}
@@ -0,0 +1,33 @@
+package culsim
+
+import (
+	"reflect"
+	"testing"
+)
+
+// interventions that set cultures or spread traits that don't fit the
+// features and traits of a run are refused, leaving the grid as it was
+func TestInterveneRefusesWhatDoesntFit(t *testing.T) {
+	region := Region{W: 4, H: 4}
+	interventions := map[string]Intervention{
+		"culture":      {Type: "culture", Culture: 0x000005},
+		"policy":       {Type: "event", Event: &Event{Type: "policy", Tick: 1, Region: region, Feature: 5, Trait: 12, Rate: 1}},
+		"policy trait": {Type: "event", Event: &Event{Type: "policy", Tick: 1, Region: region, Feature: 1, Trait: 4, Rate: 1}},
+		"lock":         {Type: "event", Event: &Event{Type: "lock", Tick: 1, Region: region, Feature: 3}},
+	}
+	for name, iv := range interventions {
+		t.Run(name, func(t *testing.T) {
+			e, err := New(WithGrid(8, 8), WithFeatures(3, 4), WithSeed(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			grid := e.Cultures()
+			if err = e.Intervene(iv); err == nil {
+				t.Fatal("applied an intervention of other features and traits")
+			}
+			if len(e.Interventions()) > 0 || !reflect.DeepEqual(e.Cultures(), grid) {
+				t.Error("refused intervention changed the run")
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package culsim
+
+import "fmt"
+
+// Invade fills a block of size by size cells in the centre of the grid with
+// a new random culture, the invader, for an invasion experiment
+func (e *Engine) Invade(size int) error {
+	if size > e.width || size > e.height {
+		return fmt.Errorf("block of %d cells is larger than the grid", size)
+	}
+	e.invader = e.randomCulture()
+	block := Region{X: (e.width - size) / 2, Y: (e.height - size) / 2, W: size, H: size}
+	for n := range e.cultures {
+		if block.contains(n, e.width) {
+			e.cultures[n] = e.invader
+		}
+	}
+	return nil
+}
+
+// Invaders is the number of cells with the invader culture
+func (e *Engine) Invaders() int {
+	var count int
+	for _, c := range e.cultures {
+		if c == e.invader {
+			count++
+		}
+	}
+	return count
+}
+
+// copying weight of the cell at n, invader cells are copied more often if
+// they have prestige
+func (e *Engine) prestige(n int) float64 {
+	if e.invader != Empty && e.cultures[n] == e.invader {
+		return 1 + e.params.InvaderPrestige
+	}
+	return 1
+}
+
+// randomly choose the cell that initiates an interaction, invader cells are
+// chosen more often if they are more active
+func (e *Engine) initiator() int {
+	r := e.randomCell()
+	if e.invader == Empty || e.params.InvaderActivity == 0 {
+		return r
+	}
+	// accept other cells less often than invaders, giving invaders
+	// 1+activity times the chance of being chosen
+	for e.cultures[r] != e.invader && e.rng.Float64() >= 1/(1+e.params.InvaderActivity) {
+		r = e.randomCell()
+	}
+	return r
+}
@@ -0,0 +1,80 @@
+package culsim
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Labels name the features of cultures and their traits, in feature order,
+// so that cultures can be shown as, say, en/catholic/rural instead of their
+// hex traits. Traits without a label are shown by number.
+type Labels []FeatureLabels
+
+// FeatureLabels are the name of a feature and the labels of its traits, in
+// trait order
+type FeatureLabels struct {
+	Name   string   `json:"name"`
+	Traits []string `json:"traits"`
+}
+
+func (l Labels) validate() error {
+	if len(l) > len(masks) {
+		return fmt.Errorf("labels of %d features, cultures have at most %d", len(l), len(masks))
+	}
+	names := make(map[string]bool)
+	for f, fl := range l {
+		if fl.Name != "" && names[fl.Name] {
+			return fmt.Errorf("feature name %q used twice", fl.Name)
+		}
+		names[fl.Name] = true
+		if len(fl.Traits) > 0x10 {
+			return fmt.Errorf("labels of %d traits in feature %d, features have at most 16", len(fl.Traits), f)
+		}
+		traits := make(map[string]bool)
+		for _, t := range fl.Traits {
+			if strings.Contains(t, "/") {
+				return errors.New(`trait labels cannot contain "/"`)
+			}
+			if t != "" && traits[t] {
+				return fmt.Errorf("trait label %q used twice in feature %d", t, f)
+			}
+			traits[t] = true
+		}
+	}
+	return nil
+}
+
+// Feature is the name of a feature, empty if it has none
+func (l Labels) Feature(f int) string {
+	if f < len(l) {
+		return l[f].Name
+	}
+	return ""
+}
+
+// Trait is the label of a trait of a feature, its number if it has none
+func (l Labels) Trait(f, t int) string {
+	if f < len(l) && t < len(l[f].Traits) && l[f].Traits[t] != "" {
+		return l[f].Traits[t]
+	}
+	return strconv.Itoa(t)
+}
+
+// Culture shows a culture as the labels of its traits from the first feature
+// on, separated by slashes, and empty cells as empty, or as its hex traits
+// if there are no labels
+func (l Labels) Culture(c int) string {
+	if len(l) == 0 {
+		return fmt.Sprintf("%06X", c)
+	}
+	if c == Empty {
+		return "empty"
+	}
+	traits := make([]string, Features)
+	for f := range traits {
+		traits[f] = l.Trait(f, FeatureTrait(c, f))
+	}
+	return strings.Join(traits, "/")
+}
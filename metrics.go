@@ -0,0 +1,117 @@
+package culsim
+
+import "fmt"
+
+// Metric is measured at the end of every tick and recorded as a series of
+// the engine's metrics
+type Metric interface {
+	// Name of the metric's series, unique among the engine's metrics
+	Name() string
+	// Update measures the metric on the engine at the end of a tick
+	Update(e *Engine)
+	// Value is the latest measurement
+	Value() float64
+}
+
+// NewMetric makes a metric measured by a function of the engine
+func NewMetric(name string, measure func(e *Engine) float64) Metric {
+	return &funcMetric{name: name, measure: measure}
+}
+
+// metric measured by a function
+type funcMetric struct {
+	name    string
+	measure func(e *Engine) float64
+	value   float64
+}
+
+func (m *funcMetric) Name() string     { return m.name }
+func (m *funcMetric) Update(e *Engine) { m.value = m.measure(e) }
+func (m *funcMetric) Value() float64   { return m.value }
+
+// Register adds a metric to the engine, recorded from the end of the next
+// tick on
+func (e *Engine) Register(m Metric) error {
+	for _, s := range e.metrics {
+		if s.Name == m.Name() {
+			return fmt.Errorf("metric %q is already recorded", m.Name())
+		}
+	}
+	e.registry = append(e.registry, m)
+	e.metrics = append(e.metrics, Series{Name: m.Name()})
+	return nil
+}
+
+// register the built-in metrics of the model and its mechanisms, except the
+// ones left out with WithoutMetrics
+func (e *Engine) registerBuiltins() error {
+	builtins := []Metric{
+		// the average distance between all features, the number of exchanges
+		// per row and the number of unique cultures
+		NewMetric("distance", func(e *Engine) float64 { return float64(e.featureDistAvg()) }),
+		NewMetric("change", func(e *Engine) float64 { return float64(e.stats.Exchanges / e.width) }),
+		NewMetric("unique", func(e *Engine) float64 { return float64(e.similarCount()) }),
+	}
+	if e.params.Conquest > 0 {
+		builtins = append(builtins, NewMetric("conquest", func(e *Engine) float64 { return float64(e.stats.Conquered) }))
+	}
+	builtins = append(builtins, e.exchangeMetrics()...)
+	builtins = append(builtins, e.entropyMetrics()...)
+	builtins = append(builtins, e.performanceMetrics()...)
+	builtins = append(builtins, e.adaptiveMetrics()...)
+	builtins = append(builtins, e.eventMetrics()...)
+	builtins = append(builtins, e.institutionMetrics()...)
+	if e.cfg.Minority != nil {
+		builtins = append(builtins, NewMetric("minority", func(e *Engine) float64 { return float64(e.minorityCount()) }))
+	}
+
+	skipped := make(map[string]bool)
+	for _, name := range e.params.WithoutMetrics {
+		skipped[name] = false
+	}
+	for _, m := range builtins {
+		if _, ok := skipped[m.Name()]; ok {
+			skipped[m.Name()] = true
+			continue
+		}
+		if err := e.Register(m); err != nil {
+			return err
+		}
+	}
+	for _, name := range e.params.WithoutMetrics {
+		if !skipped[name] {
+			return fmt.Errorf("no built-in metric %q to leave out", name)
+		}
+	}
+	return nil
+}
+
+// measure every metric at the end of a tick and record its value
+func (e *Engine) measureMetrics() {
+	for _, m := range e.registry {
+		m.Update(e)
+		e.record(m.Name(), m.Value())
+	}
+}
+
+// latest value of a registered metric, 0 if there is none of that name
+func (e *Engine) value(name string) float64 {
+	for _, m := range e.registry {
+		if m.Name() == name {
+			return m.Value()
+		}
+	}
+	return 0
+}
+
+// Latest are the names of the recorded metrics and their values in the
+// latest tick, in the order of Metrics
+func (e *Engine) Latest() ([]string, []float64) {
+	names, values := make([]string, 0, len(e.metrics)), make([]float64, 0, len(e.metrics))
+	for _, s := range e.metrics {
+		if len(s.Values) > 0 {
+			names, values = append(names, s.Name), append(values, s.Values[len(s.Values)-1])
+		}
+	}
+	return names, values
+}
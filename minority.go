@@ -0,0 +1,85 @@
+package culsim
+
+import "errors"
+
+// Minority is a protected minority culture seeded in a region at the start.
+// Cells with the minority culture resist changing with probability
+// retention, and a minority media node influences every cell sharing at
+// least half its features with the minority culture with probability media
+// each tick, copying one differing trait.
+type Minority struct {
+	Culture   int     `json:"culture"`
+	Region    Region  `json:"region"`
+	Retention float64 `json:"retention"`
+	Media     float64 `json:"media"`
+}
+
+func (m *Minority) validate() error {
+	if m.Culture < 0 || m.Culture >= Empty {
+		return errors.New("culture out of range")
+	}
+	if m.Retention < 0 || m.Retention > 1 || m.Media < 0 || m.Media > 1 {
+		return errors.New("retention and media must be between 0 and 1")
+	}
+	return m.Region.validate()
+}
+
+// seed the minority culture in its region
+func (e *Engine) seedMinority() {
+	if e.cfg.Minority == nil {
+		return
+	}
+	for n := range e.cultures {
+		if e.cfg.Minority.Region.contains(n, e.width) {
+			e.cultures[n] = e.cfg.Minority.Culture
+		}
+	}
+}
+
+// check if the cell at n keeps its minority culture instead of changing
+func (e *Engine) retains(n int) bool {
+	return e.cfg.Minority != nil && e.cultures[n] == e.cfg.Minority.Culture &&
+		e.rng.Float64() < e.cfg.Minority.Retention
+}
+
+// let the minority media node influence the cells close to its culture
+func (e *Engine) broadcastMinority() {
+	if e.cfg.Minority == nil || e.cfg.Minority.Media == 0 {
+		return
+	}
+	m := e.cfg.Minority.Culture
+	for n, culture := range e.cultures {
+		if culture == Empty || culture == m || e.frozen(n) || e.sharedFeatures(culture, m)*2 < e.features {
+			continue
+		}
+		if e.rng.Float64() < e.cfg.Minority.Media {
+			f := uint(e.randomDifferingFeature(culture, m))
+			rp := replace(culture, extract(m, f), f)
+			if !e.cfg.Constraints.forbids(rp) && !e.locked(n, int(f)) {
+				e.cultures[n] = rp
+			}
+		}
+	}
+}
+
+// record how long the minority has lasted
+func (e *Engine) trackMinority() {
+	if e.cfg.Minority != nil && e.minorityCount() > 0 {
+		e.minorityPersistence = e.tick
+	}
+}
+
+// number of cells with the minority culture
+func (e *Engine) minorityCount() int {
+	var count int
+	for _, c := range e.cultures {
+		if c == e.cfg.Minority.Culture {
+			count++
+		}
+	}
+	return count
+}
+
+// MinorityPersistence is the last tick in which the minority culture was
+// still present
+func (e *Engine) MinorityPersistence() int { return e.minorityPersistence }
@@ -0,0 +1,77 @@
+package culsim
+
+import "errors"
+
+// NoiseSchedule varies the noise rate over the run. With a "step" schedule
+// the rate of each point holds from its tick until the next point, with a
+// "linear" schedule the rate is interpolated between points. Before the first
+// point and after the last the rate is held at their values.
+type NoiseSchedule struct {
+	Schedule string       `json:"schedule"`
+	Points   []NoisePoint `json:"points"`
+}
+
+// NoisePoint is the noise rate at a tick
+type NoisePoint struct {
+	Tick int     `json:"tick"`
+	Rate float64 `json:"rate"`
+}
+
+func (n *NoiseSchedule) validate() error {
+	if n.Schedule != "step" && n.Schedule != "linear" {
+		return errors.New(`schedule must be "step" or "linear"`)
+	}
+	if len(n.Points) == 0 {
+		return errors.New("schedule has no points")
+	}
+	for i, p := range n.Points {
+		if p.Rate < 0 || p.Rate > 1 {
+			return errors.New("rate must be between 0 and 1")
+		}
+		if i > 0 && p.Tick <= n.Points[i-1].Tick {
+			return errors.New("points must be in increasing tick order")
+		}
+	}
+	return nil
+}
+
+// noise rate at tick t
+func (n *NoiseSchedule) rate(t int) float64 {
+	if t <= n.Points[0].Tick {
+		return n.Points[0].Rate
+	}
+	for i := 1; i < len(n.Points); i++ {
+		p0, p1 := n.Points[i-1], n.Points[i]
+		if t < p1.Tick {
+			if n.Schedule == "step" {
+				return p0.Rate
+			}
+			return p0.Rate + (p1.Rate-p0.Rate)*float64(t-p0.Tick)/float64(p1.Tick-p0.Tick)
+		}
+	}
+	return n.Points[len(n.Points)-1].Rate
+}
+
+// current noise rate, from the config schedule if there is one
+func (e *Engine) noiseRate() float64 {
+	if e.cfg.Noise != nil {
+		return e.cfg.Noise.rate(e.tick)
+	}
+	return e.params.Noise
+}
+
+// randomly change the trait of one feature of the culture at cell n,
+// returning whether the culture changed. Empty cells have no traits to
+// change.
+func (e *Engine) mutate(n int) bool {
+	if e.cultures[n] == Empty {
+		return false
+	}
+	i := uint(e.rng.Intn(e.features))
+	culture := replace(e.cultures[n], e.rng.Intn(e.traits), i)
+	if e.cfg.Constraints.forbids(culture) || culture == e.cultures[n] || e.locked(n, int(i)) {
+		return false
+	}
+	e.cultures[n] = culture
+	return true
+}
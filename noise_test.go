@@ -0,0 +1,38 @@
+package culsim
+
+import (
+	"context"
+	"testing"
+)
+
+// number of empty cells of a grid
+func emptyCells(cultures []int) int {
+	var empty int
+	for _, c := range cultures {
+		if c == Empty {
+			empty++
+		}
+	}
+	return empty
+}
+
+// noise changes the traits of populated cells and leaves empty cells empty
+func TestNoiseKeepsEmptyCells(t *testing.T) {
+	e, err := New(WithGrid(24, 24), WithCoverage(0.5), WithNoise(1), WithSeed(3), WithDuration(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := e.Cultures()
+	if _, err = e.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	after := e.Cultures()
+	if got, want := emptyCells(after), emptyCells(before); got != want {
+		t.Errorf("%d empty cells after noise, want %d", got, want)
+	}
+	for n := range after {
+		if (before[n] == Empty) != (after[n] == Empty) {
+			t.Fatalf("cell %d went from %06X to %06X", n, before[n], after[n])
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package culsim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Rule decides how neighbouring cultures interact
+type Rule string
+
+const (
+	// Distance interacts with a probability falling with the total distance
+	// between the traits of the 2 cultures and copies the trait of a random
+	// feature
+	Distance Rule = "distance"
+	// Axelrod interacts with one random neighbour, with a probability equal
+	// to the fraction of features the 2 cultures share, and the chosen cell
+	// copies the trait of a feature they differ on
+	Axelrod Rule = "axelrod"
+)
+
+// Topology decides which cells are neighbours
+type Topology string
+
+const (
+	// Moore neighbours are the 8 surrounding cells, fewer at the edges
+	Moore Topology = "moore"
+	// VonNeumann neighbours are the 4 cells above, below, left and right
+	VonNeumann Topology = "von-neumann"
+	// Torus neighbours are the 8 surrounding cells of a grid whose edges
+	// wrap around
+	Torus Topology = "torus"
+)
+
+// Partner decides which of its neighbours the cell initiating an interaction
+// interacts with
+type Partner string
+
+const (
+	// AllNeighbours interacts with every neighbour in turn, the default of
+	// the distance rule
+	AllNeighbours Partner = "all"
+	// RandomNeighbour interacts with one random neighbour, the default of
+	// Axelrod's rule
+	RandomNeighbour Partner = "random"
+	// MostSimilar interacts with the populated neighbour sharing the most
+	// features, one of them at random on ties
+	MostSimilar Partner = "most-similar"
+	// SimilarityWeighted interacts with one populated neighbour chosen with a
+	// probability proportional to the features they share
+	SimilarityWeighted Partner = "weighted"
+)
+
+// Exchange decides how the distance between 2 cultures sets the probability
+// that they exchange a trait under the distance rule
+type Exchange string
+
+const (
+	// Linear falls from 1 to 0 in proportion to the distance
+	Linear Exchange = "linear"
+	// Overlap is the fraction of features the cultures share, as in
+	// Axelrod's rule
+	Overlap Exchange = "overlap"
+	// Sigmoid falls from 1 to 0 around a threshold distance, more steeply
+	// the larger the steepness
+	Sigmoid Exchange = "sigmoid"
+	// Threshold is 1 up to a threshold distance and 0 beyond it, as in
+	// bounded confidence models
+	Threshold Exchange = "threshold"
+)
+
+// Params are the parameters of a simulation, a mechanism whose parameter is
+// left at 0 is not part of the model
+type Params struct {
+	Width            int      // cells across the grid
+	Height           int      // cells down the grid
+	Features         int      // features of a culture, at most 6
+	Traits           int      // traits of each feature, at most 16
+	Rule             Rule     // how neighbouring cultures interact
+	Topology         Topology // which cells are neighbours
+	Partner          Partner  // which neighbours interact, "" for the default of the rule
+	Exchange         Exchange // how the distance between cultures sets their probability of an exchange
+	Steepness        float64  // steepness of the sigmoid exchange function
+	Threshold        float64  // distance, as a fraction, the sigmoid and threshold exchange functions turn at
+	Copies           int      // traits copied in an exchange, every differing one if at least the features
+	DifferingOnly    bool     // the distance rule chooses among the features the cultures differ in
+	Interactions     int      // interactions between cultures per tick
+	Coverage         float64  // fraction of the grid populated with cultures
+	Duration         int      // ticks Run runs the simulation for
+	Seed             int64    // seed of the random numbers
+	Noise            float64  // probability of a random trait change per interaction
+	Colonization     float64  // probability of spreading into an empty neighbour
+	Conquest         float64  // probability of a contest at each domain border per tick
+	ConquestDistance int      // minimum feature distance for domains to contest
+	ReputationBias   float64  // how strongly reputation biases who is copied
+	Refractory       int      // ticks a pair of cells rests after an exchange
+	Initial          string   // initial distribution of cultures
+	InvaderPrestige  float64  // extra copying weight of invader cells
+	InvaderActivity  float64  // extra chance of invader cells initiating
+	Workers          int      // goroutines running the tiles of parallel ticks, 0 for serial ticks
+	FeatureStats     bool     // record the exchanges of every tick by feature
+	InteractionStats bool     // record the interactions of every tick by outcome
+	Entropy          bool     // record the trait entropy of every feature every tick
+	UniquePrecision  int      // precision of the approximate count of unique cultures, 0 to count exactly
+	Shuffle          bool     // choose the cells initiating the interactions of a tick without replacement
+	Performance      bool     // record the wall time, allocations and interaction attempts of every tick
+	Adaptive         bool     // scale the interactions of every tick with the active bonds of the grid
+	Config           *Config  // optional simulation settings
+	WithoutMetrics   []string // built-in metrics left out
+}
+
+// default parameters, the same as the defaults of the culsim command
+func defaultParams() Params {
+	return Params{
+		Width:            36,
+		Height:           36,
+		Features:         Features,
+		Traits:           0x10,
+		Rule:             Distance,
+		Topology:         Moore,
+		Exchange:         Linear,
+		Steepness:        10,
+		Threshold:        0.5,
+		Copies:           1,
+		Interactions:     100,
+		Coverage:         1,
+		Duration:         200,
+		ConquestDistance: 3,
+		Initial:          "random",
+	}
+}
+
+// check the parameters describe a simulation that can run
+func (p Params) validate() error {
+	if p.Width <= 0 || p.Height <= 0 {
+		return fmt.Errorf("invalid grid of %dx%d cells", p.Width, p.Height)
+	}
+	if p.Features < 1 || p.Features > Features {
+		return fmt.Errorf("features %d out of range [1,%d]", p.Features, Features)
+	}
+	if p.Traits < 1 || p.Traits > 0x10 {
+		return fmt.Errorf("traits %d out of range [1,%d]", p.Traits, 0x10)
+	}
+	switch p.Rule {
+	case Distance, Axelrod:
+	default:
+		return fmt.Errorf("unknown rule %q", p.Rule)
+	}
+	switch p.Partner {
+	case "", AllNeighbours, RandomNeighbour, MostSimilar, SimilarityWeighted:
+	default:
+		return fmt.Errorf("unknown partner strategy %q", p.Partner)
+	}
+	switch p.Exchange {
+	case Linear, Overlap, Sigmoid, Threshold:
+	default:
+		return fmt.Errorf("unknown exchange function %q", p.Exchange)
+	}
+	if p.Exchange != Linear && p.Rule != Distance {
+		return errors.New("exchange functions are for the distance rule")
+	}
+	if p.Steepness < 0 || p.Threshold < 0 || p.Threshold > 1 {
+		return errors.New("steepness cannot be negative and the threshold must be between 0 and 1")
+	}
+	switch p.Topology {
+	case Moore, VonNeumann:
+	case Torus:
+		// smaller grids would make a cell its own neighbour
+		if p.Width < 3 || p.Height < 3 {
+			return errors.New("a torus needs a grid of at least 3x3 cells")
+		}
+	default:
+		return fmt.Errorf("unknown topology %q", p.Topology)
+	}
+	if p.Copies < 1 {
+		return errors.New("an exchange copies at least 1 trait")
+	}
+	if p.Interactions < 0 || p.Duration < 0 {
+		return errors.New("interactions and duration cannot be negative")
+	}
+	if p.Workers < 0 {
+		return errors.New("workers cannot be negative")
+	}
+	if p.UniquePrecision != 0 && (p.UniquePrecision < 4 || p.UniquePrecision > 16) {
+		return errors.New("precision of the unique count must be between 4 and 16")
+	}
+	if p.Shuffle && p.InvaderActivity != 0 {
+		return errors.New("cells chosen without replacement cannot favour active invaders")
+	}
+	if p.Coverage < 0 || p.Coverage > 1 {
+		return errors.New("coverage must be between 0 and 1")
+	}
+	if err := p.validateAdaptive(); err != nil {
+		return err
+	}
+	if err := p.validateWindow(); err != nil {
+		return err
+	}
+	if p.Config != nil {
+		for i := range p.Config.Interventions {
+			if err := p.Config.Interventions[i].validate(p.Width, p.Height, p.Features, p.Traits); err != nil {
+				return fmt.Errorf("invalid config: intervention %d: %w", i, err)
+			}
+		}
+	}
+	if p.Config != nil {
+		if err := p.Config.validate(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+		if err := p.Config.fit(p.Features, p.Traits); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if err := p.validateBlobs(); err != nil {
+		return fmt.Errorf("invalid config: init: %w", err)
+	}
+	return nil
+}
+
+// Option sets a parameter of a simulation created with New
+type Option func(*Params)
+
+// WithGrid sets the cells across and down the grid, 36 by 36 by default
+func WithGrid(width, height int) Option {
+	return func(p *Params) { p.Width, p.Height = width, height }
+}
+
+// WithFeatures sets the number of features of a culture and of traits of
+// each feature, 6 and 16 by default
+func WithFeatures(features, traits int) Option {
+	return func(p *Params) { p.Features, p.Traits = features, traits }
+}
+
+// WithRule sets how neighbouring cultures interact, Distance by default
+func WithRule(rule Rule) Option {
+	return func(p *Params) { p.Rule = rule }
+}
+
+// WithPartner sets which neighbours the cell initiating an interaction
+// interacts with, by default every neighbour under the distance rule and a
+// random one under Axelrod's rule
+func WithPartner(partner Partner) Option {
+	return func(p *Params) { p.Partner = partner }
+}
+
+// WithExchange sets how the distance between 2 cultures sets the probability
+// that they exchange a trait under the distance rule, Linear by default, and
+// the steepness and threshold distance, as a fraction of the features times
+// the traits, of the Sigmoid and Threshold functions, 10 and 0.5 by default
+func WithExchange(fn Exchange, steepness, threshold float64) Option {
+	return func(p *Params) { p.Exchange, p.Steepness, p.Threshold = fn, steepness, threshold }
+}
+
+// WithCopies sets how many traits an exchange copies, the one of the feature
+// the rule chooses and up to k - 1 more the 2 cultures differ in, 1 by
+// default. With k of at least the features, an exchange copies every trait
+// they differ in, a full assimilation.
+func WithCopies(k int) Option {
+	return func(p *Params) { p.Copies = k }
+}
+
+// WithDifferingOnly makes the distance rule choose the feature whose trait is
+// copied among the features the 2 cultures differ in, as Axelrod's rule
+// does, instead of among all the features, so no exchange copies a trait the
+// copying cell already has
+func WithDifferingOnly() Option {
+	return func(p *Params) { p.DifferingOnly = true }
+}
+
+// WithSeed sets the seed of the random numbers, 0 by default
+func WithSeed(seed int64) Option {
+	return func(p *Params) { p.Seed = seed }
+}
+
+// WithTopology sets which cells are neighbours, Moore by default
+func WithTopology(t Topology) Option {
+	return func(p *Params) { p.Topology = t }
+}
+
+// WithInteractions sets the interactions between cultures per tick, 100 by
+// default
+func WithInteractions(n int) Option {
+	return func(p *Params) { p.Interactions = n }
+}
+
+// WithAdaptiveInteractions scales the interactions of every tick with the
+// active bonds of the grid, the pairs of populated neighbours that could
+// still exchange a trait, to Params.Interactions times the fraction of
+// bonds that are active, at least 1 while any is. Nearly frozen runs then
+// skip the interactions that couldn't change anything, at the cost of
+// counting the bonds every tick, and stop interacting once frozen. The
+// interactions of every tick are recorded as the interactions metric. It
+// can't be combined with noise or colonization.
+func WithAdaptiveInteractions() Option {
+	return func(p *Params) { p.Adaptive = true }
+}
+
+// WithCoverage sets the fraction of the grid populated with cultures, all of
+// it by default
+func WithCoverage(coverage float64) Option {
+	return func(p *Params) { p.Coverage = coverage }
+}
+
+// WithDuration sets the ticks Run runs the simulation for, 200 by default
+func WithDuration(ticks int) Option {
+	return func(p *Params) { p.Duration = ticks }
+}
+
+// WithNoise sets the probability of a random trait change per interaction
+func WithNoise(rate float64) Option {
+	return func(p *Params) { p.Noise = rate }
+}
+
+// WithColonization sets the probability of spreading into an empty
+// neighbour
+func WithColonization(rate float64) Option {
+	return func(p *Params) { p.Colonization = rate }
+}
+
+// WithConquest sets the probability of a contest at each domain border per
+// tick and the minimum feature distance for domains to contest, 3 by default
+func WithConquest(rate float64, distance int) Option {
+	return func(p *Params) { p.Conquest, p.ConquestDistance = rate, distance }
+}
+
+// WithReputation sets how strongly reputation biases who is copied
+func WithReputation(bias float64) Option {
+	return func(p *Params) { p.ReputationBias = bias }
+}
+
+// WithRefractory sets the ticks a pair of cells rests after an exchange
+func WithRefractory(ticks int) Option {
+	return func(p *Params) { p.Refractory = ticks }
+}
+
+// WithInitial sets the initial distribution of cultures: random, converged,
+// zipf, clusters, blobs, marginals or file, random by default
+func WithInitial(kind string) Option {
+	return func(p *Params) { p.Initial = kind }
+}
+
+// WithInvader sets the extra copying weight and the extra chance of
+// initiating of invader cells
+func WithInvader(prestige, activity float64) Option {
+	return func(p *Params) { p.InvaderPrestige, p.InvaderActivity = prestige, activity }
+}
+
+// WithWorkers runs the interactions of every tick in tiles of the grid on n
+// goroutines. Parallel runs are the same for any number of workers, given
+// the same seed, but differ from serial runs, the default with 0 workers.
+func WithWorkers(n int) Option {
+	return func(p *Params) { p.Workers = n }
+}
+
+// WithFeatureStats records the exchanges of every tick by the feature whose
+// trait is copied, as the copied-0 to copied-5 metrics, and the interactions
+// blocked because the cultures have no distance between them, as the
+// blocked-identical metric
+func WithFeatureStats() Option {
+	return func(p *Params) { p.FeatureStats = true }
+}
+
+// WithInteractionStats records the interactions of every tick by how they
+// turned out: the attempts of pairs of populated neighbours, the ones
+// rejected by the probability of the rule, blocked-identical for cultures
+// with no distance between them, blocked for feature rates, constraints and
+// minorities keeping a trait from being copied, and the copies made. The
+// attempts add up to the other 4, and copies is the raw count the change
+// metric divides by the width of the grid.
+func WithInteractionStats() Option {
+	return func(p *Params) { p.InteractionStats = true }
+}
+
+// WithEntropy records the Shannon entropy in bits of the traits of each
+// feature over the populated cells at the end of every tick, as the
+// entropy-0 to entropy-5 metrics. Features converge at their own pace, and
+// a feature whose entropy falls to 0 is shared by the whole grid.
+func WithEntropy() Option {
+	return func(p *Params) { p.Entropy = true }
+}
+
+// WithApproximateUnique counts the unique cultures of the unique metric
+// approximately, with a HyperLogLog sketch of 2^precision registers, instead
+// of exactly with a set of every culture. It takes a fixed 2^precision bytes
+// for grids of any size, with a standard error of 1.04/sqrt(2^precision),
+// 1.6% at precision 12. The precision is between 4 and 16.
+func WithApproximateUnique(precision int) Option {
+	return func(p *Params) { p.UniquePrecision = precision }
+}
+
+// WithPerformance records the cost of every tick alongside the model
+// metrics: wall-us, its wall time in microseconds up to measuring the metrics,
+// allocs and alloc-bytes, the heap allocations of the whole process during
+// the tick, and attempts, the interactions it attempted. Reading the
+// allocations briefly stops the world, so it slows the run a little.
+func WithPerformance() Option {
+	return func(p *Params) { p.Performance = true }
+}
+
+// WithoutReplacement chooses the cells initiating the interactions of a
+// tick without replacement, a shuffled subset of the populated cells, instead
+// of drawing each from every cell of the grid. No cell then initiates twice
+// in a tick, or empty cells at all, until every populated cell has. It
+// can't be combined with the activity of invaders.
+func WithoutReplacement() Option {
+	return func(p *Params) { p.Shuffle = true }
+}
+
+// WithoutMetrics leaves out built-in metrics, such as distance, which is the
+// slowest to measure
+func WithoutMetrics(names ...string) Option {
+	return func(p *Params) { p.WithoutMetrics = append(p.WithoutMetrics, names...) }
+}
+
+// WithConfig sets the optional simulation settings
+func WithConfig(cfg *Config) Option {
+	return func(p *Params) { p.Config = cfg }
+}
@@ -0,0 +1,207 @@
+package culsim
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// side of the tiles the grid is split into for parallel ticks, in cells.
+// Tiles need at least 2 cells on each side, so that tiles run at the same
+// time never touch the same cells or their neighbours.
+const tileSize = 8
+
+// part of the grid whose cells interact in one go in a parallel tick
+type tile struct {
+	x, y, w, h int
+	colour     int // tiles of the same colour are run at the same time
+}
+
+// split a grid into tiles of tileSize cells a side, the last tiles of a row
+// or column taking in the cells left over. The tiles only depend on the size
+// of the grid, never on the number of workers.
+func tiles(width, height int) []tile {
+	xs, ys := tileBounds(width), tileBounds(height)
+	var ts []tile
+	for j := 0; j+1 < len(ys); j++ {
+		for i := 0; i+1 < len(xs); i++ {
+			ts = append(ts, tile{
+				x: xs[i], y: ys[j], w: xs[i+1] - xs[i], h: ys[j+1] - ys[j],
+				colour: tileParity(j, len(ys)-1)*3 + tileParity(i, len(xs)-1),
+			})
+		}
+	}
+	return ts
+}
+
+// where the tiles along a side of n cells start, ending with n
+func tileBounds(n int) []int {
+	bounds := []int{0}
+	for b := tileSize; b+tileSize <= n; b += tileSize {
+		bounds = append(bounds, b)
+	}
+	return append(bounds, n)
+}
+
+// colour of the i-th of n tiles along a side. Neighbouring tiles alternate,
+// and the last of an odd number has a colour of its own, as on a torus it
+// neighbours the first.
+func tileParity(i, n int) int {
+	if n > 1 && n%2 == 1 && i == n-1 {
+		return 2
+	}
+	return i % 2
+}
+
+// cells in the tile
+func (t *tile) size() int { return t.w * t.h }
+
+// index of the k-th cell of the tile in a grid of the given width
+func (t *tile) cell(k, width int) int {
+	return (t.y+k/t.w)*width + t.x + k%t.w
+}
+
+// randomly choose a cell of the grid, of the window of the config, or of
+// the tile of a parallel tick
+func (e *Engine) randomCell() int {
+	if e.tile == nil && e.cfg.Window != nil {
+		return e.windowCell()
+	}
+	if e.tile == nil {
+		return e.rng.Intn(len(e.cultures))
+	}
+	return e.tile.cell(e.rng.Intn(e.tile.size()), e.width)
+}
+
+// randomly choose n populated cells of the grid, or of the tile of a
+// parallel tick, without replacement. Once every populated cell has been
+// chosen they are shuffled again for the rest, and there are none if no
+// cell is populated.
+func (e *Engine) shuffled(n int) []int {
+	var populated []int
+	if e.tile == nil {
+		for i, c := range e.cultures {
+			if c != Empty && !e.frozen(i) {
+				populated = append(populated, i)
+			}
+		}
+	} else {
+		for k := 0; k < e.tile.size(); k++ {
+			if i := e.tile.cell(k, e.width); e.cultures[i] != Empty {
+				populated = append(populated, i)
+			}
+		}
+	}
+	drawn := make([]int, 0, n)
+	for len(populated) > 0 && len(drawn) < n {
+		// shuffle only as many cells as are still needed
+		k := min(n-len(drawn), len(populated))
+		for i := 0; i < k; i++ {
+			j := i + e.rng.Intn(len(populated)-i)
+			populated[i], populated[j] = populated[j], populated[i]
+		}
+		drawn = append(drawn, populated[:k]...)
+	}
+	return drawn
+}
+
+// run the interactions of a tick in tiles on Params.Workers goroutines. The
+// tiles of each colour run at the same time, one colour after the other, and
+// every tile draws its random numbers from its own stream, seeded from the
+// seed of the run, the tick and the tile. The tiles of a colour touch
+// different cells, and their exchanges are published in the order of the
+// tiles once they are done, so a parallel run is the same whatever the
+// number of workers, though not the same as a run that isn't parallel.
+// Returns the number of exchanges and of mutations.
+func (e *Engine) exchangeTiles() (int, int) {
+	ts := tiles(e.width, e.height)
+	chg, mutated := make([]int, len(ts)), make([]int, len(ts))
+	published := make([][]Message, len(ts))
+	for colour := 0; colour < 9; colour++ {
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < e.params.Workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					chg[i], mutated[i] = e.exchangeTile(ts, i, &published[i])
+				}
+			}()
+		}
+		for i := range ts {
+			if ts[i].colour == colour {
+				jobs <- i
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		for i := range ts {
+			if ts[i].colour == colour {
+				for _, m := range published[i] {
+					e.bus.Publish(m)
+				}
+			}
+		}
+	}
+	var total, mutations int
+	for i := range ts {
+		total += chg[i]
+		mutations += mutated[i]
+	}
+	return total, mutations
+}
+
+// run the interactions of the i-th tile, its share of the interactions of
+// the tick, on a copy of the engine with the tile's own random numbers. The
+// exchanges it would publish are kept for exchangeTiles.
+func (e *Engine) exchangeTile(ts []tile, i int, published *[]Message) (int, int) {
+	// the interactions before the tile and up to its end, in proportion to
+	// the cells, so the shares add up to all interactions
+	var before int
+	for _, t := range ts[:i] {
+		before += t.size()
+	}
+	cells := len(e.cultures)
+	n := e.interactions*(before+ts[i].size())/cells - e.interactions*before/cells
+	t := *e
+	t.tile = &ts[i]
+	t.rng = rand.New(&splitMix{tileSeed(e.seed, e.tick, i)})
+	t.bus = &Bus{}
+	if e.bus.active() {
+		t.bus.Subscribe(func(m Message) { *published = append(*published, m) })
+	}
+	if e.tally != nil {
+		t.tally = &exchangeStats{copied: make([]int, e.features)}
+		defer e.tally.add(t.tally)
+	}
+	return t.exchange(n)
+}
+
+// seed of the random numbers of a tile in a tick
+func tileSeed(seed int64, tick, tile int) uint64 {
+	return mix(mix(mix(uint64(seed)+golden)^uint64(tick)) ^ uint64(tile))
+}
+
+// increment of the splitmix64 state, from the golden ratio
+const golden = 0x9e3779b97f4a7c15
+
+// splitmix64 random numbers, cheap to seed for every tile of every tick
+type splitMix struct {
+	state uint64
+}
+
+func (s *splitMix) Uint64() uint64 {
+	s.state += golden
+	return mix(s.state)
+}
+
+func (s *splitMix) Int63() int64 { return int64(s.Uint64() >> 1) }
+
+func (s *splitMix) Seed(seed int64) { s.state = uint64(seed) }
+
+// finalizer of splitmix64, scrambling the bits of x
+func mix(x uint64) uint64 {
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,49 @@
+package culsim
+
+import "testing"
+
+// cells drawn without replacement are populated cells, each drawn once
+// before any is drawn again, and none of the cells frozen outside a window
+func TestShuffled(t *testing.T) {
+	window := &Config{Window: &Region{X: 2, Y: 2, W: 6, H: 6}}
+	for _, cfg := range []*Config{nil, window} {
+		e, err := New(WithGrid(10, 10), WithCoverage(0.5), WithSeed(1), WithConfig(cfg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var populated int
+		for n, c := range e.cultures {
+			if c != Empty && !e.frozen(n) {
+				populated++
+			}
+		}
+		for _, n := range []int{1, populated / 2, populated, populated + 3, 3*populated + 1} {
+			drawn := e.shuffled(n)
+			if len(drawn) != n {
+				t.Fatalf("drew %d cells, want %d", len(drawn), n)
+			}
+			times := make(map[int]int)
+			for _, cell := range drawn {
+				if e.cultures[cell] == Empty || e.frozen(cell) {
+					t.Fatalf("drew cell %d, which is empty or frozen", cell)
+				}
+				times[cell]++
+			}
+			for cell, k := range times {
+				if k < n/populated || k > (n+populated-1)/populated {
+					t.Fatalf("drew cell %d %d times of %d from %d cells", cell, k, n, populated)
+				}
+			}
+			if n >= populated && len(times) != populated {
+				t.Fatalf("drew %d of %d cells in %d draws", len(times), populated, n)
+			}
+		}
+	}
+	e, err := New(WithGrid(10, 10), WithCoverage(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drawn := e.shuffled(10); len(drawn) != 0 {
+		t.Errorf("drew %d cells of an empty grid", len(drawn))
+	}
+}
@@ -0,0 +1,68 @@
+package culsim
+
+// neighbours of the cell at r it interacts with in an interaction, by the
+// partner strategy of the run or the one of the rule
+func (e *Engine) partners(r int, axelrod bool) []int {
+	neighbours := e.neighbours(r)
+	partner := e.params.Partner
+	if partner == "" {
+		partner = AllNeighbours
+		if axelrod {
+			partner = RandomNeighbour
+		}
+	}
+	switch partner {
+	case RandomNeighbour:
+		if len(neighbours) > 0 {
+			return neighbours[e.rng.Intn(len(neighbours)):][:1]
+		}
+	case MostSimilar:
+		// the first of the most similar or, on ties, one of them at random
+		best, ties := -1, 0
+		var chosen int
+		for _, n := range neighbours {
+			if e.cultures[n] == Empty {
+				continue
+			}
+			shared := sharedFeatures(e.cultures[r], e.cultures[n], e.features)
+			switch {
+			case shared > best:
+				best, ties, chosen = shared, 1, n
+			case shared == best:
+				ties++
+				if e.rng.Intn(ties) == 0 {
+					chosen = n
+				}
+			}
+		}
+		if best >= 0 {
+			return []int{chosen}
+		}
+	case SimilarityWeighted:
+		// populated neighbours weighted by the features they share, equally
+		// if they share none
+		var populated, weights []int
+		var total int
+		for _, n := range neighbours {
+			if e.cultures[n] != Empty {
+				shared := sharedFeatures(e.cultures[r], e.cultures[n], e.features)
+				populated, weights = append(populated, n), append(weights, shared)
+				total += shared
+			}
+		}
+		if len(populated) == 0 {
+			return nil
+		}
+		if total == 0 {
+			return populated[e.rng.Intn(len(populated)):][:1]
+		}
+		pick := e.rng.Intn(total)
+		for i, w := range weights {
+			if pick < w {
+				return populated[i : i+1]
+			}
+			pick -= w
+		}
+	}
+	return neighbours
+}
@@ -0,0 +1,67 @@
+package culsim
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// the neighbours of the centre of a 3x3 grid each partner strategy picks,
+// with cultures sharing 5, 4 and 5 features with the centre and an empty
+// cell, or sharing none
+func TestPartners(t *testing.T) {
+	similar := map[int]int{1: 0x000001, 3: 0x000011, 5: Empty, 7: 0x100000}
+	unlike := map[int]int{1: 0x111111, 3: 0x222222, 5: Empty, 7: 0x333333}
+	cases := []struct {
+		name      string
+		partner   Partner
+		axelrod   bool
+		neighbour map[int]int
+		picked    map[int]float64 // share of the picks of each neighbour
+	}{
+		{name: "all", partner: AllNeighbours, neighbour: similar, picked: map[int]float64{1: 1, 3: 1, 5: 1, 7: 1}},
+		{name: "distance default", neighbour: similar, picked: map[int]float64{1: 1, 3: 1, 5: 1, 7: 1}},
+		{name: "random", partner: RandomNeighbour, neighbour: similar, picked: map[int]float64{1: 0.25, 3: 0.25, 5: 0.25, 7: 0.25}},
+		{name: "axelrod default", axelrod: true, neighbour: similar, picked: map[int]float64{1: 0.25, 3: 0.25, 5: 0.25, 7: 0.25}},
+		{name: "most similar", partner: MostSimilar, neighbour: similar, picked: map[int]float64{1: 0.5, 7: 0.5}},
+		{name: "most similar of none shared", partner: MostSimilar, neighbour: unlike, picked: map[int]float64{1: 1. / 3, 3: 1. / 3, 7: 1. / 3}},
+		{name: "weighted", partner: SimilarityWeighted, neighbour: similar, picked: map[int]float64{1: 5. / 14, 3: 4. / 14, 7: 5. / 14}},
+		{name: "weighted of none shared", partner: SimilarityWeighted, neighbour: unlike, picked: map[int]float64{1: 1. / 3, 3: 1. / 3, 7: 1. / 3}},
+	}
+	const runs = 20000
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newEngine(Params{Width: 3, Height: 3, Features: Features, Traits: 0x10, Topology: VonNeumann, Partner: c.partner, Seed: 1})
+			for n := range e.cultures {
+				e.cultures[n] = Empty
+			}
+			e.cultures[4] = 0x000000
+			for n, culture := range c.neighbour {
+				e.cultures[n] = culture
+			}
+			picks := make(map[int]int)
+			for run := 0; run < runs; run++ {
+				partners := e.partners(4, c.axelrod)
+				if len(partners) != 1 {
+					sort.Ints(partners)
+					if !reflect.DeepEqual(partners, []int{1, 3, 5, 7}) {
+						t.Fatalf("picked neighbours %v", partners)
+					}
+				}
+				for _, n := range partners {
+					picks[n]++
+				}
+			}
+			for n := range picks {
+				if _, ok := c.picked[n]; !ok {
+					t.Fatalf("picked neighbour %d, %06X", n, e.cultures[n])
+				}
+			}
+			for n, share := range c.picked {
+				if got := float64(picks[n]) / runs; got < share-0.02 || got > share+0.02 {
+					t.Errorf("picked neighbour %d in %.3f of the runs, want %.3f", n, got, share)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package culsim
+
+import (
+	"runtime"
+	"time"
+)
+
+// wall time and heap allocations of the current tick, with WithPerformance
+type performance struct {
+	started time.Time
+	mallocs uint64 // heap allocations of the process when the tick started
+	bytes   uint64 // bytes allocated by the process when the tick started
+
+	wall       time.Duration
+	allocs     uint64
+	allocBytes uint64
+}
+
+// start measuring the tick
+func (p *performance) start() {
+	if p == nil {
+		return
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	p.started, p.mallocs, p.bytes = time.Now(), m.Mallocs, m.TotalAlloc
+}
+
+// stop measuring the tick, before its metrics are measured
+func (p *performance) stop() {
+	if p == nil {
+		return
+	}
+	p.wall = time.Since(p.started)
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	p.allocs, p.allocBytes = m.Mallocs-p.mallocs, m.TotalAlloc-p.bytes
+}
+
+// metrics of the performance of every tick with WithPerformance: its wall
+// time in microseconds, the heap allocations and bytes allocated by the
+// process while it ran, and the interactions it attempted unless
+// WithInteractionStats counts them already
+func (e *Engine) performanceMetrics() []Metric {
+	if !e.params.Performance {
+		return nil
+	}
+	e.perf = &performance{}
+	metrics := []Metric{
+		NewMetric("wall-us", func(e *Engine) float64 { return float64(e.perf.wall.Microseconds()) }),
+		NewMetric("allocs", func(e *Engine) float64 { return float64(e.perf.allocs) }),
+		NewMetric("alloc-bytes", func(e *Engine) float64 { return float64(e.perf.allocBytes) }),
+	}
+	if !e.params.InteractionStats {
+		metrics = append(metrics, NewMetric("attempts", func(e *Engine) float64 { return float64(e.tally.attempts) }))
+	}
+	return metrics
+}
@@ -0,0 +1,39 @@
+package culsim
+
+import "sync"
+
+// ticks each pair of cells last exchanged a trait, shared by the tiles of a
+// parallel tick. The tiles running at the same time touch different pairs,
+// so the order they take the lock in doesn't change the ticks recorded.
+type exchangeLog struct {
+	sync.Mutex
+	ticks map[[2]int]int
+}
+
+// key for the pair of cells a and b, the same whichever way round they are
+func pairKey(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+// check if the pair of cells is still recovering from a recent exchange
+func (e *Engine) resting(a, b int) bool {
+	if e.params.Refractory <= 0 {
+		return false
+	}
+	e.lastExchange.Lock()
+	last, ok := e.lastExchange.ticks[pairKey(a, b)]
+	e.lastExchange.Unlock()
+	return ok && e.tick-last < e.params.Refractory
+}
+
+// record an exchange between the pair of cells in the current tick
+func (e *Engine) exchanged(a, b int) {
+	if e.params.Refractory > 0 {
+		e.lastExchange.Lock()
+		e.lastExchange.ticks[pairKey(a, b)] = e.tick
+		e.lastExchange.Unlock()
+	}
+}
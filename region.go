@@ -0,0 +1,25 @@
+package culsim
+
+import "errors"
+
+// Region is a rectangle of cells on the simulation grid, X and Y are the
+// 0-based column and row of its top left cell
+type Region struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+func (r Region) validate() error {
+	if r.W <= 0 || r.H <= 0 {
+		return errors.New("region must have a positive width and height")
+	}
+	return nil
+}
+
+// check if the cell at index n of a grid of the given width is in the region
+func (r Region) contains(n, width int) bool {
+	x, y := n%width, n/width
+	return x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H
+}
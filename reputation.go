@@ -0,0 +1,14 @@
+package culsim
+
+import "math"
+
+// copying weight of the cell at n from its reputation, the number of times it
+// has successfully influenced a neighbour. Cells with a higher reputation are
+// more likely to be copied.
+func (e *Engine) reputationWeight(n int) float64 {
+	return math.Pow(1+float64(e.influences[n]), e.params.ReputationBias)
+}
+
+// Influences is the number of times each cell has successfully influenced a
+// neighbour over the run
+func (e *Engine) Influences() []int { return append([]int(nil), e.influences...) }
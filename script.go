@@ -0,0 +1,120 @@
+package culsim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ScriptedRule replaces the rule of the simulation with expressions, so
+// rule variants can be tried without recompiling. Probability is the
+// probability that 2 neighbouring cultures interact, from the variables
+// shared, features, traits, similarity (shared / features), distance (the
+// total distance between their traits) and tick. Feature is the weight of
+// each feature being the one whose trait is copied, from the same variables
+// and feature (its index), a and b (the traits of the 2 cultures in that
+// feature) and differs (1 if a and b differ), by default differs.
+type ScriptedRule struct {
+	Probability string `json:"probability"`
+	Feature     string `json:"feature"`
+
+	probability expr
+	feature     expr
+}
+
+// variables of an interaction that the expressions of a scripted rule see
+type ruleVars struct {
+	shared, features, traits, distance, tick float64
+	feature, a, b                            float64
+}
+
+// variables of the probability expression, the feature expression also has
+// the ones in featureVars
+var pairVars = map[string]func(v *ruleVars) float64{
+	"shared":     func(v *ruleVars) float64 { return v.shared },
+	"features":   func(v *ruleVars) float64 { return v.features },
+	"traits":     func(v *ruleVars) float64 { return v.traits },
+	"similarity": func(v *ruleVars) float64 { return v.shared / v.features },
+	"distance":   func(v *ruleVars) float64 { return v.distance },
+	"tick":       func(v *ruleVars) float64 { return v.tick },
+}
+
+var featureVars = map[string]func(v *ruleVars) float64{
+	"feature": func(v *ruleVars) float64 { return v.feature },
+	"a":       func(v *ruleVars) float64 { return v.a },
+	"b":       func(v *ruleVars) float64 { return v.b },
+	"differs": func(v *ruleVars) float64 { return truth(v.a != v.b) },
+}
+
+// compile the expressions of the rule
+func (r *ScriptedRule) validate() error {
+	if r.Probability == "" {
+		return errors.New("probability expression is required")
+	}
+	var err error
+	if r.probability, err = compileExpr(r.Probability, pairVars); err != nil {
+		return fmt.Errorf("probability: %w", err)
+	}
+	vars := make(map[string]func(v *ruleVars) float64)
+	for name, v := range pairVars {
+		vars[name] = v
+	}
+	for name, v := range featureVars {
+		vars[name] = v
+	}
+	src := r.Feature
+	if src == "" {
+		src = "differs"
+	}
+	if r.feature, err = compileExpr(src, vars); err != nil {
+		return fmt.Errorf("feature: %w", err)
+	}
+	return nil
+}
+
+// the feature whose trait 2 neighbouring cells exchange under the scripted
+// rule, -1 if they don't interact
+func (r *ScriptedRule) interact(e *Engine, a, b int) int {
+	ca, cb := e.cultures[a], e.cultures[b]
+	v := ruleVars{
+		shared:   float64(e.sharedFeatures(ca, cb)),
+		features: float64(e.features),
+		traits:   float64(e.traits),
+		tick:     float64(e.tick),
+	}
+	for i := 0; i < e.features; i++ {
+		v.distance += float64(traitDistance(ca, cb, uint(i)))
+	}
+	// NaN probabilities never interact
+	if !(e.rng.Float64() < r.probability(&v)) {
+		e.tally.reject()
+		return -1
+	}
+	weights := make([]float64, e.features)
+	var total float64
+	for i := range weights {
+		v.feature, v.a, v.b = float64(i), float64(extract(ca, uint(i))), float64(extract(cb, uint(i)))
+		// negative weights are never chosen
+		if w := r.feature(&v); w > 0 {
+			weights[i] = w
+			total += w
+		}
+	}
+	// no feature to copy is a rejection of the rule too
+	if total == 0 {
+		e.tally.reject()
+		return -1
+	}
+	x, chosen := e.rng.Float64()*total, -1
+	for i, w := range weights {
+		if w == 0 {
+			continue
+		}
+		// the last feature with a weight if rounding leaves x over
+		chosen = i
+		if x < w {
+			break
+		}
+		x -= w
+	}
+	return chosen
+}
@@ -0,0 +1,69 @@
+package culsim
+
+import "fmt"
+
+// MovingAverage is a metric of the average of another metric over its
+// latest window ticks, fewer at the start of the run, named like
+// change-ma10. Register it after the metric it averages. A window of less
+// than 1 tick is taken as 1.
+func MovingAverage(name string, window int) Metric {
+	return &movingAverage{name: name, window: max(window, 1)}
+}
+
+// ExponentialAverage is a metric of the exponentially weighted moving
+// average of another metric, with the weight of a window ticks moving
+// average, 2/(window+1), named like change-ewma10. Register it after the
+// metric it averages. A window of less than 1 tick is taken as 1.
+func ExponentialAverage(name string, window int) Metric {
+	window = max(window, 1)
+	return &exponentialAverage{name: name, alpha: 2 / float64(window+1), window: window}
+}
+
+// moving average over the latest ticks
+type movingAverage struct {
+	name   string
+	window int
+	values []float64 // values in the window
+	sum    float64
+}
+
+func (m *movingAverage) Name() string { return fmt.Sprintf("%s-ma%d", m.name, m.window) }
+
+func (m *movingAverage) Update(e *Engine) {
+	v := e.value(m.name)
+	m.values = append(m.values, v)
+	m.sum += v
+	if len(m.values) > m.window {
+		m.sum -= m.values[0]
+		m.values = m.values[1:]
+	}
+}
+
+func (m *movingAverage) Value() float64 {
+	if len(m.values) == 0 {
+		return 0
+	}
+	return m.sum / float64(len(m.values))
+}
+
+// exponentially weighted moving average, starting from the first value
+type exponentialAverage struct {
+	name    string
+	window  int
+	alpha   float64
+	value   float64
+	started bool
+}
+
+func (m *exponentialAverage) Name() string { return fmt.Sprintf("%s-ewma%d", m.name, m.window) }
+
+func (m *exponentialAverage) Update(e *Engine) {
+	v := e.value(m.name)
+	if !m.started {
+		m.value, m.started = v, true
+		return
+	}
+	m.value += m.alpha * (v - m.value)
+}
+
+func (m *exponentialAverage) Value() float64 { return m.value }
@@ -0,0 +1,54 @@
+package culsim
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// values of a recorded metric
+func series(t *testing.T, e *Engine, name string) []float64 {
+	t.Helper()
+	for _, s := range e.Metrics() {
+		if s.Name == name {
+			return s.Values
+		}
+	}
+	t.Fatalf("no metric %s", name)
+	return nil
+}
+
+// a moving average averages the latest window values of its metric, and
+// windows of less than 1 tick average only the latest value
+func TestMovingAverages(t *testing.T) {
+	e, err := New(WithGrid(12, 12), WithSeed(1), WithDuration(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []Metric{MovingAverage("unique", 3), MovingAverage("unique", 0), ExponentialAverage("unique", -2)} {
+		if err = e.Register(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = e.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	unique := series(t, e, "unique")
+	for i, v := range series(t, e, "unique-ma3") {
+		var sum float64
+		from := max(i-2, 0)
+		for _, u := range unique[from : i+1] {
+			sum += u
+		}
+		if want := sum / float64(i+1-from); math.Abs(v-want) > 1e-9 {
+			t.Errorf("3 tick moving average %v at tick %d, want %v", v, i+1, want)
+		}
+	}
+	for _, name := range []string{"unique-ma1", "unique-ewma1"} {
+		for i, v := range series(t, e, name) {
+			if v != unique[i] {
+				t.Errorf("%s %v at tick %d, want the latest value %v", name, v, i+1, unique[i])
+			}
+		}
+	}
+}
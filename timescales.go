@@ -0,0 +1,25 @@
+package culsim
+
+import "fmt"
+
+// check the per-feature update rates, one for each feature between 0 and 1
+func validateFeatureRates(rates []float64) error {
+	if len(rates) != len(masks) {
+		return fmt.Errorf("need %d rates, one per feature, got %d", len(masks), len(rates))
+	}
+	for i, r := range rates {
+		if r < 0 || r > 1 {
+			return fmt.Errorf("rate of feature %d must be between 0 and 1", i)
+		}
+	}
+	return nil
+}
+
+// check if a chosen feature is updated in an exchange, slow features are
+// only updated at their configured rate
+func (e *Engine) featureUpdates(i int) bool {
+	if e.cfg.FeatureRates == nil {
+		return true
+	}
+	return e.rng.Float64() < e.cfg.FeatureRates[i]
+}
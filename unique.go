@@ -0,0 +1,81 @@
+package culsim
+
+import (
+	"math"
+	"math/bits"
+)
+
+// cultures are packed into an int of 4 bits per feature, so the culture
+// itself is an exact fingerprint of its traits and the exact count of unique
+// cultures is a set of ints, however many features and traits there are.
+// Very large grids can count them approximately instead, in the fixed
+// memory of a HyperLogLog sketch.
+
+// count the unique cultures of the grid, empty cells included, with the
+// sketch of WithApproximateUnique if there is one
+func (e *Engine) similarCount() int {
+	if e.params.UniquePrecision > 0 {
+		h := newHyperLogLog(e.params.UniquePrecision)
+		for _, c := range e.cultures {
+			h.add(fingerprint(c))
+		}
+		return int(math.Round(h.count()))
+	}
+	uniques := make(map[int]struct{})
+	for _, c := range e.cultures {
+		uniques[c] = struct{}{}
+	}
+	return len(uniques)
+}
+
+// 64-bit hash of a culture, spreading its traits over every bit with the
+// finalizer of splitmix64
+func fingerprint(c int) uint64 {
+	x := uint64(c)
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// HyperLogLog sketch of the number of distinct hashes added, with 2^p
+// registers and a standard error of 1.04/sqrt(2^p)
+type hyperLogLog struct {
+	p         uint8
+	registers []uint8
+}
+
+func newHyperLogLog(p int) *hyperLogLog {
+	return &hyperLogLog{p: uint8(p), registers: make([]uint8, 1<<p)}
+}
+
+// add a hash, its first p bits picking the register that keeps the longest
+// run of leading zeros of the rest
+func (h *hyperLogLog) add(x uint64) {
+	i := x >> (64 - h.p)
+	rank := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+// estimated number of distinct hashes added, corrected by linear counting
+// for small counts
+func (h *hyperLogLog) count() float64 {
+	m := float64(len(h.registers))
+	var sum float64
+	var zeros int
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		return m * math.Log(m/float64(zeros))
+	}
+	return estimate
+}
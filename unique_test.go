@@ -0,0 +1,67 @@
+package culsim
+
+import (
+	"math"
+	"testing"
+)
+
+// the exact count of unique cultures is that of a set of the cultures of the
+// grid, and the approximate one is within 3 standard errors of it
+func TestSimilarCount(t *testing.T) {
+	opts := []Option{WithGrid(100, 100), WithCoverage(0.9), WithFeatures(3, 10), WithSeed(1)}
+	e, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uniques := make(map[int]bool)
+	for _, c := range e.Cultures() {
+		uniques[c] = true
+	}
+	if got := e.similarCount(); got != len(uniques) {
+		t.Errorf("counted %d unique cultures, want %d", got, len(uniques))
+	}
+	approximate, err := New(append(opts, WithApproximateUnique(12))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := float64(approximate.similarCount()), float64(len(uniques))
+	if math.Abs(got-want) > 3*1.04/64*want {
+		t.Errorf("approximately counted %.0f unique cultures, want about %.0f", got, want)
+	}
+}
+
+// a HyperLogLog sketch counts distinct hashes within 3 standard errors,
+// counting linearly for small counts, and added hashes again don't count
+func TestHyperLogLog(t *testing.T) {
+	for _, p := range []int{4, 16} {
+		tolerance := 3 * 1.04 / math.Sqrt(math.Ldexp(1, p))
+		for _, n := range []int{10, 1000, 100000, 1000000} {
+			h := newHyperLogLog(p)
+			for twice := 0; twice < 2; twice++ {
+				for c := 0; c < n; c++ {
+					h.add(fingerprint(c))
+				}
+			}
+			if got := h.count(); math.Abs(got-float64(n)) > tolerance*float64(n) {
+				t.Errorf("precision %d counted %.0f of %d hashes", p, got, n)
+			}
+		}
+	}
+	if got := newHyperLogLog(4).count(); got != 0 {
+		t.Errorf("counted %.0f hashes of an empty sketch", got)
+	}
+}
+
+// precisions of the approximate count outside 4 to 16 are refused
+func TestApproximateUniquePrecision(t *testing.T) {
+	for _, precision := range []int{-1, 1, 3, 17, 24} {
+		if _, err := New(WithApproximateUnique(precision)); err == nil {
+			t.Errorf("created a run of precision %d", precision)
+		}
+	}
+	for _, precision := range []int{0, 4, 16} {
+		if _, err := New(WithApproximateUnique(precision)); err != nil {
+			t.Errorf("refused a run of precision %d: %v", precision, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package culsim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// check the window of the config, if any, is a part of the grid the
+// interactions of a serial tick can be limited to
+func (p *Params) validateWindow() error {
+	if p.Config == nil || p.Config.Window == nil {
+		return nil
+	}
+	w := p.Config.Window
+	if err := w.validate(); err != nil {
+		return fmt.Errorf("window: %w", err)
+	}
+	if w.X < 0 || w.Y < 0 || w.X+w.W > p.Width || w.Y+w.H > p.Height {
+		return fmt.Errorf("window of %dx%d cells at %d,%d is outside the grid of %dx%d cells", w.W, w.H, w.X, w.Y,
+			p.Width, p.Height)
+	}
+	if p.Workers > 0 {
+		return errors.New("a window cannot be combined with parallel ticks")
+	}
+	return nil
+}
+
+// check if the cell at n is frozen outside the window of the config, kept
+// at its culture as a boundary the cells of the window interact with
+func (e *Engine) frozen(n int) bool {
+	return e.cfg.Window != nil && !e.cfg.Window.contains(n, e.width)
+}
+
+// randomly choose a cell of the window of the config
+func (e *Engine) windowCell() int {
+	w := e.cfg.Window
+	k := e.rng.Intn(w.W * w.H)
+	return (w.Y+k/w.W)*e.width + w.X + k%w.W
+}
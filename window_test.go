@@ -0,0 +1,42 @@
+package culsim
+
+import (
+	"context"
+	"testing"
+)
+
+// cells outside the window keep their cultures whatever changes the cells
+// inside it, exchanges, noise, colonization and conquest
+func TestWindow(t *testing.T) {
+	window := &Region{X: 5, Y: 5, W: 8, H: 8}
+	e, err := New(WithGrid(20, 20), WithCoverage(0.8), WithNoise(0.05), WithColonization(0.1), WithConquest(0.05, 3),
+		WithSeed(1), WithConfig(&Config{Window: window}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exchanges int
+	e.Bus().Subscribe(func(m Message) {
+		if x, ok := m.(ExchangeHappened); ok {
+			exchanges++
+			if !window.contains(x.Dst, e.Width()) {
+				t.Errorf("tick %d: cell %d outside the window took a trait", x.Tick, x.Dst)
+			}
+		}
+	})
+	start := e.Cultures()
+	for e.Tick() < 50 {
+		e.Step(context.Background())
+	}
+	var changed int
+	for n, c := range e.Cultures() {
+		switch {
+		case !window.contains(n, e.Width()) && c != start[n]:
+			t.Fatalf("cell %d outside the window changed from %06X to %06X", n, start[n], c)
+		case c != start[n]:
+			changed++
+		}
+	}
+	if exchanges == 0 || changed == 0 {
+		t.Errorf("%d exchanges changed %d cells inside the window", exchanges, changed)
+	}
+}